@@ -0,0 +1,212 @@
+package collie
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+)
+
+// Arrow IPC constants
+const (
+	arrowMetadataV5          = 4
+	arrowHeaderSchema        = 1
+	arrowHeaderRecordBatch   = 3
+	arrowTypeBinary          = 4
+	arrowTypeFixedSizeBinary = 15
+)
+
+var arrowMagic = []byte("ARROW1")
+
+// ExportArrow writes the selected columns and rows to w as an Arrow IPC
+// file. Fixed-length columns are exported as FixedSizeBinary, variable
+// ones as Binary. Rows are streamed in record batches of
+// ExportOptions.BatchRows rows each. Encodings are ignored.
+func (c *Collection) ExportArrow(w io.Writer, opts *ExportOptions) error {
+	return c.ExportArrowContext(context.Background(), w, opts)
+}
+
+// ExportArrowContext behaves like ExportArrow, but aborts when ctx is
+// done, checking between record batches
+func (c *Collection) ExportArrowContext(ctx context.Context, w io.Writer, opts *ExportOptions) error {
+	opts, err := opts.norm(c)
+	if err != nil {
+		return err
+	}
+
+	cols := c.exportColumns(opts)
+	aw := &arrowWriter{w: &countingWriter{w: bufio.NewWriter(w)}, schema: arrowSchema(cols)}
+	if err := aw.begin(); err != nil {
+		return err
+	}
+
+	err = c.exportBatches(ctx, opts, func(n int64, vals [][]Value) error {
+		return aw.writeBatch(cols, n, vals)
+	})
+	if err != nil {
+		return err
+	}
+	return aw.finish()
+}
+
+// arrowWriter writes Arrow IPC files
+type arrowWriter struct {
+	w       *countingWriter
+	schema  *fbTable
+	batches []byte
+	nb      int
+}
+
+func (aw *arrowWriter) begin() error {
+	if _, err := aw.w.Write(append(arrowMagic, 0, 0)); err != nil {
+		return err
+	}
+	_, _, err := aw.writeMessage(arrowHeaderSchema, aw.schema, nil)
+	return err
+}
+
+func (aw *arrowWriter) writeBatch(cols []Column, n int64, vals [][]Value) error {
+	var body []byte
+	nodes := &fbStructs{n: len(cols)}
+	buffers := &fbStructs{}
+
+	addBuffer := func(data []byte) {
+		buffers.data = appendInt64s(buffers.data, int64(len(body)), int64(len(data)))
+		buffers.n++
+		body = append(body, data...)
+		for len(body)%8 != 0 {
+			body = append(body, 0)
+		}
+	}
+
+	for i, col := range cols {
+		nodes.data = appendInt64s(nodes.data, n, 0)
+		addBuffer(nil)
+
+		if col.Size > 0 {
+			data := make([]byte, 0, int(n)*col.Size)
+			for _, val := range vals[i] {
+				data = append(data, val...)
+			}
+			addBuffer(data)
+			continue
+		}
+
+		offsets := make([]byte, 4, 4*(n+1))
+		var data []byte
+		for _, val := range vals[i] {
+			data = append(data, val...)
+			offsets = append(offsets, 0, 0, 0, 0)
+			binary.LittleEndian.PutUint32(offsets[len(offsets)-4:], uint32(len(data)))
+		}
+		addBuffer(offsets)
+		addBuffer(data)
+	}
+
+	batch := new(fbTable)
+	batch.Int64(0, n)
+	batch.Ref(1, nodes)
+	batch.Ref(2, buffers)
+
+	pos, meta, err := aw.writeMessage(arrowHeaderRecordBatch, batch, body)
+	if err != nil {
+		return err
+	}
+
+	// Blocks: offset, metadata length (+ padding), body length
+	aw.batches = appendInt64s(aw.batches, pos, int64(meta), int64(len(body)))
+	aw.nb++
+	return nil
+}
+
+func (aw *arrowWriter) finish() error {
+	// End-of-stream marker
+	if _, err := aw.w.Write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}); err != nil {
+		return err
+	}
+
+	footer := new(fbTable)
+	footer.Int16(0, arrowMetadataV5)
+	footer.Ref(1, aw.schema)
+	footer.Ref(2, &fbStructs{})
+	footer.Ref(3, &fbStructs{n: aw.nb, data: aw.batches})
+	data := fbEncode(footer)
+
+	size := make([]byte, 4)
+	binary.LittleEndian.PutUint32(size, uint32(len(data)))
+	for _, p := range [][]byte{data, size, arrowMagic} {
+		if _, err := aw.w.Write(p); err != nil {
+			return err
+		}
+	}
+	return aw.w.Flush()
+}
+
+// writeMessage writes an encapsulated message and returns its position
+// and the length of the metadata, including the prefix
+func (aw *arrowWriter) writeMessage(kind uint8, header *fbTable, body []byte) (int64, int, error) {
+	msg := new(fbTable)
+	msg.Int16(0, arrowMetadataV5)
+	msg.Uint8(1, kind)
+	msg.Ref(2, header)
+	msg.Int64(3, int64(len(body)))
+	data := fbEncode(msg)
+
+	prefix := make([]byte, 8)
+	binary.LittleEndian.PutUint32(prefix[0:], 0xffffffff)
+	binary.LittleEndian.PutUint32(prefix[4:], uint32(len(data)))
+
+	pos := aw.w.n
+	for _, p := range [][]byte{prefix, data, body} {
+		if _, err := aw.w.Write(p); err != nil {
+			return pos, 0, err
+		}
+	}
+	return pos, len(prefix) + len(data), nil
+}
+
+// arrowSchema builds the schema table for cols
+func arrowSchema(cols []Column) *fbTable {
+	fields := make(fbTables, 0, len(cols))
+	for _, col := range cols {
+		typ := new(fbTable)
+		field := new(fbTable)
+		field.Ref(0, fbString(col.Name))
+		if col.Size > 0 {
+			typ.Int32(0, int32(col.Size))
+			field.Uint8(2, arrowTypeFixedSizeBinary)
+		} else {
+			field.Uint8(2, arrowTypeBinary)
+		}
+		field.Ref(3, typ)
+		field.Ref(5, fbTables{})
+		fields = append(fields, field)
+	}
+
+	schema := new(fbTable)
+	schema.Ref(1, fields)
+	return schema
+}
+
+func appendInt64s(dst []byte, vs ...int64) []byte {
+	buf := make([]byte, 8)
+	for _, v := range vs {
+		binary.LittleEndian.PutUint64(buf, uint64(v))
+		dst = append(dst, buf...)
+	}
+	return dst
+}
+
+// countingWriter counts the bytes written to a buffered writer
+type countingWriter struct {
+	w *bufio.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *countingWriter) Flush() error { return w.w.Flush() }
@@ -0,0 +1,95 @@
+package collie
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Arrow", func() {
+	var subject *Collection
+
+	BeforeEach(func() {
+		schema := CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "code", Size: 2},
+			{Name: "tag", Index: IndexTypeHash, NoData: true},
+		})
+
+		var err error
+		subject, err = OpenCollection(testDir, schema)
+		Expect(err).NotTo(HaveOccurred())
+
+		txn := subject.Begin(5)
+		for i := 0; i < 5; i++ {
+			txn.Add(testRecord{"name": Value("name" + strconv.Itoa(i)), "code": Value(strconv.Itoa(i))})
+		}
+		_, err = txn.Commit()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should export IPC files", func() {
+		buf := new(bytes.Buffer)
+		Expect(subject.ExportArrow(buf, &ExportOptions{From: 1, BatchRows: 3})).To(Succeed())
+
+		data := buf.Bytes()
+		Expect(data[:8]).To(Equal([]byte("ARROW1\x00\x00")))
+		Expect(data[len(data)-6:]).To(Equal([]byte("ARROW1")))
+
+		size := int(binary.LittleEndian.Uint32(data[len(data)-10:]))
+		r := fbReader(data[len(data)-10-size : len(data)-10])
+		footer := r.root()
+		Expect(r.int16(footer, 0)).To(Equal(int16(arrowMetadataV5)))
+
+		fields := r.tables(r.table(footer, 1), 1)
+		Expect(fields).To(HaveLen(2))
+		Expect(r.str(fields[0], 0)).To(Equal("name"))
+		Expect(r.uint8(fields[0], 2)).To(Equal(uint8(arrowTypeBinary)))
+		Expect(r.str(fields[1], 0)).To(Equal("code"))
+		Expect(r.uint8(fields[1], 2)).To(Equal(uint8(arrowTypeFixedSizeBinary)))
+		Expect(r.int32(r.table(fields[1], 3), 0)).To(Equal(int32(2)))
+
+		n, blocks := r.structs(footer, 3)
+		Expect(n).To(Equal(2))
+
+		// Decode the second record batch
+		offset := int(binary.LittleEndian.Uint64(blocks[24:]))
+		meta := int(binary.LittleEndian.Uint32(blocks[32:]))
+		Expect(binary.LittleEndian.Uint32(data[offset:])).To(Equal(uint32(0xffffffff)))
+
+		msg := fbReader(data[offset+8 : offset+meta])
+		root := msg.root()
+		Expect(msg.uint8(root, 1)).To(Equal(uint8(arrowHeaderRecordBatch)))
+		batch := msg.table(root, 2)
+		Expect(msg.int64(batch, 0)).To(Equal(int64(1)))
+
+		_, buffers := msg.structs(batch, 2)
+		body := data[offset+meta:]
+		buffer := func(i int) []byte {
+			pos := binary.LittleEndian.Uint64(buffers[16*i:])
+			return body[pos : pos+binary.LittleEndian.Uint64(buffers[16*i+8:])]
+		}
+		Expect(buffer(1)).To(Equal([]byte{0, 0, 0, 0, 5, 0, 0, 0}))
+		Expect(buffer(2)).To(Equal([]byte("name4")))
+		Expect(buffer(4)).To(Equal([]byte{'4', 0}))
+	})
+
+	It("should abort when cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Expect(subject.ExportArrowContext(ctx, new(bytes.Buffer), nil)).To(Equal(context.Canceled))
+	})
+
+	It("should reject invalid columns", func() {
+		Expect(subject.ExportArrow(new(bytes.Buffer), &ExportOptions{Columns: []string{"tag"}})).To(Equal(ErrColumnNotFound))
+	})
+
+})
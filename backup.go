@@ -0,0 +1,357 @@
+package collie
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bsm/collie/column"
+)
+
+const (
+	backupManifestFile = "BACKUP"
+	backupRowsFile     = "ROWS"
+	backupBatchRows    = 1000
+	readBytesChunk     = 64 << 10
+)
+
+var (
+	errBackupGap    = errors.New("collie: backup starts after current offset")
+	errBackupSchema = errors.New("collie: backup schema mismatch")
+	errBackupFormat = errors.New("collie: invalid backup")
+	errBackupShort  = errors.New("collie: backup ends before its offset")
+)
+
+// backupManifest describes a backup
+type backupManifest struct {
+	// Offset of the first row
+	Since int64
+	// Offset of the backup
+	Offset  int64
+	Columns []string
+	Indices []string
+}
+
+// Backup writes a consistent archive of all rows committed from since up
+// to the current offset to w and returns the offset the backup is pinned
+// to. Passing the returned offset to the next call produces an incremental
+// backup. Commits can continue while the backup is written. Backups are
+// tar archives, storing rows in batches, each in a directory named after
+// its offset, with one file per column and index.
+func (c *Collection) Backup(w io.Writer, since int64) (int64, error) {
+	return c.BackupContext(context.Background(), w, since)
+}
+
+// BackupContext behaves like Backup, but aborts when ctx is done,
+// checking between batches
+func (c *Collection) BackupContext(ctx context.Context, w io.Writer, since int64) (int64, error) {
+	if first := c.FirstOffset(); since < first {
+		since = first
+	}
+
+	offset := c.Offset()
+	if since > offset {
+		return offset, errBackupGap
+	}
+
+	head := c.head()
+	if head == nil {
+		return offset, ErrClosed
+	}
+	manifest := &backupManifest{Since: since, Offset: offset}
+	for name := range head.columns {
+		manifest.Columns = append(manifest.Columns, name)
+	}
+	for name := range head.indices {
+		manifest.Indices = append(manifest.Indices, name)
+	}
+	sort.Strings(manifest.Columns)
+	sort.Strings(manifest.Indices)
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return offset, err
+	}
+
+	tw := tar.NewWriter(w)
+	if err := writeTarFile(tw, backupManifestFile, data); err != nil {
+		return offset, err
+	}
+	batches := c.newBatchReader()
+	for since < offset {
+		if err := canceled(ctx); err != nil {
+			return offset, err
+		}
+
+		limit := since + backupBatchRows
+		if limit > offset {
+			limit = offset
+		}
+
+		batch, err := batches.read(since, limit)
+		if err != nil {
+			return offset, err
+		}
+		if err := writeBackupBatch(tw, batch); err != nil {
+			return offset, err
+		}
+		since += batch.Rows
+	}
+	return offset, tw.Close()
+}
+
+// Restore applies a backup, created via Backup, and returns the new
+// offset. Rows which are already present are skipped, incremental
+// backups must therefore be restored in order. Truncated backups fail,
+// after applying all complete batches.
+func (c *Collection) Restore(r io.Reader) (int64, error) {
+	tr := tar.NewReader(bufio.NewReader(r))
+
+	hdr, err := tr.Next()
+	if err == io.EOF || (err == nil && hdr.Name != backupManifestFile) {
+		return c.Offset(), errBackupFormat
+	} else if err != nil {
+		return c.Offset(), err
+	}
+
+	var manifest backupManifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return c.Offset(), err
+	}
+	if err := c.checkBackup(&manifest); err != nil {
+		return c.Offset(), err
+	}
+
+	var batch *commitBatch
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return c.Offset(), err
+		}
+
+		dir, name := path.Split(hdr.Name)
+		if name == backupRowsFile {
+			if batch != nil {
+				if err := c.apply(batch); err != nil {
+					return c.Offset(), err
+				}
+			}
+			if batch, err = readBackupBatch(tr, dir, &manifest); err != nil {
+				return c.Offset(), err
+			}
+		} else if batch == nil || dir != segmentName(batch.Offset)+"/" {
+			return c.Offset(), errBackupFormat
+		} else if err := batch.decode(tr, name); err != nil {
+			return c.Offset(), err
+		}
+	}
+	if batch != nil {
+		if err := c.apply(batch); err != nil {
+			return c.Offset(), err
+		}
+	}
+	if offset := c.Offset(); offset < manifest.Offset {
+		return offset, errBackupShort
+	}
+	return c.Offset(), nil
+}
+
+// checkBackup validates a backup manifest against the collection
+func (c *Collection) checkBackup(m *backupManifest) error {
+	head := c.head()
+	if head == nil {
+		return ErrClosed
+	} else if len(m.Columns) != len(head.columns) || len(m.Indices) != len(head.indices) {
+		return errBackupSchema
+	}
+	for _, name := range m.Columns {
+		if _, ok := head.columns[name]; !ok {
+			return errBackupSchema
+		}
+	}
+	for _, name := range m.Indices {
+		if _, ok := head.indices[name]; !ok {
+			return errBackupSchema
+		}
+	}
+	if m.Since > c.Offset() {
+		return errBackupGap
+	}
+	return nil
+}
+
+// writeBackupBatch writes a batch as a directory of files
+func writeBackupBatch(tw *tar.Writer, batch *commitBatch) error {
+	dir := segmentName(batch.Offset)
+	if err := writeTarFile(tw, path.Join(dir, backupRowsFile), []byte(strconv.FormatInt(batch.Rows, 10))); err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	for name, vals := range batch.Values {
+		buf.Reset()
+		for _, val := range vals {
+			writeUvarint(buf, uint64(len(val)))
+			buf.Write(val)
+		}
+		if err := writeTarFile(tw, path.Join(dir, name+".cc"), buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	for name, p := range batch.Postings {
+		vals := make([]string, 0, len(p))
+		for val := range p {
+			vals = append(vals, val)
+		}
+		sort.Strings(vals)
+
+		buf.Reset()
+		for _, val := range vals {
+			writeUvarint(buf, uint64(len(val)))
+			buf.WriteString(val)
+			writeUvarint(buf, uint64(len(p[val])))
+			for _, off := range p[val] {
+				writeUvarint(buf, uint64(off-batch.Offset))
+			}
+		}
+		if err := writeTarFile(tw, path.Join(dir, name+".ci"), buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readBackupBatch reads the number of rows of a batch and
+// returns an empty batch. Batches must lie within the range of m.
+func readBackupBatch(r io.Reader, dir string, m *backupManifest) (*commitBatch, error) {
+	offset, err := strconv.ParseInt(strings.TrimSuffix(dir, "/"), 10, 64)
+	if err != nil || offset < m.Since {
+		return nil, errBackupFormat
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r, 20))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil || rows < 0 || rows > m.Offset-offset {
+		return nil, errBackupFormat
+	}
+
+	return &commitBatch{
+		Offset:   offset,
+		Rows:     rows,
+		Values:   make(map[string][]Value),
+		Postings: make(map[string]column.Postings),
+	}, nil
+}
+
+// decode decodes the column or index file name into b
+func (b *commitBatch) decode(r io.Reader, name string) error {
+	br := bufio.NewReader(r)
+	switch ext := path.Ext(name); ext {
+	case ".cc":
+		var vals []Value
+		for int64(len(vals)) < b.Rows {
+			val, err := readBytes(br)
+			if err != nil {
+				return err
+			}
+			vals = append(vals, val)
+		}
+		b.Values[strings.TrimSuffix(name, ext)] = vals
+	case ".ci":
+		p := make(column.Postings)
+		for {
+			val, err := readBytes(br)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+
+			n, err := binary.ReadUvarint(br)
+			if err != nil {
+				return errBackupFormat
+			}
+			var offs []int64
+			for i := uint64(0); i < n; i++ {
+				rel, err := binary.ReadUvarint(br)
+				if err != nil || int64(rel) >= b.Rows {
+					return errBackupFormat
+				}
+				offs = append(offs, b.Offset+int64(rel))
+			}
+			p[string(val)] = offs
+		}
+		b.Postings[strings.TrimSuffix(name, ext)] = p
+	default:
+		return fmt.Errorf("collie: unexpected backup file '%s'", name)
+	}
+	return nil
+}
+
+// writeTarFile writes a regular file to tw
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func writeUvarint(buf *bytes.Buffer, n uint64) {
+	tmp := make([]byte, binary.MaxVarintLen64)
+	buf.Write(tmp[:binary.PutUvarint(tmp, n)])
+}
+
+// readBytes reads a length-prefixed byte slice, returns io.EOF
+// if r is exhausted
+func readBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err == io.EOF {
+		return nil, err
+	} else if err != nil {
+		return nil, errBackupFormat
+	}
+
+	// Lengths are untrusted, large buffers grow as data is read
+	if n <= readBytesChunk {
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, errBackupFormat
+		}
+		return buf, nil
+	}
+
+	if n > math.MaxInt64 {
+		return nil, errBackupFormat
+	}
+	buf, err := ioutil.ReadAll(io.LimitReader(r, int64(n)))
+	if err != nil {
+		return nil, err
+	} else if uint64(len(buf)) != n {
+		return nil, errBackupFormat
+	}
+	return buf, nil
+}
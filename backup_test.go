@@ -0,0 +1,248 @@
+package collie
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backup", func() {
+	var subject, target *Collection
+	var schema *Schema
+
+	add := func(coll *Collection, names ...string) error {
+		txn := coll.Begin(len(names))
+		for _, name := range names {
+			txn.Add(testRecord{"name": Value(name), "tag": Value(name[:1])})
+		}
+		_, err := txn.Commit()
+		return err
+	}
+
+	BeforeEach(func() {
+		schema = CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "tag", Index: IndexTypeHash, NoData: true},
+		})
+
+		var err error
+		subject, err = OpenCollection(filepath.Join(testDir, "source"), schema)
+		Expect(err).NotTo(HaveOccurred())
+		target, err = OpenCollection(filepath.Join(testDir, "target"), schema)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(add(subject, "alice", "bob", "anna")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+		target.Close()
+	})
+
+	It("should write archives", func() {
+		buf := new(bytes.Buffer)
+		Expect(subject.Backup(buf, 0)).To(Equal(int64(3)))
+
+		var names []string
+		tr := tar.NewReader(buf)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).NotTo(HaveOccurred())
+			names = append(names, hdr.Name)
+		}
+		Expect(names).To(Equal([]string{
+			"BACKUP",
+			"00000000000000000000/ROWS",
+			"00000000000000000000/name.cc",
+			"00000000000000000000/tag.ci",
+		}))
+	})
+
+	It("should restore backups", func() {
+		buf := new(bytes.Buffer)
+		Expect(subject.Backup(buf, 0)).To(Equal(int64(3)))
+		Expect(target.Restore(buf)).To(Equal(int64(3)))
+
+		Expect(target.Value("name", 2)).To(Equal([]byte("anna")))
+		Expect(target.Offsets("tag", []byte("a"))).To(Equal([]int64{0, 2}))
+		Expect(target.Offsets("tag", []byte("b"))).To(Equal([]int64{1}))
+	})
+
+	It("should restore incremental backups", func() {
+		full := new(bytes.Buffer)
+		offset, err := subject.Backup(full, 0)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(add(subject, "carol", "bill")).To(Succeed())
+		incr := new(bytes.Buffer)
+		Expect(subject.Backup(incr, offset)).To(Equal(int64(5)))
+
+		_, err = target.Restore(bytes.NewReader(incr.Bytes()))
+		Expect(err).To(Equal(errBackupGap))
+
+		Expect(target.Restore(full)).To(Equal(int64(3)))
+		Expect(target.Restore(incr)).To(Equal(int64(5)))
+		Expect(target.Value("name", 3)).To(Equal([]byte("carol")))
+		Expect(target.Offsets("tag", []byte("b"))).To(Equal([]int64{1, 4}))
+	})
+
+	It("should skip restored rows", func() {
+		buf := new(bytes.Buffer)
+		Expect(subject.Backup(buf, 0)).To(Equal(int64(3)))
+		Expect(target.Restore(bytes.NewReader(buf.Bytes()))).To(Equal(int64(3)))
+		Expect(target.Restore(bytes.NewReader(buf.Bytes()))).To(Equal(int64(3)))
+		Expect(target.Offsets("tag", []byte("a"))).To(Equal([]int64{0, 2}))
+	})
+
+	It("should pin backups to an offset", func() {
+		for i := 0; i < 30; i++ {
+			Expect(add(subject, "name"+strconv.Itoa(i))).To(Succeed())
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			for i := 0; i < 30; i++ {
+				Expect(add(subject, "more"+strconv.Itoa(i))).To(Succeed())
+			}
+		}()
+
+		buf := new(bytes.Buffer)
+		offset, err := subject.Backup(buf, 0)
+		Expect(err).NotTo(HaveOccurred())
+		<-done
+
+		Expect(target.Restore(buf)).To(Equal(offset))
+		Expect(target.Value("name", offset-1)).To(Equal([]byte(mustValue(subject, "name", offset-1))))
+	})
+
+	It("should reject corrupt archives", func() {
+		archive := func(rows string, files map[string][]byte) io.Reader {
+			buf := new(bytes.Buffer)
+			tw := tar.NewWriter(buf)
+			Expect(writeTarFile(tw, "BACKUP", []byte(`{"Since":0,"Offset":3,"Columns":["name"],"Indices":["tag"]}`))).To(Succeed())
+			Expect(writeTarFile(tw, "00000000000000000000/ROWS", []byte(rows))).To(Succeed())
+			for _, name := range []string{"name.cc", "tag.ci"} {
+				if data, ok := files[name]; ok {
+					Expect(writeTarFile(tw, "00000000000000000000/"+name, data)).To(Succeed())
+				}
+			}
+			Expect(tw.Close()).To(Succeed())
+			return buf
+		}
+		huge := []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x40}
+
+		_, err := target.Restore(archive("3", map[string][]byte{"tag.ci": nil}))
+		Expect(err).To(Equal(errIncompleteBatch))
+		_, err = target.Restore(archive("1099511627776", nil))
+		Expect(err).To(Equal(errBackupFormat))
+		_, err = target.Restore(archive("1", map[string][]byte{"name.cc": huge}))
+		Expect(err).To(Equal(errBackupFormat))
+		_, err = target.Restore(archive("1", map[string][]byte{"name.cc": {1, 'x'}, "tag.ci": append([]byte{1, 'x'}, huge...)}))
+		Expect(err).To(Equal(errBackupFormat))
+		Expect(target.Offset()).To(Equal(int64(0)))
+	})
+
+	It("should abort when cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := subject.BackupContext(ctx, new(bytes.Buffer), 0)
+		Expect(err).To(Equal(context.Canceled))
+	})
+
+	It("should reject truncated archives", func() {
+		buf := new(bytes.Buffer)
+		Expect(subject.Backup(buf, 0)).To(Equal(int64(3)))
+
+		type entry struct {
+			name string
+			data []byte
+		}
+		var entries []entry
+		tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).NotTo(HaveOccurred())
+			data, err := ioutil.ReadAll(tr)
+			Expect(err).NotTo(HaveOccurred())
+			entries = append(entries, entry{hdr.Name, data})
+		}
+		Expect(entries).To(HaveLen(4))
+
+		// Cut after each entry, without the end-of-archive marker
+		for n := 1; n < len(entries); n++ {
+			cut := new(bytes.Buffer)
+			tw := tar.NewWriter(cut)
+			for _, e := range entries[:n] {
+				Expect(writeTarFile(tw, e.name, e.data)).To(Succeed())
+			}
+			Expect(tw.Flush()).To(Succeed())
+
+			_, err := target.Restore(cut)
+			Expect(err).To(HaveOccurred(), "cut after %s", entries[n-1].name)
+			Expect(target.Offset()).To(Equal(int64(0)))
+		}
+
+		// Cut within the last entry
+		_, err := target.Restore(bytes.NewReader(buf.Bytes()[:buf.Len()-1536]))
+		Expect(err).To(HaveOccurred())
+		Expect(target.Offset()).To(Equal(int64(0)))
+
+		// Skip the index postings
+		cut := new(bytes.Buffer)
+		tw := tar.NewWriter(cut)
+		for _, e := range entries[:3] {
+			Expect(writeTarFile(tw, e.name, e.data)).To(Succeed())
+		}
+		Expect(tw.Close()).To(Succeed())
+		_, err = target.Restore(cut)
+		Expect(err).To(Equal(errIncompleteBatch))
+
+		Expect(target.Restore(buf)).To(Equal(int64(3)))
+	})
+
+	It("should report short archives", func() {
+		buf := new(bytes.Buffer)
+		tw := tar.NewWriter(buf)
+		Expect(writeTarFile(tw, "BACKUP", []byte(`{"Since":0,"Offset":5,"Columns":["name"],"Indices":["tag"]}`))).To(Succeed())
+		Expect(tw.Close()).To(Succeed())
+
+		_, err := target.Restore(buf)
+		Expect(err).To(Equal(errBackupShort))
+	})
+
+	It("should reject mismatching schemas", func() {
+		other, err := OpenCollection(filepath.Join(testDir, "other"), CreateSchema([]Column{{Name: "name"}}))
+		Expect(err).NotTo(HaveOccurred())
+		defer other.Close()
+
+		buf := new(bytes.Buffer)
+		Expect(subject.Backup(buf, 0)).To(Equal(int64(3)))
+		_, err = other.Restore(buf)
+		Expect(err).To(Equal(errBackupSchema))
+
+		_, err = other.Restore(bytes.NewReader([]byte("bogus")))
+		Expect(err).To(HaveOccurred())
+	})
+
+})
+
+func mustValue(coll *Collection, name string, offset int64) []byte {
+	val, err := coll.Value(name, offset)
+	Expect(err).NotTo(HaveOccurred())
+	return val
+}
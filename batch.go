@@ -0,0 +1,226 @@
+package collie
+
+import (
+	"errors"
+
+	"github.com/bsm/collie/column"
+)
+
+// The maximum number of rows, for which batch readers retain index values
+var batchReaderWindow int64 = 64 << 10
+
+var errIncompleteBatch = errors.New("collie: batch lacks column values or index postings")
+
+// commitBatch holds a contiguous range of committed rows
+type commitBatch struct {
+	// Offset of the first row
+	Offset int64
+	// Number of rows
+	Rows int64
+	// Column values by column name
+	Values map[string][]Value
+	// Index postings by index name
+	Postings map[string]column.Postings
+}
+
+// rows converts the batch into a slice of rows
+func (b *commitBatch) rows() []*Row {
+	rows := make([]*Row, b.Rows)
+	for i := range rows {
+		rows[i] = newRow(len(b.Values), len(b.Postings))
+	}
+	for name, vals := range b.Values {
+		for i, val := range vals {
+			rows[i].SetColumn(name, val)
+		}
+	}
+	for name, p := range b.Postings {
+		for val, offs := range p {
+			for _, off := range offs {
+				if n := off - b.Offset; n >= 0 && n < b.Rows {
+					rows[n].AddIndex(name, Value(val))
+				}
+			}
+		}
+	}
+	return rows
+}
+
+// records converts the batch into a slice of records
+func (b *commitBatch) records() []Record {
+	rows := b.rows()
+	recs := make([]Record, len(rows))
+	for i, row := range rows {
+		recs[i] = row
+	}
+	return recs
+}
+
+// detach copies all column values into a single buffer, releasing
+// references to buffers owned by callers
+func (b *commitBatch) detach() {
+	size := 0
+	for _, vals := range b.Values {
+		for _, val := range vals {
+			size += len(val)
+		}
+	}
+
+	buf := make([]byte, 0, size)
+	for name, vals := range b.Values {
+		cp := make([]Value, len(vals))
+		for i, val := range vals {
+			if val != nil {
+				n := len(buf)
+				buf = append(buf, val...)
+				cp[i] = buf[n:len(buf):len(buf)]
+			}
+		}
+		b.Values[name] = cp
+	}
+}
+
+// batchReader reads consecutive batches of committed rows. Indices are
+// scanned once per window of up to batchReaderWindow rows, retaining the
+// index values of all rows within the window for subsequent batches.
+type batchReader struct {
+	c *Collection
+
+	seg      *segment
+	from, to int64
+	// index values per row, relative to from, by index name
+	values map[string][][]string
+}
+
+// newBatchReader creates a new batch reader
+func (c *Collection) newBatchReader() *batchReader {
+	return &batchReader{c: c}
+}
+
+// read reads committed rows within [offset, limit). Batches never
+// span multiple segments and may therefore contain fewer rows.
+func (r *batchReader) read(offset, limit int64) (*commitBatch, error) {
+	c := r.c
+	c.smux.RLock()
+
+	seg := c.segmentAt(offset)
+	if seg == nil {
+		c.smux.RUnlock()
+		return nil, ErrNotFound
+	}
+
+	end := c.Offset()
+	for i, s := range c.segs {
+		if s == seg && i+1 < len(c.segs) && end > c.segs[i+1].base {
+			end = c.segs[i+1].base
+		}
+	}
+	if limit > end {
+		limit = end
+	}
+	if offset >= limit {
+		c.smux.RUnlock()
+		return nil, ErrNotFound
+	}
+
+	batch := &commitBatch{
+		Offset: offset,
+		Rows:   limit - offset,
+		Values: make(map[string][]Value, len(seg.columns)),
+	}
+	for name, col := range seg.columns {
+		vals := make([]Value, 0, batch.Rows)
+		for off := offset; off < limit; off++ {
+			val, err := col.Get(off - seg.base)
+			if err != nil {
+				c.smux.RUnlock()
+				return nil, err
+			}
+			vals = append(vals, val)
+		}
+		batch.Values[name] = vals
+	}
+
+	if r.seg == seg && offset >= r.from && limit <= r.to {
+		c.smux.RUnlock()
+		batch.Postings = r.postings(offset, limit)
+		return batch, nil
+	}
+
+	// Indices supporting snapshots are scanned without holding the lock
+	r.seg, r.from, r.to = nil, offset, offset+batchReaderWindow
+	if r.to < limit {
+		r.to = limit
+	} else if r.to > end {
+		r.to = end
+	}
+	r.values = make(map[string][][]string, len(seg.indices))
+	snaps := make(map[string]column.IndexSnapshot, len(seg.indices))
+	defer func() {
+		for _, snap := range snaps {
+			snap.Release()
+		}
+	}()
+
+	var err error
+	for name, idx := range seg.indices {
+		r.values[name] = make([][]string, r.to-offset)
+		if s, ok := idx.(snapshotter); !ok {
+			err = r.scan(name, idx.Iterate(nil))
+		} else if snaps[name], err = s.Snapshot(); err != nil {
+			delete(snaps, name)
+		}
+		if err != nil {
+			break
+		}
+	}
+	c.smux.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for name, snap := range snaps {
+		if err := r.scan(name, snap.Iterate(nil)); err != nil {
+			return nil, err
+		}
+	}
+	r.seg = seg
+
+	batch.Postings = r.postings(offset, limit)
+	return batch, nil
+}
+
+// scan collects the index values of all rows within [from, to)
+func (r *batchReader) scan(name string, iter column.IndexIterator) error {
+	defer iter.Release()
+
+	rows := r.values[name]
+	for iter.Next() {
+		var val string
+		for _, off := range iter.Offsets() {
+			if off < r.from || off >= r.to {
+				continue
+			}
+			if val == "" {
+				val = string(iter.Value())
+			}
+			rows[off-r.from] = append(rows[off-r.from], val)
+		}
+	}
+	return iter.Error()
+}
+
+// postings returns the postings of all rows within [offset, limit)
+func (r *batchReader) postings(offset, limit int64) map[string]column.Postings {
+	res := make(map[string]column.Postings, len(r.values))
+	for name, rows := range r.values {
+		p := make(column.Postings)
+		for off := offset; off < limit; off++ {
+			for _, val := range rows[off-r.from] {
+				p[val] = append(p[val], off)
+			}
+		}
+		res[name] = p
+	}
+	return res
+}
@@ -0,0 +1,117 @@
+package collie
+
+import (
+	"github.com/bsm/collie/column"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("commitBatch", func() {
+	var subject *Collection
+
+	BeforeEach(func() {
+		schema := CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "tag", Index: IndexTypeHash, NoData: true},
+		})
+
+		var err error
+		subject, err = OpenCollectionWithOptions(testDir, schema, &Options{SegmentRows: 2})
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, names := range [][]string{{"alice", "bob"}, {"anne", "ben", "carl"}} {
+			txn := subject.Begin(len(names))
+			for _, name := range names {
+				txn.Add(testRecord{"name": Value(name), "tag": Value(name[:1])})
+			}
+			_, err := txn.Commit()
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should read batches", func() {
+		batches := subject.newBatchReader()
+		batch, err := batches.read(1, 10)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(batch).To(Equal(&commitBatch{
+			Offset:   1,
+			Rows:     1,
+			Values:   map[string][]Value{"name": {Value("bob")}},
+			Postings: map[string]column.Postings{"tag": {"b": {1}}},
+		}))
+
+		batch, err = batches.read(2, 4)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(batch).To(Equal(&commitBatch{
+			Offset:   2,
+			Rows:     2,
+			Values:   map[string][]Value{"name": {Value("anne"), Value("ben")}},
+			Postings: map[string]column.Postings{"tag": {"a": {2}, "b": {3}}},
+		}))
+
+		batch, err = batches.read(4, 5)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(batch.Postings).To(Equal(map[string]column.Postings{"tag": {"c": {4}}}))
+
+		batch, err = batches.read(0, 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(batch.Postings).To(Equal(map[string]column.Postings{"tag": {"a": {0}}}))
+
+		_, err = batches.read(5, 10)
+		Expect(err).To(Equal(ErrNotFound))
+	})
+
+	It("should scan segment indices once", func() {
+		batches := subject.newBatchReader()
+		_, err := batches.read(2, 3)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(batches.from).To(Equal(int64(2)))
+		Expect(batches.to).To(Equal(int64(5)))
+
+		seg := batches.seg
+		idx := seg.indices["tag"]
+		seg.indices["tag"] = nil
+		defer func() { seg.indices["tag"] = idx }()
+
+		batch, err := batches.read(3, 4)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(batch.Postings).To(Equal(map[string]column.Postings{"tag": {"b": {3}}}))
+	})
+
+	It("should limit retained index values", func() {
+		defer func(n int64) { batchReaderWindow = n }(batchReaderWindow)
+		batchReaderWindow = 2
+
+		batches := subject.newBatchReader()
+		_, err := batches.read(2, 3)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(batches.to).To(Equal(int64(4)))
+
+		batch, err := batches.read(4, 5)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(batches.from).To(Equal(int64(4)))
+		Expect(batches.to).To(Equal(int64(5)))
+		Expect(batch.Postings).To(Equal(map[string]column.Postings{"tag": {"c": {4}}}))
+
+		_, err = batches.read(2, 5)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(batches.to).To(Equal(int64(5)))
+	})
+
+	It("should convert to records", func() {
+		batch, err := subject.newBatchReader().read(2, 5)
+		Expect(err).NotTo(HaveOccurred())
+
+		recs := batch.records()
+		Expect(recs).To(HaveLen(3))
+		Expect(recs[1].ValueAt("name")).To(Equal(Value("ben")))
+		Expect(recs[1].IValuesAt("tag")).To(Equal([]Value{Value("b")}))
+		Expect(recs[2].ValueAt("name")).To(Equal(Value("carl")))
+		Expect(recs[2].IValuesAt("tag")).To(Equal([]Value{Value("c")}))
+	})
+
+})
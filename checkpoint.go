@@ -0,0 +1,250 @@
+package collie
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bsm/collie/column"
+)
+
+const (
+	checkpointFile     = "CHECKPOINT"
+	checkpointCopyRows = 10000
+)
+
+var (
+	errCheckpointExists   = errors.New("collie: checkpoint directory already exists")
+	errCheckpointWritable = errors.New("collie: checkpoints can only be opened read-only")
+)
+
+// checkpointManifest describes a checkpoint
+type checkpointManifest struct {
+	Offset  int64
+	Created time.Time
+}
+
+// snapshotter is implemented by indices supporting snapshots
+type snapshotter interface {
+	Snapshot() (column.IndexSnapshot, error)
+}
+
+// indexSnapshot is a pending index copy
+type indexSnapshot struct {
+	column.IndexSnapshot
+	typ IndexType
+	dir string
+}
+
+// fileCopy is a pending copy of the first size bytes of src
+type fileCopy struct {
+	src  *os.File
+	size int64
+	dst  string
+}
+
+// Checkpoint creates a point-in-time copy of the collection in dir and
+// returns the pinned offset. Column files of sealed segments are
+// hard-linked, dir must therefore reside on the same file system. Column
+// files of the head segment, which may still be truncated, are copied up
+// to their pinned size, checksum files are always copied. Indices are
+// copied from snapshots. Checkpoints can only be opened read-only and hold
+// all rows up to the pinned offset.
+func (c *Collection) Checkpoint(dir string) (int64, error) {
+	if _, err := os.Stat(dir); err == nil {
+		return 0, errCheckpointExists
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	c.wmux.Lock()
+	offset := c.Offset()
+	snaps, copies, err := c.linkSegments(dir)
+	c.wmux.Unlock()
+
+	defer func() {
+		for _, snap := range snaps {
+			snap.Release()
+		}
+		for _, cp := range copies {
+			cp.src.Close()
+		}
+	}()
+	if err != nil {
+		return offset, err
+	}
+
+	for _, cp := range copies {
+		if err := copyFile(cp.src, cp.size, cp.dst); err != nil {
+			return offset, err
+		}
+	}
+	for _, snap := range snaps {
+		if err := copyIndex(snap, offset); err != nil {
+			return offset, err
+		}
+	}
+
+	if err := writeSchema(dir, c.schema); err != nil {
+		return offset, err
+	}
+	if err := writeOffsetFile(dir, offset); err != nil {
+		return offset, err
+	}
+	data, err := json.Marshal(&checkpointManifest{Offset: offset, Created: time.Now()})
+	if err != nil {
+		return offset, err
+	}
+	return offset, ioutil.WriteFile(filepath.Join(dir, checkpointFile), data, 0644)
+}
+
+// linkSegments hard-links the column files of all sealed segments into
+// dir, copies all checksum files and pins the column files of the head
+// segment for copying. It also takes snapshots of all indices. Must only
+// be called while holding the write lock.
+func (c *Collection) linkSegments(dir string) (snaps []indexSnapshot, copies []fileCopy, err error) {
+	c.smux.RLock()
+	defer c.smux.RUnlock()
+
+	if len(c.segs) == 0 {
+		return nil, nil, ErrClosed
+	}
+
+	for i, seg := range c.segs {
+		target := dir
+		if seg.dir != c.dir {
+			target = filepath.Join(dir, filepath.Base(seg.dir))
+		}
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return snaps, copies, err
+		}
+
+		if data, err := ioutil.ReadFile(filepath.Join(seg.dir, segmentCreatedFile)); err == nil {
+			if err := ioutil.WriteFile(filepath.Join(target, segmentCreatedFile), data, 0644); err != nil {
+				return snaps, copies, err
+			}
+		} else if !os.IsNotExist(err) {
+			return snaps, copies, err
+		}
+
+		sealed := i < len(c.segs)-1
+		for name := range seg.columns {
+			for _, ext := range []string{".cc", ".cc.index"} {
+				src, dst := filepath.Join(seg.dir, name+ext), filepath.Join(target, name+ext)
+				if cp, err := pinFile(src+".crc", dst+".crc"); err != nil {
+					return snaps, copies, err
+				} else if cp != nil {
+					err := copyFile(cp.src, cp.size, cp.dst)
+					cp.src.Close()
+					if err != nil {
+						return snaps, copies, err
+					}
+				}
+
+				if sealed {
+					if err := os.Link(src, dst); err != nil && !os.IsNotExist(err) {
+						return snaps, copies, err
+					}
+				} else if cp, err := pinFile(src, dst); err != nil {
+					return snaps, copies, err
+				} else if cp != nil {
+					copies = append(copies, *cp)
+				}
+			}
+		}
+
+		for name, idx := range seg.indices {
+			s, ok := idx.(snapshotter)
+			if !ok {
+				return snaps, copies, errors.New("collie: index '" + name + "' does not support snapshots")
+			}
+			snap, err := s.Snapshot()
+			if err != nil {
+				return snaps, copies, err
+			}
+			col, _ := c.schema.column(name)
+			snaps = append(snaps, indexSnapshot{IndexSnapshot: snap, typ: col.Index, dir: filepath.Join(target, name+".ci")})
+		}
+	}
+	return snaps, copies, nil
+}
+
+// pinFile opens src and pins its current size for copying to dst.
+// Returns nil if src does not exist.
+func pinFile(src, dst string) (*fileCopy, error) {
+	file, err := os.Open(src)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &fileCopy{src: file, size: info.Size(), dst: dst}, nil
+}
+
+// copyFile copies the first size bytes of src to a new file at dst
+func copyFile(src *os.File, size int64, dst string) (err error) {
+	file, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0664)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := file.Close(); e != nil && err == nil {
+			err = e
+		}
+	}()
+
+	_, err = io.Copy(file, io.NewSectionReader(src, 0, size))
+	return err
+}
+
+// copyIndex copies all postings below offset from snap into a new index
+func copyIndex(snap indexSnapshot, offset int64) (err error) {
+	dst, err := openIndex(snap.typ, snap.dir, false)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := dst.Close(); e != nil && err == nil {
+			err = e
+		}
+	}()
+
+	iter := snap.Iterate(nil)
+	defer iter.Release()
+
+	p, n := make(column.Postings), 0
+	for iter.Next() {
+		for _, off := range iter.Offsets() {
+			if off < offset {
+				p[string(iter.Value())] = append(p[string(iter.Value())], off)
+				n++
+			}
+		}
+		if n >= checkpointCopyRows {
+			if err := dst.Write(p); err != nil {
+				return err
+			}
+			p, n = make(column.Postings), 0
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return dst.Write(p)
+}
+
+// isCheckpoint returns true if dir holds a checkpoint
+func isCheckpoint(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, checkpointFile))
+	return err == nil
+}
@@ -0,0 +1,175 @@
+package collie
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Checkpoint", func() {
+	var subject *Collection
+	var schema *Schema
+	var dir string
+
+	add := func(names ...string) error {
+		txn := subject.Begin(len(names))
+		for _, name := range names {
+			txn.Add(testRecord{"name": Value(name), "tag": Value(name[:1]), "code": Value(name[:2])})
+		}
+		_, err := txn.Commit()
+		return err
+	}
+
+	open := func() *Collection {
+		coll, err := OpenCollectionWithOptions(dir, schema, &Options{ReadOnly: true})
+		Expect(err).NotTo(HaveOccurred())
+		return coll
+	}
+
+	BeforeEach(func() {
+		schema = CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "code", Size: 2},
+			{Name: "tag", Index: IndexTypeHash, NoData: true},
+		})
+		dir = filepath.Join(testDir, "checkpoint")
+
+		var err error
+		subject, err = OpenCollection(filepath.Join(testDir, "source"), schema)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(add("alice", "bob", "anna")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should create checkpoints", func() {
+		Expect(subject.Checkpoint(dir)).To(Equal(int64(3)))
+		Expect(add("amber")).To(Succeed())
+
+		checkpoint := open()
+		defer checkpoint.Close()
+
+		Expect(checkpoint.Offset()).To(Equal(int64(3)))
+		Expect(checkpoint.Value("name", 2)).To(Equal([]byte("anna")))
+		Expect(checkpoint.Value("code", 1)).To(Equal([]byte("bo")))
+		_, err := checkpoint.Value("name", 3)
+		Expect(err).To(Equal(ErrNotFound))
+		_, err = checkpoint.Rows([]int64{2, 3})
+		Expect(err).To(Equal(ErrNotFound))
+		Expect(checkpoint.Offsets("tag", []byte("a"))).To(Equal([]int64{0, 2}))
+
+		Expect(checkpoint.Refresh()).To(Succeed())
+		Expect(checkpoint.Offset()).To(Equal(int64(3)))
+	})
+
+	It("should checkpoint memory indices", func() {
+		Expect(subject.Close()).To(Succeed())
+
+		schema = CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "code", Size: 2, Index: IndexTypeMemory},
+			{Name: "tag", Index: testIndexTypeCounted, NoData: true},
+		})
+
+		var err error
+		subject, err = OpenCollection(filepath.Join(testDir, "memory"), schema)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(add("alice", "bob", "anna")).To(Succeed())
+		Expect(subject.Checkpoint(dir)).To(Equal(int64(3)))
+		Expect(add("amber")).To(Succeed())
+
+		checkpoint := open()
+		defer checkpoint.Close()
+
+		Expect(checkpoint.Offsets("code", []byte("an"))).To(Equal([]int64{2}))
+		Expect(checkpoint.Offsets("tag", []byte("a"))).To(Equal([]int64{0, 2}))
+	})
+
+	It("should copy column files of the head segment", func() {
+		Expect(subject.Checkpoint(dir)).To(Equal(int64(3)))
+		crc, err := ioutil.ReadFile(filepath.Join(dir, "name.cc.crc"))
+		Expect(err).NotTo(HaveOccurred())
+
+		src, err := os.Stat(filepath.Join(testDir, "source", "name.cc"))
+		Expect(err).NotTo(HaveOccurred())
+		dst, err := os.Stat(filepath.Join(dir, "name.cc"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.SameFile(src, dst)).To(BeFalse())
+
+		Expect(add("amber")).To(Succeed())
+		Expect(subject.head().columns["name"].Truncate(0)).To(Succeed())
+		Expect(ioutil.ReadFile(filepath.Join(dir, "name.cc.crc"))).To(Equal(crc))
+
+		checkpoint := open()
+		defer checkpoint.Close()
+
+		Expect(checkpoint.Value("name", 2)).To(Equal([]byte("anna")))
+		Expect(checkpoint.Scrub()).To(BeEmpty())
+	})
+
+	It("should hard-link column files of sealed segments", func() {
+		Expect(subject.Close()).To(Succeed())
+
+		var err error
+		subject, err = OpenCollectionWithOptions(filepath.Join(testDir, "sealed"), schema, &Options{SegmentRows: 2})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(add("alice", "bob")).To(Succeed())
+		Expect(add("anna")).To(Succeed())
+		Expect(subject.Checkpoint(dir)).To(Equal(int64(3)))
+
+		sealed, head := subject.segs[0], subject.segs[1]
+		for _, fname := range []string{"name.cc", "name.cc.crc"} {
+			src, err := os.Stat(filepath.Join(sealed.dir, fname))
+			Expect(err).NotTo(HaveOccurred())
+			dst, err := os.Stat(filepath.Join(dir, filepath.Base(sealed.dir), fname))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.SameFile(src, dst)).To(Equal(fname == "name.cc"))
+		}
+
+		src, err := os.Stat(filepath.Join(head.dir, "name.cc"))
+		Expect(err).NotTo(HaveOccurred())
+		dst, err := os.Stat(filepath.Join(dir, filepath.Base(head.dir), "name.cc"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.SameFile(src, dst)).To(BeFalse())
+	})
+
+	It("should checkpoint segmented collections", func() {
+		Expect(subject.Close()).To(Succeed())
+
+		var err error
+		subject, err = OpenCollectionWithOptions(filepath.Join(testDir, "segmented"), schema, &Options{SegmentRows: 2})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(add("alice", "bob")).To(Succeed())
+		Expect(add("anna")).To(Succeed())
+
+		Expect(subject.Checkpoint(dir)).To(Equal(int64(3)))
+		Expect(add("amber")).To(Succeed())
+		Expect(add("arnold")).To(Succeed())
+
+		checkpoint := open()
+		defer checkpoint.Close()
+
+		Expect(checkpoint.Offset()).To(Equal(int64(3)))
+		Expect(checkpoint.segs).To(HaveLen(2))
+		Expect(checkpoint.Value("name", 2)).To(Equal([]byte("anna")))
+		Expect(checkpoint.Offsets("tag", []byte("a"))).To(Equal([]int64{0, 2}))
+	})
+
+	It("should only open checkpoints read-only", func() {
+		Expect(subject.Checkpoint(dir)).To(Equal(int64(3)))
+		_, err := OpenCollection(dir, schema)
+		Expect(err).To(Equal(errCheckpointWritable))
+	})
+
+	It("should not overwrite existing directories", func() {
+		Expect(os.MkdirAll(dir, 0755)).To(Succeed())
+		_, err := subject.Checkpoint(dir)
+		Expect(err).To(Equal(errCheckpointExists))
+	})
+
+})
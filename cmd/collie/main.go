@@ -0,0 +1,264 @@
+// Command collie inspects collection directories.
+//
+// Usage:
+//
+//	collie schema DIR
+//	collie info DIR
+//	collie dump [-from N] [-to N] [-columns a,b] [-format csv|jsonl] [-hex a,b] DIR
+//	collie lookup [-prefix] [-hex] DIR INDEX VALUE
+//	collie verify [-repair] [-scrub] DIR
+//
+// Collections are opened read-only, using the schema stored in DIR.
+// Repairs require exclusive write access.
+// Lookups require index access and fail while another process writes
+// to the collection.
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bsm/collie"
+)
+
+var errUsage = errors.New("usage: collie schema|info|dump|lookup|verify [flags] DIR [args]")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err == errUsage || err == flag.ErrHelp {
+		fmt.Fprintln(os.Stderr, errUsage.Error())
+		os.Exit(2)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch cmd, args := args[0], args[1:]; cmd {
+	case "schema":
+		return runSchema(args, w)
+	case "info":
+		return runInfo(args, w)
+	case "dump":
+		return runDump(args, w)
+	case "lookup":
+		return runLookup(args, w)
+	case "verify":
+		return runVerify(args, w)
+	}
+	return errUsage
+}
+
+func runSchema(args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	schema, err := collie.ReadSchema(args[0])
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func runInfo(args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	coll, err := open(args[0], true)
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+
+	fmt.Fprintf(w, "offset:\t%d\n", coll.Offset())
+	fmt.Fprintf(w, "first offset:\t%d\n", coll.FirstOffset())
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEGMENT\tCREATED\tCOLUMN\tLEN\tSIZE")
+	for _, seg := range coll.Segments() {
+		for _, col := range seg.Columns {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\n", seg.Base, seg.Created.Format(time.RFC3339), col.Name, col.Len, col.Size)
+		}
+	}
+	return tw.Flush()
+}
+
+func runDump(args []string, w io.Writer) error {
+	var opts collie.ExportOptions
+	var columns, hexCols string
+
+	flags := flag.NewFlagSet("dump", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	flags.Int64Var(&opts.From, "from", 0, "first offset")
+	flags.Int64Var(&opts.To, "to", 0, "offset limit, defaults to the current offset")
+	flags.StringVar(&columns, "columns", "", "comma-separated columns, defaults to all data columns")
+	flags.StringVar(&hexCols, "hex", "", "comma-separated columns to hex-encode")
+	format := flags.String("format", "csv", "output format, csv or jsonl")
+	if err := flags.Parse(args); err != nil {
+		return err
+	} else if flags.NArg() != 1 {
+		return errUsage
+	}
+
+	opts.Columns = split(columns)
+	opts.Encodings = make(map[string]collie.Encoding)
+	for _, name := range split(hexCols) {
+		opts.Encodings[name] = collie.EncodingHex
+	}
+
+	coll, err := open(flags.Arg(0), true)
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+
+	switch *format {
+	case "csv":
+		return coll.ExportCSV(w, &opts)
+	case "jsonl":
+		return coll.ExportJSONL(w, &opts)
+	}
+	return errors.New("collie: unknown format '" + *format + "'")
+}
+
+func runLookup(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("lookup", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	prefix := flags.Bool("prefix", false, "look up all values starting with VALUE")
+	isHex := flags.Bool("hex", false, "VALUE is hex-encoded")
+	if err := flags.Parse(args); err != nil {
+		return err
+	} else if flags.NArg() != 3 {
+		return errUsage
+	}
+
+	value := []byte(flags.Arg(2))
+	if *isHex {
+		var err error
+		if value, err = hex.DecodeString(flags.Arg(2)); err != nil {
+			return err
+		}
+	}
+
+	coll, err := open(flags.Arg(0), false)
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+
+	var offsets []int64
+	if *prefix {
+		offsets, err = coll.OffsetsPrefix(flags.Arg(1), value)
+	} else {
+		offsets, err = coll.Offsets(flags.Arg(1), value)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, off := range offsets {
+		if _, err := fmt.Fprintln(w, off); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runVerify(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	repair := flags.Bool("repair", false, "truncate columns and prune dangling index entries")
+	scrub := flags.Bool("scrub", false, "verify the checksums of all column data")
+	if err := flags.Parse(args); err != nil {
+		return err
+	} else if flags.NArg() != 1 {
+		return errUsage
+	}
+
+	var coll *collie.Collection
+	var err error
+	if *repair {
+		var schema *collie.Schema
+		if schema, err = collie.ReadSchema(flags.Arg(0)); err == nil {
+			coll, err = collie.OpenCollection(flags.Arg(0), schema)
+		}
+	} else {
+		coll, err = open(flags.Arg(0), false)
+	}
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+
+	var issues []collie.Issue
+	if *repair {
+		issues, err = coll.Repair()
+	} else {
+		issues, err = coll.Verify()
+	}
+	if err != nil {
+		return err
+	}
+
+	// Repaired issues are reported only
+	unresolved := len(issues)
+	if *repair {
+		unresolved = 0
+	}
+
+	if *scrub {
+		corrupt, err := coll.Scrub()
+		if err != nil {
+			return err
+		}
+		issues = append(issues, corrupt...)
+		unresolved += len(corrupt)
+	}
+
+	for _, issue := range issues {
+		if _, err := fmt.Fprintln(w, issue.String()); err != nil {
+			return err
+		}
+	}
+	if unresolved != 0 {
+		return fmt.Errorf("collie: %d issues found", unresolved)
+	}
+	return nil
+}
+
+// open opens the collection in dir read-only
+func open(dir string, noIndices bool) (*collie.Collection, error) {
+	schema, err := collie.ReadSchema(dir)
+	if err != nil {
+		return nil, err
+	}
+	return collie.OpenCollectionWithOptions(dir, schema, &collie.Options{ReadOnly: true, NoIndices: noIndices})
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
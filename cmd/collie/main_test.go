@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bsm/collie"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("collie", func() {
+	var coll *collie.Collection
+
+	exec := func(args ...string) (string, error) {
+		buf := new(bytes.Buffer)
+		err := run(args, buf)
+		return buf.String(), err
+	}
+
+	BeforeEach(func() {
+		schema := collie.CreateSchema([]collie.Column{
+			{Name: "name"},
+			{Name: "code", Size: 2},
+			{Name: "tag", Index: collie.IndexTypeHash, NoData: true},
+		})
+
+		var err error
+		coll, err = collie.OpenCollection(testDir, schema)
+		Expect(err).NotTo(HaveOccurred())
+
+		txn := coll.Begin(3)
+		for _, name := range []string{"alice", "bob", "anna"} {
+			row := txn.New()
+			row.SetColumn("name", collie.Value(name))
+			row.SetColumn("code", collie.Value(name[:1]))
+			row.AddIndex("tag", collie.Value(name[:1]))
+		}
+		_, err = txn.Commit()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		coll.Close()
+	})
+
+	It("should validate usage", func() {
+		_, err := exec()
+		Expect(err).To(Equal(errUsage))
+		_, err = exec("bogus")
+		Expect(err).To(Equal(errUsage))
+		_, err = exec("info")
+		Expect(err).To(Equal(errUsage))
+	})
+
+	It("should show schemas", func() {
+		out, err := exec("schema", testDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(ContainSubstring(`"name": "tag"`))
+		Expect(out).To(ContainSubstring(`"index": "hash"`))
+	})
+
+	It("should inspect collections with unregistered index types", func() {
+		fname := filepath.Join(testDir, "SCHEMA")
+		data, err := ioutil.ReadFile(fname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.WriteFile(fname, bytes.Replace(data, []byte(`"hash"`), []byte(`"custom"`), 1), 0644)).To(Succeed())
+
+		out, err := exec("schema", testDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(ContainSubstring(`"index": "custom"`))
+
+		out, err = exec("dump", "-columns", "name", testDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal("name\nalice\nbob\nanna\n"))
+
+		_, err = exec("lookup", testDir, "tag", "a")
+		Expect(err).To(MatchError("collie: unregistered index type 'custom'"))
+	})
+
+	It("should show info", func() {
+		out, err := exec("info", testDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(ContainSubstring("offset:\t3\n"))
+		Expect(out).To(MatchRegexp(`0\s+\S+\s+name\s+3\s+36\n`))
+		Expect(out).To(MatchRegexp(`0\s+\S+\s+code\s+3\s+6\n`))
+	})
+
+	It("should dump rows", func() {
+		out, err := exec("dump", "-from", "1", "-hex", "code", testDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal("name,code\nbob,6200\nanna,6100\n"))
+
+		out, err = exec("dump", "-to", "1", "-columns", "name", "-format", "jsonl", testDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal(`{"name":"alice"}` + "\n"))
+
+		_, err = exec("dump", "-format", "xml", testDir)
+		Expect(err).To(MatchError("collie: unknown format 'xml'"))
+	})
+
+	It("should look up offsets", func() {
+		Expect(coll.Close()).To(Succeed())
+
+		out, err := exec("lookup", testDir, "tag", "a")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal("0\n2\n"))
+
+		out, err = exec("lookup", "-hex", testDir, "tag", "62")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal("1\n"))
+
+		out, err = exec("lookup", "-prefix", testDir, "tag", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal("0\n1\n2\n"))
+	})
+
+	It("should verify and repair", func() {
+		Expect(coll.Close()).To(Succeed())
+
+		out, err := exec("verify", testDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(BeEmpty())
+
+		Expect(os.Truncate(filepath.Join(testDir, "code.cc"), 4)).To(Succeed())
+		out, err = exec("verify", testDir)
+		Expect(err).To(MatchError("collie: 2 issues found"))
+		Expect(out).To(Equal("segment 0, name: 1 rows beyond the consistent prefix\nsegment 0, tag: 1 index entries reference offsets outside [0, 2)\n"))
+
+		out, err = exec("verify", "-repair", testDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(ContainSubstring("segment 0, name: 1 rows beyond the consistent prefix\n"))
+
+		out, err = exec("verify", "-scrub", testDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(BeEmpty())
+
+		file, err := os.OpenFile(filepath.Join(testDir, "name.cc"), os.O_WRONLY, 0644)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = file.WriteAt([]byte("x"), 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		out, err = exec("verify", "-scrub", testDir)
+		Expect(err).To(MatchError("collie: 1 issues found"))
+		Expect(out).To(HavePrefix("segment 0, name: collie: checksum mismatch in "))
+	})
+
+})
+
+/*************************************************************************
+ * GINKGO TEST HOOK
+ *************************************************************************/
+
+var testDir string
+
+func TestSuite(t *testing.T) {
+	BeforeEach(func() {
+		var err error
+		testDir, err = ioutil.TempDir("", "collie.cmd.test")
+		Expect(err).NotTo(HaveOccurred())
+	})
+	AfterEach(func() {
+		os.RemoveAll(testDir)
+	})
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "collie/cmd/collie")
+}
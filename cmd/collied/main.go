@@ -0,0 +1,100 @@
+// Command collied serves collections over HTTP, see package
+// github.com/bsm/collie/server for the API.
+//
+// Usage:
+//
+//	collied [-addr ADDR] [-readonly] [-batch N] [-max-rows N] [-max-body BYTES] NAME=DIR ...
+//
+// Collections are opened using the schema stored in DIR and served under
+// /NAME. Unless -readonly is given, the server requires exclusive write
+// access. Prometheus metrics are served under /metrics.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/bsm/collie"
+	"github.com/bsm/collie/server"
+)
+
+var errUsage = errors.New("usage: collied [-addr ADDR] [-readonly] [-batch N] [-max-rows N] [-max-body BYTES] NAME=DIR ...")
+
+func main() {
+	if err := run(os.Args[1:]); err == errUsage || err == flag.ErrHelp {
+		fmt.Fprintln(os.Stderr, errUsage.Error())
+		os.Exit(2)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	srv, colls, err := setup(args)
+	if err != nil {
+		return err
+	}
+	defer closeAll(colls)
+
+	return srv.ListenAndServe()
+}
+
+// setup parses args, opens the collections and creates the server
+func setup(args []string) (*http.Server, map[string]*collie.Collection, error) {
+	flags := flag.NewFlagSet("collied", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	addr := flags.String("addr", ":7070", "listen address")
+	readOnly := flags.Bool("readonly", false, "open collections read-only")
+	batch := flags.Int("batch", 0, "maximum rows per transaction, when appending")
+	maxRows := flags.Int("max-rows", 0, "maximum rows returned per request")
+	maxBody := flags.Int64("max-body", 0, "maximum request body size, in bytes")
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	} else if flags.NArg() == 0 {
+		return nil, nil, errUsage
+	}
+
+	colls := make(map[string]*collie.Collection, flags.NArg())
+	for _, arg := range flags.Args() {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[0] == "metrics" || strings.Contains(parts[0], "/") {
+			closeAll(colls)
+			return nil, nil, errors.New("collied: invalid collection '" + arg + "'")
+		} else if _, ok := colls[parts[0]]; ok {
+			closeAll(colls)
+			return nil, nil, errors.New("collied: duplicate collection '" + parts[0] + "'")
+		}
+
+		coll, err := open(parts[1], *readOnly)
+		if err != nil {
+			closeAll(colls)
+			return nil, nil, err
+		}
+		colls[parts[0]] = coll
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", server.New(colls, &server.Options{BatchRows: *batch, MaxRows: *maxRows, MaxBodyBytes: *maxBody}))
+	mux.Handle("/metrics", collie.MetricsHandler(colls, false))
+	return &http.Server{Addr: *addr, Handler: mux}, colls, nil
+}
+
+func open(dir string, readOnly bool) (*collie.Collection, error) {
+	schema, err := collie.ReadSchema(dir)
+	if err != nil {
+		return nil, err
+	}
+	return collie.OpenCollectionWithOptions(dir, schema, &collie.Options{ReadOnly: readOnly})
+}
+
+func closeAll(colls map[string]*collie.Collection) {
+	for _, coll := range colls {
+		coll.Close()
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/bsm/collie"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("collied", func() {
+
+	BeforeEach(func() {
+		coll, err := collie.OpenCollection(testDir, collie.CreateSchema([]collie.Column{
+			{Name: "name"},
+			{Name: "tag", Index: collie.IndexTypeHash, NoData: true},
+		}))
+		Expect(err).NotTo(HaveOccurred())
+
+		txn := coll.Begin(1)
+		row := txn.New()
+		row.SetColumn("name", collie.Value("alice"))
+		row.AddIndex("tag", collie.Value("a"))
+		_, err = txn.Commit()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(coll.Close()).To(Succeed())
+	})
+
+	It("should validate usage", func() {
+		_, _, err := setup(nil)
+		Expect(err).To(Equal(errUsage))
+		_, _, err = setup([]string{"-bogus"})
+		Expect(err).To(HaveOccurred())
+		_, _, err = setup([]string{testDir})
+		Expect(err).To(MatchError("collied: invalid collection '" + testDir + "'"))
+		_, _, err = setup([]string{"metrics=" + testDir})
+		Expect(err).To(MatchError("collied: invalid collection 'metrics=" + testDir + "'"))
+		_, _, err = setup([]string{"-readonly", "a=" + testDir, "a=" + testDir})
+		Expect(err).To(MatchError("collied: duplicate collection 'a'"))
+	})
+
+	It("should serve collections", func() {
+		srv, colls, err := setup([]string{"-addr", "127.0.0.1:0", "-readonly", "people=" + testDir})
+		Expect(err).NotTo(HaveOccurred())
+		defer closeAll(colls)
+		Expect(srv.Addr).To(Equal("127.0.0.1:0"))
+		Expect(colls).To(HaveKey("people"))
+
+		ts := httptest.NewServer(srv.Handler)
+		defer ts.Close()
+
+		res, err := http.Get(ts.URL + "/people/rows/0")
+		Expect(err).NotTo(HaveOccurred())
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(Equal(`{"rows":[{"offset":0,"columns":{"name":"alice"}}]}` + "\n"))
+
+		res, err = http.Get(ts.URL + "/metrics")
+		Expect(err).NotTo(HaveOccurred())
+		body, err = ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(ContainSubstring(`collie_offset{collection="people"} 1`))
+	})
+
+})
+
+var testDir string
+
+func TestSuite(t *testing.T) {
+	BeforeEach(func() {
+		var err error
+		testDir, err = ioutil.TempDir("", "collie.collied.test")
+		Expect(err).NotTo(HaveOccurred())
+	})
+	AfterEach(func() {
+		os.RemoveAll(testDir)
+	})
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "collie/cmd/collied")
+}
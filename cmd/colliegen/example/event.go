@@ -0,0 +1,22 @@
+// Package example demonstrates records generated by colliegen.
+package example
+
+import "time"
+
+//go:generate colliegen -type Event
+
+// Event is an example row type
+type Event struct {
+	ID      uint32    `collie:"id"`
+	Name    string    `collie:"name"`
+	Code    string    `collie:"code,index"`
+	Score   int16     `collie:"score"`
+	Ratio   float64   `collie:"ratio"`
+	Active  bool      `collie:"active"`
+	Created time.Time `collie:"created"`
+	Raw     []byte    `collie:"raw"`
+	Tags    []string  `collie:"tags,index"`
+	Zones   []int32   `collie:"zones,index"`
+	Level   uint8     `collie:"level,index"`
+	Skipped string    `collie:"-"`
+}
@@ -0,0 +1,275 @@
+// Code generated by colliegen. DO NOT EDIT.
+
+package example
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+
+	"github.com/bsm/collie"
+)
+
+// EventSchema returns the collection schema of Event
+func EventSchema() *collie.Schema {
+	return collie.CreateSchema([]collie.Column{
+		{Name: "id", Size: 4},
+		{Name: "name"},
+		{Name: "code", Index: collie.IndexTypeHash},
+		{Name: "score", Size: 2},
+		{Name: "ratio", Size: 8},
+		{Name: "active", Size: 1},
+		{Name: "created", Size: 8},
+		{Name: "raw"},
+		{Name: "tags", Index: collie.IndexTypeHash, NoData: true},
+		{Name: "zones", Index: collie.IndexTypeHash, NoData: true},
+		{Name: "level", Size: 1, Index: collie.IndexTypeHash},
+	})
+}
+
+// EventRecord implements collie.Record for Event. Values are encoded into buffers
+// owned by the record, without allocations once these have grown to
+// size. Returned values refer to the record, which must therefore not
+// be modified or reused until the transaction is committed.
+type EventRecord struct {
+	*Event
+	scratch [24]byte
+	bufs    [4][]byte
+	ivals   [1]collie.Value
+	mvals   [2][]collie.Value
+}
+
+// ValueAt implements collie.Record
+func (r *EventRecord) ValueAt(name string) (collie.Value, error) {
+	switch name {
+	case "id":
+		binary.BigEndian.PutUint32(r.scratch[0:4], r.Event.ID)
+		return r.scratch[0:4], nil
+	case "name":
+		r.bufs[0] = append(r.bufs[0][:0], r.Event.Name...)
+		return r.bufs[0], nil
+	case "code":
+		r.bufs[1] = append(r.bufs[1][:0], r.Event.Code...)
+		return r.bufs[1], nil
+	case "score":
+		binary.BigEndian.PutUint16(r.scratch[4:6], uint16(r.Event.Score))
+		return r.scratch[4:6], nil
+	case "ratio":
+		binary.BigEndian.PutUint64(r.scratch[6:14], math.Float64bits(r.Event.Ratio))
+		return r.scratch[6:14], nil
+	case "active":
+		r.scratch[14] = 0
+		if r.Event.Active {
+			r.scratch[14] = 1
+		}
+		return r.scratch[14:15], nil
+	case "created":
+		binary.BigEndian.PutUint64(r.scratch[15:23], uint64(r.Event.Created.Unix()))
+		return r.scratch[15:23], nil
+	case "raw":
+		return collie.Value(r.Event.Raw), nil
+	case "level":
+		r.scratch[23] = r.Event.Level
+		return r.scratch[23:24], nil
+	}
+	return nil, nil
+}
+
+// IValuesAt implements collie.Record
+func (r *EventRecord) IValuesAt(name string) ([]collie.Value, error) {
+	switch name {
+	case "code":
+		r.bufs[1] = append(r.bufs[1][:0], r.Event.Code...)
+		r.ivals[0] = r.bufs[1]
+		return r.ivals[:], nil
+	case "tags":
+		r.bufs[2] = r.bufs[2][:0]
+		for _, v := range r.Event.Tags {
+			r.bufs[2] = append(r.bufs[2], v...)
+		}
+		buf := r.bufs[2]
+		r.mvals[0] = r.mvals[0][:0]
+		for _, v := range r.Event.Tags {
+			r.mvals[0] = append(r.mvals[0], buf[:len(v):len(v)])
+			buf = buf[len(v):]
+		}
+		return r.mvals[0], nil
+	case "zones":
+		r.bufs[3] = append(r.bufs[3][:0], make([]byte, 4*len(r.Event.Zones))...)
+		r.mvals[1] = r.mvals[1][:0]
+		for i, v := range r.Event.Zones {
+			val := r.bufs[3][4*i : 4*i+4]
+			binary.BigEndian.PutUint32(val, uint32(v))
+			r.mvals[1] = append(r.mvals[1], val)
+		}
+		return r.mvals[1], nil
+	case "level":
+		r.scratch[23] = r.Event.Level
+		r.ivals[0] = r.scratch[23:24]
+		return r.ivals[:], nil
+	}
+	return nil, nil
+}
+
+// EventReader reads Event rows from a collection
+type EventReader struct {
+	coll *collie.Collection
+}
+
+// NewEventReader creates a new reader for coll
+func NewEventReader(coll *collie.Collection) *EventReader {
+	return &EventReader{coll: coll}
+}
+
+// Load reads the row at offset into v, index-only fields are skipped
+func (r *EventReader) Load(offset int64, v *Event) (err error) {
+	if v.ID, err = r.ID(offset); err != nil {
+		return
+	}
+	if v.Name, err = r.Name(offset); err != nil {
+		return
+	}
+	if v.Code, err = r.Code(offset); err != nil {
+		return
+	}
+	if v.Score, err = r.Score(offset); err != nil {
+		return
+	}
+	if v.Ratio, err = r.Ratio(offset); err != nil {
+		return
+	}
+	if v.Active, err = r.Active(offset); err != nil {
+		return
+	}
+	if v.Created, err = r.Created(offset); err != nil {
+		return
+	}
+	if v.Raw, err = r.Raw(offset); err != nil {
+		return
+	}
+	if v.Level, err = r.Level(offset); err != nil {
+		return
+	}
+	return
+}
+
+// ID reads the 'id' column at offset
+func (r *EventReader) ID(offset int64) (uint32, error) {
+	val, err := r.coll.Value("id", offset)
+	if err != nil {
+		return 0, err
+	} else if len(val) < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return binary.BigEndian.Uint32(val), nil
+}
+
+// Name reads the 'name' column at offset
+func (r *EventReader) Name(offset int64) (string, error) {
+	val, err := r.coll.Value("name", offset)
+	if err != nil {
+		return "", err
+	}
+	return string(val), nil
+}
+
+// Code reads the 'code' column at offset
+func (r *EventReader) Code(offset int64) (string, error) {
+	val, err := r.coll.Value("code", offset)
+	if err != nil {
+		return "", err
+	}
+	return string(val), nil
+}
+
+// OffsetsByCode returns the offsets of all rows indexed by v
+func (r *EventReader) OffsetsByCode(v string) ([]int64, error) {
+	return r.coll.Offsets("code", collie.Value(v))
+}
+
+// Score reads the 'score' column at offset
+func (r *EventReader) Score(offset int64) (int16, error) {
+	val, err := r.coll.Value("score", offset)
+	if err != nil {
+		return 0, err
+	} else if len(val) < 2 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return int16(binary.BigEndian.Uint16(val)), nil
+}
+
+// Ratio reads the 'ratio' column at offset
+func (r *EventReader) Ratio(offset int64) (float64, error) {
+	val, err := r.coll.Value("ratio", offset)
+	if err != nil {
+		return 0, err
+	} else if len(val) < 8 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(val)), nil
+}
+
+// Active reads the 'active' column at offset
+func (r *EventReader) Active(offset int64) (bool, error) {
+	val, err := r.coll.Value("active", offset)
+	if err != nil {
+		return false, err
+	} else if len(val) < 1 {
+		return false, io.ErrUnexpectedEOF
+	}
+	return val[0] != 0, nil
+}
+
+// Created reads the 'created' column at offset
+//
+// Times are stored as Unix seconds, sub-second precision and
+// locations are dropped, the result is in local time.
+func (r *EventReader) Created(offset int64) (time.Time, error) {
+	val, err := r.coll.Value("created", offset)
+	if err != nil {
+		return time.Time{}, err
+	} else if len(val) < 8 {
+		return time.Time{}, io.ErrUnexpectedEOF
+	}
+	return time.Unix(int64(binary.BigEndian.Uint64(val)), 0), nil
+}
+
+// Raw reads the 'raw' column at offset
+func (r *EventReader) Raw(offset int64) ([]byte, error) {
+	val, err := r.coll.Value("raw", offset)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(val), nil
+}
+
+// OffsetsByTags returns the offsets of all rows indexed by v
+func (r *EventReader) OffsetsByTags(v string) ([]int64, error) {
+	return r.coll.Offsets("tags", collie.Value(v))
+}
+
+// OffsetsByZones returns the offsets of all rows indexed by v
+func (r *EventReader) OffsetsByZones(v int32) ([]int64, error) {
+	buf := make(collie.Value, 4)
+	binary.BigEndian.PutUint32(buf, uint32(v))
+	return r.coll.Offsets("zones", buf)
+}
+
+// Level reads the 'level' column at offset
+func (r *EventReader) Level(offset int64) (uint8, error) {
+	val, err := r.coll.Value("level", offset)
+	if err != nil {
+		return 0, err
+	} else if len(val) < 1 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return val[0], nil
+}
+
+// OffsetsByLevel returns the offsets of all rows indexed by v
+func (r *EventReader) OffsetsByLevel(v uint8) ([]int64, error) {
+	buf := make(collie.Value, 1)
+	buf[0] = v
+	return r.coll.Offsets("level", buf)
+}
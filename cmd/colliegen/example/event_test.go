@@ -0,0 +1,117 @@
+package example
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bsm/collie"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Event", func() {
+	var subject *collie.Collection
+	var event = Event{
+		ID:      7,
+		Name:    "alice",
+		Code:    "ab",
+		Score:   -3,
+		Ratio:   0.25,
+		Active:  true,
+		Created: time.Unix(1400000000, 0),
+		Raw:     []byte{0, 1},
+		Tags:    []string{"x", "y"},
+		Zones:   []int32{-1, 2},
+		Level:   3,
+	}
+
+	BeforeEach(func() {
+		var err error
+		subject, err = collie.OpenCollection(testDir, EventSchema())
+		Expect(err).NotTo(HaveOccurred())
+
+		txn := subject.Begin(2)
+		txn.Add(&EventRecord{Event: &event})
+		Expect(txn.AddStruct(&Event{Name: "bob", Code: "cd", Tags: []string{"y"}, Zones: []int32{2}})).To(Succeed())
+		_, err = txn.Commit()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should encode like AddStruct", func() {
+		Expect(subject.Value("id", 0)).To(Equal([]byte{0, 0, 0, 7}))
+		Expect(subject.Value("score", 0)).To(Equal([]byte{0xff, 0xfd}))
+		Expect(subject.Value("active", 0)).To(Equal([]byte{1}))
+		Expect(subject.Value("created", 0)).To(Equal([]byte{0, 0, 0, 0, 0x53, 0x72, 0x4e, 0x00}))
+
+		var loaded Event
+		Expect(subject.Load(0, &loaded)).To(Succeed())
+		Expect(loaded.Name).To(Equal("alice"))
+		Expect(loaded.Ratio).To(Equal(0.25))
+		Expect(loaded.Created).To(Equal(event.Created))
+	})
+
+	It("should read typed values", func() {
+		reader := NewEventReader(subject)
+		Expect(reader.Score(0)).To(Equal(int16(-3)))
+		Expect(reader.Name(1)).To(Equal("bob"))
+		Expect(reader.Active(1)).To(BeFalse())
+
+		var loaded Event
+		Expect(reader.Load(0, &loaded)).To(Succeed())
+		expected := event
+		expected.Tags, expected.Zones = nil, nil
+		Expect(loaded).To(Equal(expected))
+
+		_, err := reader.ID(2)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should look up offsets", func() {
+		reader := NewEventReader(subject)
+		Expect(reader.OffsetsByCode("cd")).To(Equal([]int64{1}))
+		Expect(reader.OffsetsByTags("y")).To(Equal([]int64{0, 1}))
+		Expect(reader.OffsetsByZones(-1)).To(Equal([]int64{0}))
+		Expect(reader.OffsetsByZones(2)).To(Equal([]int64{0, 1}))
+		Expect(reader.OffsetsByZones(3)).To(BeEmpty())
+		Expect(reader.OffsetsByLevel(3)).To(Equal([]int64{0}))
+		Expect(reader.OffsetsByLevel(0)).To(Equal([]int64{1}))
+	})
+
+	It("should not allocate values", func() {
+		rec := &EventRecord{Event: &event}
+		allocs := testing.AllocsPerRun(100, func() {
+			for _, name := range []string{"id", "name", "code", "score", "ratio", "active", "created", "raw", "level"} {
+				rec.ValueAt(name)
+			}
+			for _, name := range []string{"code", "tags", "zones", "level"} {
+				rec.IValuesAt(name)
+			}
+		})
+		Expect(allocs).To(BeZero())
+
+		Expect(rec.ValueAt("name")).To(Equal(collie.Value("alice")))
+		Expect(rec.IValuesAt("tags")).To(Equal([]collie.Value{collie.Value("x"), collie.Value("y")}))
+		Expect(rec.IValuesAt("zones")).To(Equal([]collie.Value{{0xff, 0xff, 0xff, 0xff}, {0, 0, 0, 2}}))
+	})
+})
+
+var testDir string
+
+func TestSuite(t *testing.T) {
+	BeforeEach(func() {
+		var err error
+		testDir, err = ioutil.TempDir("", "collie.colliegen.test")
+		Expect(err).NotTo(HaveOccurred())
+	})
+	AfterEach(func() {
+		os.RemoveAll(testDir)
+	})
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "collie/cmd/colliegen/example")
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+
+	"github.com/bsm/collie"
+)
+
+// goTypes maps field kinds to Go types
+var goTypes = map[string]string{
+	"string": "string", "bytes": "[]byte", "bool": "bool", "time": "time.Time",
+	"int": "int", "int8": "int8", "int16": "int16", "int32": "int32", "int64": "int64",
+	"uint": "uint", "uint8": "uint8", "uint16": "uint16", "uint32": "uint32", "uint64": "uint64",
+	"float32": "float32", "float64": "float64",
+}
+
+// generator writes Go source, tracking the required imports
+type generator struct {
+	bytes.Buffer
+	imports map[string]bool
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.Buffer, format, args...)
+}
+
+func (g *generator) use(pkg string) { g.imports[pkg] = true }
+
+// generate generates the accessors of spec
+func generate(spec *typeSpec) ([]byte, error) {
+	g := &generator{imports: map[string]bool{"github.com/bsm/collie": true}}
+	if spec.Declare {
+		g.genStruct(spec)
+	}
+	if err := g.genSchema(spec); err != nil {
+		return nil, err
+	}
+	g.genRecord(spec)
+	g.genReader(spec)
+
+	var imports []string
+	for pkg := range g.imports {
+		imports = append(imports, pkg)
+	}
+	sort.Strings(imports)
+
+	src := new(bytes.Buffer)
+	fmt.Fprintf(src, "// Code generated by colliegen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", spec.Package)
+	for _, pkg := range imports {
+		if !strings.Contains(pkg, ".") {
+			fmt.Fprintf(src, "%q\n", pkg)
+		}
+	}
+	fmt.Fprintf(src, "\n")
+	for _, pkg := range imports {
+		if strings.Contains(pkg, ".") {
+			fmt.Fprintf(src, "%q\n", pkg)
+		}
+	}
+	fmt.Fprintf(src, ")\n")
+	src.Write(g.Bytes())
+	return format.Source(src.Bytes())
+}
+
+func (g *generator) genStruct(spec *typeSpec) {
+	g.printf("\n// %s is a row of the collection\ntype %s struct {\n", spec.Name, spec.Name)
+	for _, f := range spec.Fields {
+		typ, tag := g.goType(f.Kind), f.Column
+		if f.Multi {
+			typ = "[]" + typ
+		}
+		if f.Index {
+			tag += ",index"
+		}
+		g.printf("%s %s `collie:%q`\n", f.Name, typ, tag)
+	}
+	g.printf("}\n")
+}
+
+// indexTypeIdents maps built-in index types to their identifiers, other
+// index types are not supported
+var indexTypeIdents = map[collie.IndexType]string{
+	collie.IndexTypeHash:   "IndexTypeHash",
+	collie.IndexTypeMemory: "IndexTypeMemory",
+}
+
+func (g *generator) genSchema(spec *typeSpec) error {
+	g.printf("\n// %sSchema returns the collection schema of %s\n", spec.Name, spec.Name)
+	g.printf("func %sSchema() *collie.Schema {\nreturn collie.CreateSchema([]collie.Column{\n", spec.Name)
+	for _, col := range spec.Columns() {
+		opts := []string{fmt.Sprintf("Name: %q", col.Name)}
+		if col.Size > 0 {
+			opts = append(opts, fmt.Sprintf("Size: %d", col.Size))
+		}
+		if ident, ok := indexTypeIdents[col.Index]; ok {
+			opts = append(opts, "Index: collie."+ident)
+		} else if col.Index != collie.IndexTypeNone {
+			return errors.New("colliegen: unsupported index type '" + col.Index.String() + "' of column '" + col.Name + "'")
+		}
+		if col.NoData {
+			opts = append(opts, "NoData: true")
+		}
+		g.printf("{%s},\n", strings.Join(opts, ", "))
+	}
+	g.printf("})\n}\n")
+	return nil
+}
+
+func (g *generator) genRecord(spec *typeSpec) {
+	// Assign scratch space to fixed-size fields, buffers to strings and
+	// multi-valued indices
+	offsets, size := make(map[string]int), 0
+	bufs, mvals := make(map[string]int), make(map[string]int)
+	for _, f := range spec.Fields {
+		if n := kindSizes[f.Kind]; n > 0 && !f.Multi {
+			offsets[f.Name] = size
+			size += n
+		}
+		if f.Multi {
+			mvals[f.Name] = len(mvals)
+		}
+		if f.Kind != "bytes" && (f.Kind == "string" || f.Multi) {
+			bufs[f.Name] = len(bufs)
+		}
+	}
+
+	name := spec.Name + "Record"
+	g.printf(`
+// %s implements collie.Record for %s. Values are encoded into buffers
+// owned by the record, without allocations once these have grown to
+// size. Returned values refer to the record, which must therefore not
+// be modified or reused until the transaction is committed.
+type %s struct {
+	*%s
+	scratch [%d]byte
+	bufs    [%d][]byte
+	ivals   [1]collie.Value
+	mvals   [%d][]collie.Value
+}
+`, name, spec.Name, name, spec.Name, size, len(bufs), len(mvals))
+
+	// value returns the encoded value of a non-multi field
+	value := func(f fieldSpec) string {
+		expr := "r." + spec.Name + "." + f.Name
+		if f.Kind == "bytes" {
+			return "collie.Value(" + expr + ")"
+		} else if f.Kind == "string" {
+			buf := fmt.Sprintf("r.bufs[%d]", bufs[f.Name])
+			g.printf("%s = append(%s[:0], %s...)\n", buf, buf, expr)
+			return buf
+		}
+
+		off, n := offsets[f.Name], kindSizes[f.Kind]
+		g.printf("%s\n", g.encode(f.Kind, fmt.Sprintf("r.scratch[%d:%d]", off, off+n), fmt.Sprintf("r.scratch[%d]", off), expr))
+		return fmt.Sprintf("r.scratch[%d:%d]", off, off+n)
+	}
+
+	g.printf("\n// ValueAt implements collie.Record\nfunc (r *%s) ValueAt(name string) (collie.Value, error) {\nswitch name {\n", name)
+	for _, f := range spec.Fields {
+		if !f.Multi {
+			g.printf("case %q:\n", f.Column)
+			g.printf("return %s, nil\n", value(f))
+		}
+	}
+	g.printf("}\nreturn nil, nil\n}\n")
+
+	g.printf("\n// IValuesAt implements collie.Record\nfunc (r *%s) IValuesAt(name string) ([]collie.Value, error) {\nswitch name {\n", name)
+	for _, f := range spec.Fields {
+		if !f.Index {
+			continue
+		}
+
+		g.printf("case %q:\n", f.Column)
+		if !f.Multi {
+			g.printf("r.ivals[0] = %s\nreturn r.ivals[:], nil\n", value(f))
+			continue
+		}
+
+		expr := "r." + spec.Name + "." + f.Name
+		vals := fmt.Sprintf("r.mvals[%d]", mvals[f.Name])
+		buf := fmt.Sprintf("r.bufs[%d]", bufs[f.Name])
+		switch n := kindSizes[f.Kind]; {
+		case f.Kind == "bytes":
+			g.printf("%s = %s[:0]\nfor _, v := range %s {\n%s = append(%s, v)\n}\n", vals, vals, expr, vals, vals)
+		case n == 0:
+			g.printf("%s = %s[:0]\nfor _, v := range %s {\n%s = append(%s, v...)\n}\n", buf, buf, expr, buf, buf)
+			g.printf("buf := %s\n%s = %s[:0]\nfor _, v := range %s {\n", buf, vals, vals, expr)
+			g.printf("%s = append(%s, buf[:len(v):len(v)])\nbuf = buf[len(v):]\n}\n", vals, vals)
+		default:
+			g.printf("%s = append(%s[:0], make([]byte, %d*len(%s))...)\n", buf, buf, n, expr)
+			g.printf("%s = %s[:0]\nfor i, v := range %s {\nval := %s[%d*i : %d*i+%d]\n%s\n%s = append(%s, val)\n}\n",
+				vals, vals, expr, buf, n, n, n, g.encode(f.Kind, "val", "val[0]", "v"), vals, vals)
+		}
+		g.printf("return %s, nil\n", vals)
+	}
+	g.printf("}\nreturn nil, nil\n}\n")
+}
+
+func (g *generator) genReader(spec *typeSpec) {
+	name := spec.Name + "Reader"
+	g.printf(`
+// %s reads %s rows from a collection
+type %s struct {
+	coll *collie.Collection
+}
+
+// New%s creates a new reader for coll
+func New%s(coll *collie.Collection) *%s {
+	return &%s{coll: coll}
+}
+`, name, spec.Name, name, name, name, name, name)
+
+	g.printf("\n// Load reads the row at offset into v, index-only fields are skipped\n")
+	g.printf("func (r *%s) Load(offset int64, v *%s) (err error) {\n", name, spec.Name)
+	for _, f := range spec.Fields {
+		if !f.Multi {
+			g.printf("if v.%s, err = r.%s(offset); err != nil {\nreturn\n}\n", f.Name, f.Name)
+		}
+	}
+	g.printf("return\n}\n")
+
+	for _, f := range spec.Fields {
+		typ := g.goType(f.Kind)
+		if !f.Multi {
+			g.printf("\n// %s reads the '%s' column at offset\n", f.Name, f.Column)
+			if f.Kind == "time" {
+				g.printf("//\n// Times are stored as Unix seconds, sub-second precision and\n// locations are dropped, the result is in local time.\n")
+			}
+			g.printf("func (r *%s) %s(offset int64) (%s, error) {\n", name, f.Name, typ)
+			g.printf("val, err := r.coll.Value(%q, offset)\nif err != nil {\nreturn %s, err\n}", f.Column, zeros[f.Kind])
+			if n := kindSizes[f.Kind]; n > 0 {
+				g.use("io")
+				g.printf(" else if len(val) < %d {\nreturn %s, io.ErrUnexpectedEOF\n}", n, zeros[f.Kind])
+			}
+			g.printf("\nreturn %s, nil\n}\n", g.decode(f.Kind, "val"))
+		}
+
+		if f.Index {
+			g.printf("\n// OffsetsBy%s returns the offsets of all rows indexed by v\n", f.Name)
+			g.printf("func (r *%s) OffsetsBy%s(v %s) ([]int64, error) {\n", name, f.Name, typ)
+			if n := kindSizes[f.Kind]; n == 0 {
+				g.printf("return r.coll.Offsets(%q, collie.Value(v))\n}\n", f.Column)
+			} else {
+				g.printf("buf := make(collie.Value, %d)\n%s\n", n, g.encode(f.Kind, "buf", "buf[0]", "v"))
+				g.printf("return r.coll.Offsets(%q, buf)\n}\n", f.Column)
+			}
+		}
+	}
+}
+
+// zeros maps kinds to zero value literals
+var zeros = map[string]string{
+	"string": `""`, "bytes": "nil", "bool": "false", "time": "time.Time{}",
+}
+
+func init() {
+	for kind := range kindSizes {
+		if _, ok := zeros[kind]; !ok {
+			zeros[kind] = "0"
+		}
+	}
+}
+
+func (g *generator) goType(kind string) string {
+	if kind == "time" {
+		g.use("time")
+	}
+	return goTypes[kind]
+}
+
+// encode returns a statement, encoding expr into buf, the first byte of
+// which is addressed by elem
+func (g *generator) encode(kind, buf, elem, expr string) string {
+	bits := 8 * kindSizes[kind]
+	switch kind {
+	case "bool":
+		return fmt.Sprintf("%s = 0\nif %s {\n%s = 1\n}", elem, expr, elem)
+	case "uint8":
+		return fmt.Sprintf("%s = %s", elem, expr)
+	case "int8":
+		return fmt.Sprintf("%s = byte(%s)", elem, expr)
+	case "float32":
+		g.use("math")
+		expr = "math.Float32bits(" + expr + ")"
+	case "float64":
+		g.use("math")
+		expr = "math.Float64bits(" + expr + ")"
+	case "uint16", "uint32", "uint64":
+	case "time":
+		expr = fmt.Sprintf("uint64(%s.Unix())", expr)
+	default:
+		expr = fmt.Sprintf("uint%d(%s)", bits, expr)
+	}
+
+	g.use("encoding/binary")
+	return fmt.Sprintf("binary.BigEndian.PutUint%d(%s, %s)", bits, buf, expr)
+}
+
+// decode returns an expression, decoding val
+func (g *generator) decode(kind, val string) string {
+	switch kind {
+	case "string":
+		return "string(" + val + ")"
+	case "bytes":
+		return "[]byte(" + val + ")"
+	case "bool":
+		return val + "[0] != 0"
+	case "int8":
+		return "int8(" + val + "[0])"
+	case "uint8":
+		return val + "[0]"
+	}
+
+	g.use("encoding/binary")
+	bits := 8 * kindSizes[kind]
+	raw := fmt.Sprintf("binary.BigEndian.Uint%d(%s)", bits, val)
+	switch kind {
+	case "float32":
+		g.use("math")
+		return "math.Float32frombits(" + raw + ")"
+	case "float64":
+		g.use("math")
+		return "math.Float64frombits(" + raw + ")"
+	case "time":
+		return "time.Unix(int64(" + raw + "), 0)"
+	case "uint16", "uint32", "uint64":
+		return raw
+	}
+	return kind + "(" + raw + ")"
+}
@@ -0,0 +1,305 @@
+// Command colliegen generates typed record accessors for collie
+// collections, avoiding the reflection overhead of Txn.AddStruct and
+// Collection.Load.
+//
+// Usage:
+//
+//	colliegen -type T [-schema FILE] [-output FILE] [DIR]
+//
+// By default, colliegen reads the struct type T from the Go package in DIR
+// (defaults to the current directory) and maps its fields to columns, using
+// the same `collie:"name,index"` tags as Txn.AddStruct. Alternatively, a
+// struct type T can be generated from a schema manifest FILE, as stored in
+// the SCHEMA file of a collection directory. It is typically invoked via
+//
+//	//go:generate colliegen -type Event
+//
+// and writes t_collie.go, containing:
+//
+//	func TSchema() *collie.Schema               // the schema
+//	type TRecord struct { *T }                  // a collie.Record
+//	type TReader struct { ... }                 // typed column readers
+//	func NewTReader(*collie.Collection) *TReader
+//
+// Fields of type time.Time are stored as 8-byte Unix seconds, dropping
+// sub-second precision and locations. Readers return local times.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/bsm/collie"
+)
+
+var errUsage = errors.New("usage: colliegen -type T [-schema FILE] [-output FILE] [DIR]")
+
+func main() {
+	if err := run(os.Args[1:]); err == errUsage || err == flag.ErrHelp {
+		fmt.Fprintln(os.Stderr, errUsage.Error())
+		os.Exit(2)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("colliegen", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	typeName := flags.String("type", "", "struct type name")
+	schemaFile := flags.String("schema", "", "generate the struct type from a schema manifest")
+	output := flags.String("output", "", "output file, defaults to DIR/<type>_collie.go")
+	if err := flags.Parse(args); err != nil {
+		return err
+	} else if *typeName == "" || flags.NArg() > 1 {
+		return errUsage
+	}
+
+	dir := "."
+	if flags.NArg() == 1 {
+		dir = flags.Arg(0)
+	}
+	if *output == "" {
+		*output = filepath.Join(dir, strings.ToLower(*typeName)+"_collie.go")
+	}
+
+	var spec *typeSpec
+	var err error
+	if *schemaFile != "" {
+		spec, err = readManifest(*schemaFile, *typeName, packageName(dir))
+	} else {
+		spec, err = parseStruct(dir, *typeName, filepath.Base(*output))
+	}
+	if err != nil {
+		return err
+	}
+
+	src, err := generate(spec)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(*output, src, 0644)
+}
+
+// typeSpec describes a struct type
+type typeSpec struct {
+	Package string
+	Name    string
+	Fields  []fieldSpec
+	// Declare the struct type, when generated from a manifest
+	Declare bool
+}
+
+// fieldSpec describes a struct field and its column
+type fieldSpec struct {
+	Name   string
+	Column string
+	Kind   string
+	Size   int
+	Index  bool
+	Multi  bool
+	// IndexType overrides the default hash index type
+	IndexType collie.IndexType
+}
+
+// kindSizes maps supported field kinds to their encoded sizes,
+// variable-length kinds have a size of 0
+var kindSizes = map[string]int{
+	"string": 0, "bytes": 0, "bool": 1, "time": 8,
+	"int": 8, "int8": 1, "int16": 2, "int32": 4, "int64": 8,
+	"uint": 8, "uint8": 1, "uint16": 2, "uint32": 4, "uint64": 8,
+	"float32": 4, "float64": 8,
+}
+
+// Columns returns the schema columns
+func (t *typeSpec) Columns() []collie.Column {
+	cols := make([]collie.Column, 0, len(t.Fields))
+	for _, f := range t.Fields {
+		col := collie.Column{Name: f.Column, Size: f.Size, Index: f.IndexType, NoData: f.Multi}
+		if f.Index && col.Index == collie.IndexTypeNone {
+			col.Index = collie.IndexTypeHash
+		}
+		cols = append(cols, col)
+	}
+	return cols
+}
+
+// parseStruct parses the struct type name from the package in dir,
+// skipping test files and the output file
+func parseStruct(dir, name, output string) (*typeSpec, error) {
+	filter := func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != output
+	}
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, filter, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+				for _, s := range gen.Specs {
+					ts := s.(*ast.TypeSpec)
+					if ts.Name.Name != name {
+						continue
+					}
+					st, ok := ts.Type.(*ast.StructType)
+					if !ok {
+						return nil, errors.New("colliegen: type " + name + " is not a struct")
+					}
+					return parseFields(pkg.Name, name, st)
+				}
+			}
+		}
+	}
+	return nil, errors.New("colliegen: type " + name + " not found")
+}
+
+func parseFields(pkg, name string, st *ast.StructType) (*typeSpec, error) {
+	spec := &typeSpec{Package: pkg, Name: name}
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 {
+			return nil, errors.New("colliegen: embedded fields are not supported")
+		}
+
+		var tag string
+		if field.Tag != nil {
+			lit, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(lit).Get("collie")
+		}
+		if tag == "-" {
+			continue
+		}
+
+		for _, ident := range field.Names {
+			if !ast.IsExported(ident.Name) {
+				continue
+			}
+
+			f := fieldSpec{Name: ident.Name, Column: ident.Name}
+			parts := strings.Split(tag, ",")
+			if parts[0] != "" {
+				f.Column = parts[0]
+			}
+			f.Index = len(parts) > 1 && parts[1] == "index"
+
+			typ := field.Type
+			if arr, ok := typ.(*ast.ArrayType); ok && arr.Len == nil && kindOf(typ) == "" {
+				if !f.Index {
+					return nil, errors.New("colliegen: slice field '" + f.Name + "' must be an index")
+				}
+				f.Multi, typ = true, arr.Elt
+			}
+			if f.Kind = kindOf(typ); f.Kind == "" {
+				return nil, errors.New("colliegen: field '" + f.Name + "' has an unsupported type")
+			}
+			if !f.Multi {
+				f.Size = kindSizes[f.Kind]
+			}
+			spec.Fields = append(spec.Fields, f)
+		}
+	}
+	return spec, nil
+}
+
+// kindOf returns the kind of a field type expression
+func kindOf(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if t.Name == "byte" {
+			return "uint8"
+		} else if _, ok := kindSizes[t.Name]; ok && t.Name != "bytes" && t.Name != "time" {
+			return t.Name
+		}
+	case *ast.ArrayType:
+		if elt, ok := t.Elt.(*ast.Ident); ok && t.Len == nil && (elt.Name == "byte" || elt.Name == "uint8") {
+			return "bytes"
+		}
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok && pkg.Name == "time" && t.Sel.Name == "Time" {
+			return "time"
+		}
+	}
+	return ""
+}
+
+// readManifest builds a struct type from a schema manifest. Data columns
+// are mapped to byte slices, index-only columns to multi-valued indices.
+func readManifest(fname, name, pkg string) (*typeSpec, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	var schema collie.Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, err
+	}
+
+	spec := &typeSpec{Package: pkg, Name: name, Declare: true}
+	for _, col := range schema.Columns() {
+		spec.Fields = append(spec.Fields, fieldSpec{
+			Name:      exportedName(col.Name),
+			Column:    col.Name,
+			Kind:      "bytes",
+			Size:      col.Size,
+			Index:     col.Index != collie.IndexTypeNone,
+			Multi:     col.NoData,
+			IndexType: col.Index,
+		})
+	}
+	return spec, nil
+}
+
+// packageName returns the name of the package in dir, as passed by
+// go generate or derived from the directory name
+func packageName(dir string) string {
+	if name := os.Getenv("GOPACKAGE"); name != "" {
+		return name
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, filepath.Base(dir))
+}
+
+// exportedName converts a column name into an exported Go identifier
+func exportedName(s string) string {
+	var out []rune
+	upper := true
+	for _, r := range s {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r, upper = unicode.ToUpper(r), false
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
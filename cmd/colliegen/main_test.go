@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bsm/collie"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("colliegen", func() {
+
+	It("should validate usage", func() {
+		Expect(run(nil)).To(Equal(errUsage))
+		Expect(run([]string{"-type", "T", "a", "b"})).To(Equal(errUsage))
+		Expect(run([]string{"-type", "Missing", "-output", filepath.Join(testDir, "x.go"), "example"})).To(MatchError("colliegen: type Missing not found"))
+	})
+
+	It("should generate up-to-date examples", func() {
+		output := filepath.Join(testDir, "event_collie.go")
+		Expect(run([]string{"-type", "Event", "-output", output, "example"})).To(Succeed())
+
+		generated, err := ioutil.ReadFile(output)
+		Expect(err).NotTo(HaveOccurred())
+		committed, err := ioutil.ReadFile(filepath.Join("example", "event_collie.go"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(generated)).To(Equal(string(committed)))
+	})
+
+	It("should reject unsupported fields", func() {
+		write := func(src string) error {
+			return ioutil.WriteFile(filepath.Join(testDir, "t.go"), []byte("package t\n\n"+src), 0644)
+		}
+
+		Expect(write("type T int")).To(Succeed())
+		Expect(run([]string{"-type", "T", testDir})).To(MatchError("colliegen: type T is not a struct"))
+		Expect(write("type T struct { Tags []string }")).To(Succeed())
+		Expect(run([]string{"-type", "T", testDir})).To(MatchError("colliegen: slice field 'Tags' must be an index"))
+		Expect(write("type T struct { Meta map[string]string }")).To(Succeed())
+		Expect(run([]string{"-type", "T", testDir})).To(MatchError("colliegen: field 'Meta' has an unsupported type"))
+	})
+
+	It("should parse structs", func() {
+		spec, err := parseStruct("example", "Event", "event_collie.go")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec.Package).To(Equal("example"))
+		Expect(spec.Fields).To(HaveLen(11))
+		Expect(spec.Fields[0]).To(Equal(fieldSpec{Name: "ID", Column: "id", Kind: "uint32", Size: 4}))
+		Expect(spec.Fields[8]).To(Equal(fieldSpec{Name: "Tags", Column: "tags", Kind: "string", Index: true, Multi: true}))
+	})
+
+	It("should generate from schema manifests", func() {
+		schema := collie.CreateSchema([]collie.Column{
+			{Name: "user_name"},
+			{Name: "code", Size: 2, Index: collie.IndexTypeHash},
+			{Name: "tags", Index: collie.IndexTypeMemory, NoData: true},
+		})
+		data, err := schema.MarshalJSON()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.WriteFile(filepath.Join(testDir, "SCHEMA"), data, 0644)).To(Succeed())
+
+		Expect(run([]string{"-type", "Row", "-schema", filepath.Join(testDir, "SCHEMA"), testDir})).To(Succeed())
+		src, err := ioutil.ReadFile(filepath.Join(testDir, "row_collie.go"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(src)).To(ContainSubstring("package " + packageName(testDir) + "\n"))
+		Expect(string(src)).To(ContainSubstring("\tUserName []byte   `collie:\"user_name\"`\n"))
+		Expect(string(src)).To(ContainSubstring("\tTags     [][]byte `collie:\"tags,index\"`\n"))
+		Expect(string(src)).To(ContainSubstring(`{Name: "code", Size: 2, Index: collie.IndexTypeHash},`))
+		Expect(string(src)).To(ContainSubstring(`{Name: "tags", Index: collie.IndexTypeMemory, NoData: true},`))
+		Expect(string(src)).To(ContainSubstring("func (r *RowReader) OffsetsByTags(v []byte) ([]int64, error) {"))
+	})
+
+	It("should reject unsupported index types", func() {
+		_, err := generate(&typeSpec{Package: "t", Name: "T", Fields: []fieldSpec{
+			{Name: "Code", Column: "code", Kind: "bytes", Index: true, IndexType: collie.IndexType(200)},
+		}})
+		Expect(err).To(MatchError("colliegen: unsupported index type 'IndexType(200)' of column 'code'"))
+	})
+
+})
+
+var testDir string
+
+func TestSuite(t *testing.T) {
+	BeforeEach(func() {
+		var err error
+		testDir, err = ioutil.TempDir("", "collie.colliegen.test")
+		Expect(err).NotTo(HaveOccurred())
+	})
+	AfterEach(func() {
+		os.RemoveAll(testDir)
+	})
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "collie/cmd/colliegen")
+}
@@ -1,53 +1,86 @@
 package collie
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/bsm/collie/column"
 )
 
 type Collection struct {
-	dir     string
-	columns map[string]column.Column
-	indices map[string]column.Index
-	offset  int64
-	wmux    sync.Mutex
+	dir    string
+	schema *Schema
+	opts   *Options
+	offset int64
+	wmux   sync.Mutex
+	ofile  *os.File
+
+	notify chan struct{}
+	nmux   sync.Mutex
+
+	segs []*segment
+	smux sync.RWMutex
+
+	listeners map[*listener]struct{}
+	lmux      sync.Mutex
+
+	counters counters
 }
 
 // OpenCollection opens a collection in target directory for given schema
 func OpenCollection(dir string, schema *Schema) (*Collection, error) {
-	err := os.MkdirAll(dir, 0755)
-	if err != nil {
-		return nil, err
-	}
+	return OpenCollectionWithOptions(dir, schema, nil)
+}
 
+// OpenCollectionWithOptions behaves like OpenCollection, but accepts
+// custom options
+func OpenCollectionWithOptions(dir string, schema *Schema, opts *Options) (*Collection, error) {
 	coll := &Collection{
-		dir:     dir,
-		columns: make(map[string]column.Column),
-		indices: make(map[string]column.Index),
+		dir:    dir,
+		schema: schema,
+		opts:   opts.norm(),
 	}
-
-	// Register columns
-	for _, col := range schema.Columns() {
-		if err := coll.register(&col); err != nil {
+	if err := coll.opts.validate(schema); err != nil {
+		return nil, err
+	}
+	if coll.opts.CheckSchema {
+		if err := checkSchema(dir, schema); err != nil {
 			return nil, err
 		}
 	}
-
-	// Re-establish offset (minimum)
-	offset := int64(-1)
-	for _, col := range coll.columns {
-		if cln := col.Len(); offset < 0 || cln < offset {
-			offset = cln
+	if coll.opts.ReadOnly {
+		if err := coll.openReadOnly(); err != nil {
+			return nil, err
 		}
+		return coll, nil
+	} else if isCheckpoint(dir) {
+		return nil, errCheckpointWritable
 	}
-	if offset > 0 {
-		coll.offset = offset
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
 	}
+	if err := writeSchema(dir, schema); err != nil {
+		return nil, err
+	}
+	if err := coll.openSegments(); err != nil {
+		coll.Close()
+		return nil, err
+	}
+
+	// Re-establish offset (minimum)
+	head := coll.head()
+	coll.offset = head.base + head.Len()
 
+	if err := coll.openOffsetFile(); err != nil {
+		coll.Close()
+		return nil, err
+	}
 	return coll, nil
 }
 
@@ -56,34 +89,104 @@ func OpenCollection(dir string, schema *Schema) (*Collection, error) {
 // at the number of rows about to be added.
 func (c *Collection) Begin(rows int) *Txn { return newTxn(c, rows) }
 
+// FirstOffset returns the offset of the first row, which
+// is greater than zero once old rows have been dropped.
+// Closed collections hold no rows and return Offset.
+func (c *Collection) FirstOffset() int64 {
+	c.smux.RLock()
+	defer c.smux.RUnlock()
+
+	if len(c.segs) == 0 {
+		return c.Offset()
+	}
+	return c.segs[0].base
+}
+
 // Offset returns the current offset
 func (c *Collection) Offset() int64 { return atomic.LoadInt64(&c.offset) }
 
-func (c *Collection) storeOffset(o int64) { atomic.StoreInt64(&c.offset, o) }
+// Schema returns the collection schema
+func (c *Collection) Schema() *Schema { return c.schema }
+
+// storeOffset stores the offset and wakes up all waiters
+func (c *Collection) storeOffset(o int64) {
+	atomic.StoreInt64(&c.offset, o)
+
+	c.nmux.Lock()
+	if c.notify != nil {
+		close(c.notify)
+		c.notify = nil
+	}
+	c.nmux.Unlock()
+}
+
+// changed returns a channel which is closed once the offset changes
+func (c *Collection) changed() <-chan struct{} {
+	c.nmux.Lock()
+	defer c.nmux.Unlock()
 
-// Close closes the schema
+	if c.notify == nil {
+		c.notify = make(chan struct{})
+	}
+	return c.notify
+}
+
+// Close closes the collection, after waiting for pending commits
 func (c *Collection) Close() (err error) {
-	for _, c := range c.columns {
-		if e := c.Close(); e != nil {
+	c.wmux.Lock()
+	defer c.wmux.Unlock()
+	c.smux.Lock()
+	defer c.smux.Unlock()
+
+	for _, seg := range c.segs {
+		if e := seg.Close(); e != nil {
 			err = e
 		}
 	}
-	for _, x := range c.indices {
-		if e := x.Close(); e != nil {
+	c.segs = nil
+
+	if c.ofile != nil {
+		if e := c.ofile.Close(); e != nil {
 			err = e
 		}
+		c.ofile = nil
 	}
 	return
 }
 
-// Value returns a column value at a given offset
+// Value returns a column value at a given offset. Values failing
+// checksum verification return a *column.CorruptionError.
 func (c *Collection) Value(name string, offset int64) ([]byte, error) {
-	col, ok := c.columns[name]
-	if !ok {
+	return c.ValueContext(context.Background(), name, offset)
+}
+
+// ValueContext behaves like Value, but fails if ctx is done
+func (c *Collection) ValueContext(ctx context.Context, name string, offset int64) ([]byte, error) {
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	defer c.counters.read(1, time.Now())
+
+	c.smux.RLock()
+	defer c.smux.RUnlock()
+
+	if len(c.segs) == 0 {
+		return nil, ErrClosed
+	} else if _, ok := c.segs[0].columns[name]; !ok {
 		return nil, ErrColumnNotFound
 	}
 
-	bin, err := col.Get(offset)
+	// Column files may hold uncommitted rows or, within checkpoints,
+	// rows appended after the pinned offset
+	if offset >= c.Offset() {
+		return nil, ErrNotFound
+	}
+	seg := c.segmentAt(offset)
+	if seg == nil {
+		return nil, ErrNotFound
+	}
+
+	bin, err := seg.columns[name].Get(offset - seg.base)
 	if err == column.ErrNotFound {
 		err = ErrNotFound
 	}
@@ -92,27 +195,237 @@ func (c *Collection) Value(name string, offset int64) ([]byte, error) {
 
 // Offsets returns a slice of offsets for a given index/value pair
 func (c *Collection) Offsets(name string, value []byte) ([]int64, error) {
-	idx, ok := c.indices[name]
-	if !ok {
+	return c.OffsetsContext(context.Background(), name, value)
+}
+
+// OffsetsContext behaves like Offsets, but aborts when ctx is done,
+// checking between segments
+func (c *Collection) OffsetsContext(ctx context.Context, name string, value []byte) ([]int64, error) {
+	defer c.counters.lookup(time.Now())
+
+	c.smux.RLock()
+	defer c.smux.RUnlock()
+
+	if len(c.segs) == 0 {
+		return nil, ErrClosed
+	} else if _, ok := c.segs[0].indices[name]; !ok {
 		return nil, ErrColumnNotFound
 	}
-	return idx.Get(value)
+
+	var res []int64
+	for _, seg := range c.segs {
+		if err := canceled(ctx); err != nil {
+			return nil, err
+		}
+		offs, err := seg.indices[name].Get(value)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, offs...)
+	}
+	return res, nil
+}
+
+// OffsetsPrefix returns the ascending offsets of all rows indexed by
+// a value starting with prefix
+func (c *Collection) OffsetsPrefix(name string, prefix []byte) ([]int64, error) {
+	return c.OffsetsPrefixContext(context.Background(), name, prefix)
 }
 
-func (c *Collection) register(col *Column) (err error) {
-	prefix := filepath.Join(c.dir, col.Name)
+// OffsetsPrefixContext behaves like OffsetsPrefix, but aborts when ctx is
+// done, checking between index values
+func (c *Collection) OffsetsPrefixContext(ctx context.Context, name string, prefix []byte) ([]int64, error) {
+	defer c.counters.lookup(time.Now())
+
+	c.smux.RLock()
+	defer c.smux.RUnlock()
 
-	switch col.Index {
-	case IndexTypeHash:
-		c.indices[col.Name], err = column.OpenHashIndex(prefix + ".ci")
+	iter, err := c.iterate(name, prefix)
+	if err != nil {
+		return nil, err
 	}
+	defer iter.Release()
 
-	if err == nil && !col.NoData {
-		if col.Size > 0 {
-			c.columns[col.Name], err = column.OpenFixed(prefix+".cc", col.Size)
-		} else {
-			c.columns[col.Name], err = column.OpenVariable(prefix + ".cc")
+	var lists [][]int64
+	for iter.Next() {
+		if err := canceled(ctx); err != nil {
+			return nil, err
 		}
+		lists = append(lists, iter.Offsets())
 	}
-	return
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return mergeOffsets(lists), nil
+}
+
+// IndexValues returns an iterator over the distinct values of an index,
+// restricted to values starting with prefix. Iterators must be closed
+// after use.
+func (c *Collection) IndexValues(name string, prefix []byte) (*ValueIterator, error) {
+	c.smux.RLock()
+	defer c.smux.RUnlock()
+
+	iter, err := c.iterate(name, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &ValueIterator{iter: iter}, nil
+}
+
+// TopIndexValues returns the n most frequent values of an index starting
+// with prefix, ordered by descending count. All values are returned if n < 1.
+func (c *Collection) TopIndexValues(name string, prefix []byte, n int) ([]ValueCount, error) {
+	return c.TopIndexValuesContext(context.Background(), name, prefix, n)
+}
+
+// TopIndexValuesContext behaves like TopIndexValues, but aborts when ctx
+// is done, checking between index values
+func (c *Collection) TopIndexValuesContext(ctx context.Context, name string, prefix []byte, n int) ([]ValueCount, error) {
+	iter, err := c.IndexValues(name, prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	return topValues(ctx, iter, n)
+}
+
+// openSegments opens all existing segments. Unless the collection is
+// segmented, rows are stored directly in the collection directory.
+// Segmented collections store rows in sub-directories, named after the
+// offset of their first row.
+func (c *Collection) openSegments() error {
+	if err := removeDropped(c.dir); err != nil {
+		return err
+	}
+
+	bases, err := listSegments(c.dir)
+	if err != nil {
+		return err
+	}
+
+	if hasSegmentFiles(c.dir, c.schema) || (len(bases) == 0 && !c.opts.segmented()) {
+		seg, err := openSegment(c.dir, 0, c.schema, c.opts)
+		if err != nil {
+			return err
+		}
+		c.segs = append(c.segs, seg)
+	} else if len(bases) == 0 {
+		seg, err := createSegment(filepath.Join(c.dir, segmentName(0)), 0, c.schema, c.opts)
+		if err != nil {
+			return err
+		}
+		c.segs = append(c.segs, seg)
+	}
+
+	for _, base := range bases {
+		seg, err := openSegment(filepath.Join(c.dir, segmentName(base)), base, c.schema, c.opts)
+		if err != nil {
+			return err
+		}
+		c.segs = append(c.segs, seg)
+	}
+	return nil
+}
+
+// head returns the last segment, or nil if the collection is closed
+func (c *Collection) head() *segment {
+	c.smux.RLock()
+	defer c.smux.RUnlock()
+
+	if len(c.segs) == 0 {
+		return nil
+	}
+	return c.segs[len(c.segs)-1]
+}
+
+// writable returns the segment to write the rows following offset to,
+// rolling over to a new segment if necessary. Must only be called
+// while holding the write lock.
+func (c *Collection) writable(offset int64) (*segment, error) {
+	head := c.head()
+	if head == nil {
+		return nil, ErrClosed
+	} else if !c.opts.rolloverDue(head, offset-head.base) {
+		return head, nil
+	}
+
+	seg, err := createSegment(filepath.Join(c.dir, segmentName(offset)), offset, c.schema, c.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.smux.Lock()
+	c.segs = append(c.segs, seg)
+	c.smux.Unlock()
+
+	if err := c.retain(); err != nil {
+		return nil, err
+	}
+	return seg, nil
+}
+
+// segmentAt returns the segment containing offset,
+// must be called while holding a read lock
+func (c *Collection) segmentAt(offset int64) *segment {
+	if offset < 0 {
+		return nil
+	}
+
+	n := sort.Search(len(c.segs), func(i int) bool { return c.segs[i].base > offset })
+	if n == 0 {
+		return nil
+	}
+	return c.segs[n-1]
+}
+
+// readColumn reads the values of a column within [from, to), segment by
+// segment, while holding the read lock once
+func (c *Collection) readColumn(name string, from, to int64) ([]Value, error) {
+	defer c.counters.read(to-from, time.Now())
+
+	c.smux.RLock()
+	defer c.smux.RUnlock()
+
+	if len(c.segs) == 0 {
+		return nil, ErrClosed
+	} else if _, ok := c.segs[0].columns[name]; !ok {
+		return nil, ErrColumnNotFound
+	} else if to > c.Offset() {
+		return nil, ErrNotFound
+	}
+
+	vals := make([]Value, 0, to-from)
+	for off := from; off < to; off++ {
+		seg := c.segmentAt(off)
+		if seg == nil {
+			return nil, ErrNotFound
+		}
+
+		val, err := seg.columns[name].Get(off - seg.base)
+		if err == column.ErrNotFound {
+			return nil, ErrNotFound
+		} else if err != nil {
+			return nil, err
+		}
+		vals = append(vals, val)
+	}
+	return vals, nil
+}
+
+// iterate returns an iterator over the values of an index across all
+// segments, must be called while holding a read lock
+func (c *Collection) iterate(name string, prefix []byte) (column.IndexIterator, error) {
+	if len(c.segs) == 0 {
+		return nil, ErrClosed
+	} else if _, ok := c.segs[0].indices[name]; !ok {
+		return nil, ErrColumnNotFound
+	}
+
+	iters := make([]column.IndexIterator, 0, len(c.segs))
+	for _, seg := range c.segs {
+		iters = append(iters, seg.indices[name].Iterate(prefix))
+	}
+	return mergeIterators(iters), nil
 }
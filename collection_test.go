@@ -1,6 +1,10 @@
 package collie
 
 import (
+	"context"
+	"io"
+	"path/filepath"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -29,14 +33,17 @@ var _ = Describe("Collection", func() {
 	})
 
 	It("should register types", func() {
-		Expect(subject.columns).To(HaveLen(4))
-		Expect(subject.columns).To(HaveKey("first"))
-		Expect(subject.columns).To(HaveKey("last"))
-		Expect(subject.columns).To(HaveKey("age"))
-		Expect(subject.columns).To(HaveKey("active"))
-		Expect(subject.indices).To(HaveLen(2))
-		Expect(subject.indices).To(HaveKey("accountIds"))
-		Expect(subject.indices).To(HaveKey("age"))
+		Expect(subject.segs).To(HaveLen(1))
+		seg := subject.head()
+		Expect(seg.dir).To(Equal(testDir))
+		Expect(seg.columns).To(HaveLen(4))
+		Expect(seg.columns).To(HaveKey("first"))
+		Expect(seg.columns).To(HaveKey("last"))
+		Expect(seg.columns).To(HaveKey("age"))
+		Expect(seg.columns).To(HaveKey("active"))
+		Expect(seg.indices).To(HaveLen(2))
+		Expect(seg.indices).To(HaveKey("accountIds"))
+		Expect(seg.indices).To(HaveKey("age"))
 	})
 
 	Describe("input/output", func() {
@@ -105,6 +112,256 @@ var _ = Describe("Collection", func() {
 			Expect(err).To(Equal(ErrColumnNotFound))
 		})
 
+		It("should abort cancelled reads", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, err := subject.ValueContext(ctx, "first", 0)
+			Expect(err).To(Equal(context.Canceled))
+			_, err = subject.OffsetsContext(ctx, "age", []byte{26})
+			Expect(err).To(Equal(context.Canceled))
+			_, err = subject.OffsetsPrefixContext(ctx, "accountIds", nil)
+			Expect(err).To(Equal(context.Canceled))
+			_, err = subject.TopIndexValuesContext(ctx, "age", nil, 1)
+			Expect(err).To(Equal(context.Canceled))
+			_, err = subject.RowsContext(ctx, []int64{0, 1})
+			Expect(err).To(Equal(context.Canceled))
+
+			Expect(subject.ValueContext(context.Background(), "first", 0)).To(Equal([]byte("Jane")))
+		})
+
+		It("should fail when closed", func() {
+			Expect(subject.Close()).To(Succeed())
+
+			_, err := subject.Value("first", 0)
+			Expect(err).To(Equal(ErrClosed))
+			_, err = subject.Offsets("age", []byte{26})
+			Expect(err).To(Equal(ErrClosed))
+			_, err = subject.OffsetsPrefix("age", nil)
+			Expect(err).To(Equal(ErrClosed))
+			_, err = subject.IndexValues("age", nil)
+			Expect(err).To(Equal(ErrClosed))
+			_, err = subject.Rows([]int64{0})
+			Expect(err).To(Equal(ErrClosed))
+			_, err = subject.Scrub()
+			Expect(err).To(Equal(ErrClosed))
+			Expect(subject.FirstOffset()).To(Equal(int64(2)))
+
+			txn := subject.Begin(1)
+			txn.New().SetColumn("first", Value("Jim"))
+			_, err = txn.Commit()
+			Expect(err).To(Equal(ErrClosed))
+		})
+
+		It("should wait for commits when closing", func() {
+			started, release := make(chan struct{}), make(chan struct{})
+			head := subject.head()
+			head.indices["age"] = testIndexCancelWrite{head.indices["age"], func() {
+				close(started)
+				<-release
+			}}
+
+			committed := make(chan error, 1)
+			go func() {
+				txn := subject.Begin(1)
+				txn.Add(testRecord{"first": Value("Jim"), "age": Value{30}})
+				_, err := txn.Commit()
+				committed <- err
+			}()
+			<-started
+
+			closed := make(chan error, 1)
+			go func() { closed <- subject.Close() }()
+			Consistently(closed).ShouldNot(Receive())
+
+			close(release)
+			Eventually(committed).Should(Receive(BeNil()))
+			Eventually(closed).Should(Receive(BeNil()))
+		})
+
+		It("should query index offsets by prefix", func() {
+			txn := subject.Begin(1)
+			txn.Add(testRecord{"accountIds": []byte{0, 0, 3, 0}})
+			row := txn.New()
+			row.AddIndex("accountIds", Value{0, 0, 2, 1})
+			row.AddIndex("accountIds", Value{0, 0, 2, 2})
+			_, err := txn.Commit()
+			Expect(err).NotTo(HaveOccurred())
+
+			offsets, err := subject.OffsetsPrefix("accountIds", []byte{0, 0, 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(offsets).To(Equal([]int64{0, 1, 3}))
+
+			offsets, err = subject.OffsetsPrefix("accountIds", []byte{0, 0})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(offsets).To(Equal([]int64{0, 1, 2, 3}))
+
+			offsets, err = subject.OffsetsPrefix("accountIds", []byte{1})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(offsets).To(BeEmpty())
+
+			_, err = subject.OffsetsPrefix("first", nil)
+			Expect(err).To(Equal(ErrColumnNotFound))
+		})
+
+		It("should iterate over index values", func() {
+			iter, err := subject.IndexValues("accountIds", []byte{0, 0, 2})
+			Expect(err).NotTo(HaveOccurred())
+			defer iter.Close()
+
+			Expect(iter.Next()).To(BeTrue())
+			Expect(iter.Value()).To(Equal(Value{0, 0, 2, 0}))
+			Expect(iter.Count()).To(Equal(1))
+			Expect(iter.Next()).To(BeTrue())
+			Expect(iter.Value()).To(Equal(Value{0, 0, 2, 99}))
+			Expect(iter.Count()).To(Equal(1))
+			Expect(iter.Next()).To(BeFalse())
+			Expect(iter.Err()).NotTo(HaveOccurred())
+
+			_, err = subject.IndexValues("first", nil)
+			Expect(err).To(Equal(ErrColumnNotFound))
+		})
+
+		It("should return top index values", func() {
+			txn := subject.Begin(3)
+			txn.Add(testRecord{"age": []byte{26}})
+			txn.Add(testRecord{"age": []byte{26}})
+			txn.Add(testRecord{"age": []byte{31}})
+			_, err := txn.Commit()
+			Expect(err).NotTo(HaveOccurred())
+
+			top, err := subject.TopIndexValues("age", nil, 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(top).To(Equal([]ValueCount{
+				{Value: Value{26}, Count: 3},
+				{Value: Value{27}, Count: 1},
+			}))
+
+			top, err = subject.TopIndexValues("age", nil, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(top).To(Equal([]ValueCount{
+				{Value: Value{26}, Count: 3},
+				{Value: Value{27}, Count: 1},
+				{Value: Value{31}, Count: 1},
+			}))
+
+			top, err = subject.TopIndexValues("age", []byte{3}, 5)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(top).To(BeEmpty())
+		})
+
+	})
+
+	Describe("segmented", func() {
+		var dir string
+
+		add := func(recs ...testRecord) {
+			txn := subject.Begin(len(recs))
+			for _, rec := range recs {
+				txn.Add(rec)
+			}
+			_, err := txn.Commit()
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		BeforeEach(func() {
+			Expect(subject.Close()).NotTo(HaveOccurred())
+
+			var err error
+			dir = filepath.Join(testDir, "segmented")
+			subject, err = OpenCollectionWithOptions(dir, schema, &Options{SegmentRows: 2})
+			Expect(err).NotTo(HaveOccurred())
+
+			add(
+				testRecord{"first": []byte("Jane"), "age": []byte{27}, "accountIds": []byte{0, 0, 2, 0}},
+				testRecord{"first": []byte("John"), "age": []byte{26}, "accountIds": []byte{0, 0, 2, 1}},
+			)
+			add(testRecord{"first": []byte("Jack"), "age": []byte{27}, "accountIds": []byte{0, 0, 2, 0}})
+			add(
+				testRecord{"first": []byte("Jill"), "age": []byte{25}, "accountIds": []byte{0, 0, 3, 0}},
+				testRecord{"first": []byte("Joan"), "age": []byte{27}, "accountIds": []byte{0, 0, 2, 1}},
+			)
+			add(testRecord{"first": []byte("Josh"), "age": []byte{26}, "accountIds": []byte{0, 0, 2, 0}})
+		})
+
+		It("should roll over segments", func() {
+			Expect(subject.Offset()).To(Equal(int64(6)))
+			Expect(subject.segs).To(HaveLen(3))
+			Expect(subject.segs[0].base).To(Equal(int64(0)))
+			Expect(subject.segs[0].dir).To(Equal(filepath.Join(dir, "00000000000000000000")))
+			Expect(subject.segs[1].base).To(Equal(int64(2)))
+			Expect(subject.segs[1].dir).To(Equal(filepath.Join(dir, "00000000000000000002")))
+			Expect(subject.segs[2].base).To(Equal(int64(5)))
+			Expect(subject.segs[2].dir).To(Equal(filepath.Join(dir, "00000000000000000005")))
+		})
+
+		It("should re-open segments", func() {
+			Expect(subject.Close()).NotTo(HaveOccurred())
+
+			var err error
+			subject, err = OpenCollectionWithOptions(dir, schema, &Options{SegmentRows: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(subject.Offset()).To(Equal(int64(6)))
+			Expect(subject.segs).To(HaveLen(3))
+
+			Expect(subject.Close()).NotTo(HaveOccurred())
+			subject, err = OpenCollection(dir, schema)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(subject.Offset()).To(Equal(int64(6)))
+			Expect(subject.segs).To(HaveLen(3))
+		})
+
+		It("should get values across segments", func() {
+			for off, name := range []string{"Jane", "John", "Jack", "Jill", "Joan", "Josh"} {
+				val, err := subject.Value("first", int64(off))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(val)).To(Equal(name))
+			}
+
+			_, err := subject.Value("first", 6)
+			Expect(err).To(Equal(ErrNotFound))
+			_, err = subject.Value("first", -1)
+			Expect(err).To(Equal(ErrNotFound))
+		})
+
+		It("should query offsets across segments", func() {
+			offsets, err := subject.Offsets("age", []byte{27})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(offsets).To(Equal([]int64{0, 2, 4}))
+
+			offsets, err = subject.OffsetsPrefix("accountIds", []byte{0, 0, 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(offsets).To(Equal([]int64{0, 1, 2, 4, 5}))
+		})
+
+		It("should merge index values across segments", func() {
+			top, err := subject.TopIndexValues("accountIds", nil, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(top).To(Equal([]ValueCount{
+				{Value: Value{0, 0, 2, 0}, Count: 3},
+				{Value: Value{0, 0, 2, 1}, Count: 2},
+				{Value: Value{0, 0, 3, 0}, Count: 1},
+			}))
+		})
+
+		It("should rollback within the current segment", func() {
+			txn := subject.Begin(2)
+			txn.Add(testRecord{"first": []byte("Judy"), "age": []byte{30}})
+			txn.Add(testRecordBadCol{})
+			_, err := txn.Commit()
+			Expect(err).To(Equal(io.EOF))
+			Expect(subject.Offset()).To(Equal(int64(6)))
+			Expect(subject.segs).To(HaveLen(3))
+
+			add(testRecord{"first": []byte("Judy"), "age": []byte{30}})
+			Expect(subject.Offset()).To(Equal(int64(7)))
+			Expect(subject.segs).To(HaveLen(3))
+
+			val, err := subject.Value("first", 6)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(val)).To(Equal("Judy"))
+		})
+
 	})
 
 })
@@ -5,6 +5,9 @@ import "errors"
 var (
 	ErrNotFound       = errors.New("collie: not found")
 	ErrColumnNotFound = errors.New("collie: column not found")
+	ErrReadOnly       = errors.New("collie: collection is read-only")
+	ErrClosed         = errors.New("collie: collection is closed")
+	ErrSchemaMismatch = errors.New("collie: schema does not match the stored schema")
 )
 
 // Values are just byte arrays
@@ -6,6 +6,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/bsm/collie/column"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -64,6 +65,24 @@ func (t testRecordBadIndex) IValuesAt(name string) ([]Value, error) {
 	return []Value{t[name]}, nil
 }
 
+type testIndexBadWrite struct{ column.Index }
+
+func (testIndexBadWrite) Write(column.Postings) error { return io.ErrShortWrite }
+
+type testColumnBadAdd struct{ column.Column }
+
+func (testColumnBadAdd) Add([]byte) error { return io.ErrShortWrite }
+
+type testIndexCancelWrite struct {
+	column.Index
+	cancel func()
+}
+
+func (i testIndexCancelWrite) Write(p column.Postings) error {
+	defer i.cancel()
+	return i.Index.Write(p)
+}
+
 /*************************************************************************
  * GINKGO TEST HOOK
  *************************************************************************/
@@ -2,35 +2,197 @@ package collie
 
 import (
 	"errors"
+	"math"
 	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/bsm/collie/column"
 )
 
 var validColumnName = regexp.MustCompile(`^[a-zA-Z]\w*$`)
 
+// IndexType identifies a registered index implementation
 type IndexType uint8
 
 const (
 	IndexTypeNone IndexType = iota
 	IndexTypeHash
+	IndexTypeMemory
+)
+
+// IndexOpener opens the index stored at path, optionally without write
+// access
+type IndexOpener func(path string, readOnly bool) (column.Index, error)
+
+var (
+	indexTypeNames = map[IndexType]string{
+		IndexTypeNone:   "none",
+		IndexTypeHash:   "hash",
+		IndexTypeMemory: "memory",
+	}
+	indexOpeners = map[IndexType]IndexOpener{
+		IndexTypeHash:   openHashIndex,
+		IndexTypeMemory: openMemoryIndex,
+	}
+	indexTypesMu sync.RWMutex
 )
 
+// RegisterIndexType registers a named index implementation and returns
+// its type, which can then be assigned to schema columns. Registrations
+// must be performed consistently, before any collections are opened,
+// usually in an init function. Panics if the name is already taken.
+func RegisterIndexType(name string, open IndexOpener) IndexType {
+	indexTypesMu.Lock()
+	defer indexTypesMu.Unlock()
+
+	typ := registerIndexName(name)
+	if _, ok := indexOpeners[typ]; ok {
+		panic("collie: index type '" + name + "' is already registered")
+	}
+	indexOpeners[typ] = open
+	return typ
+}
+
+// registerIndexName returns the type of a named index, allocating a new
+// one for unknown names. Must only be called while holding the lock.
+func registerIndexName(name string) IndexType {
+	for typ, n := range indexTypeNames {
+		if n == name {
+			return typ
+		}
+	}
+	if len(indexTypeNames) > math.MaxUint8 {
+		panic("collie: too many index types")
+	}
+
+	typ := IndexType(len(indexTypeNames))
+	indexTypeNames[typ] = name
+	return typ
+}
+
+// String returns the registered name
+func (t IndexType) String() string {
+	indexTypesMu.RLock()
+	defer indexTypesMu.RUnlock()
+
+	if name, ok := indexTypeNames[t]; ok {
+		return name
+	}
+	return "IndexType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (t IndexType) MarshalText() ([]byte, error) {
+	indexTypesMu.RLock()
+	defer indexTypesMu.RUnlock()
+
+	if name, ok := indexTypeNames[t]; ok {
+		return []byte(name), nil
+	}
+	return nil, errors.New("collie: unknown index type")
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. Unknown names are
+// accepted as opaque types, which allow to inspect schemas without their
+// registrations, e.g. using read-only collections with NoIndices, but
+// fail to open indices until registered.
+func (t *IndexType) UnmarshalText(text []byte) error {
+	indexTypesMu.Lock()
+	defer indexTypesMu.Unlock()
+
+	if len(text) == 0 {
+		return errors.New("collie: invalid index type ''")
+	}
+	for typ, name := range indexTypeNames {
+		if name == string(text) {
+			*t = typ
+			return nil
+		}
+	}
+	if len(indexTypeNames) > math.MaxUint8 {
+		return errors.New("collie: too many index types")
+	}
+	*t = registerIndexName(string(text))
+	return nil
+}
+
+// opener returns the opener of a registered index type
+func (t IndexType) opener() (IndexOpener, bool) {
+	indexTypesMu.RLock()
+	defer indexTypesMu.RUnlock()
+
+	open, ok := indexOpeners[t]
+	return open, ok
+}
+
+// known returns true if t is a registered or opaque index type
+func (t IndexType) known() bool {
+	indexTypesMu.RLock()
+	defer indexTypesMu.RUnlock()
+
+	_, ok := indexTypeNames[t]
+	return ok
+}
+
+// openIndex opens an index of type t at path
+func openIndex(t IndexType, path string, readOnly bool) (column.Index, error) {
+	open, ok := t.opener()
+	if !ok {
+		return nil, errors.New("collie: unregistered index type '" + t.String() + "'")
+	}
+	return open(path, readOnly)
+}
+
+func openHashIndex(path string, readOnly bool) (column.Index, error) {
+	var idx *column.HashIndex
+	var err error
+	if readOnly {
+		idx, err = column.OpenHashIndexReadOnly(path)
+	} else {
+		idx, err = column.OpenHashIndex(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+func openMemoryIndex(path string, readOnly bool) (column.Index, error) {
+	var idx *column.MemoryIndex
+	var err error
+	if readOnly {
+		idx, err = column.OpenMemoryIndexReadOnly(path)
+	} else {
+		idx, err = column.OpenMemoryIndex(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
 // Column is an abstract column definition of a schema
 type Column struct {
 	// A column name, names must start with a letter,
 	// followed by alphanumeric characters and underscores
-	Name string
+	Name string `json:"name"`
 	// The maximum column length in bytes, assumed to be variable if <1
-	Size int
-	// Create an index for this column. Default: IndexTypeNone
-	Index IndexType
+	Size int `json:"size,omitempty"`
+	// Create an index for this column, using a built-in or registered
+	// index type. Default: IndexTypeNone
+	Index IndexType `json:"index,omitempty"`
 	// Do not store the data of this column, useful for
 	// index-only columns
-	NoData bool
+	NoData bool `json:"nodata,omitempty"`
 }
 
 func (c *Column) Validate() error {
 	if c.Name == "" || !validColumnName.MatchString(c.Name) {
 		return errors.New("collie: invalid column name '" + c.Name + "'")
 	}
+	if !c.Index.known() {
+		return errors.New("collie: unknown index type for column '" + c.Name + "'")
+	}
 	return nil
 }
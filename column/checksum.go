@@ -0,0 +1,408 @@
+package column
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// The number of data bytes covered by each checksum
+	checksumBlockSize = 4096
+	// The size of the checksum file header
+	checksumHeaderSize = 16
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// CorruptionError is returned when stored data does not match its checksum
+type CorruptionError struct {
+	// Name of the corrupt file
+	File string
+	// Position of the corrupt block
+	Offset int64
+}
+
+func (e *CorruptionError) Error() string {
+	return fmt.Sprintf("collie: checksum mismatch in %s at byte %d", e.File, e.Offset)
+}
+
+// checksums maintains CRC32C checksums for each block of a data file.
+//
+// Checksums are stored in a separate file with the suffix '.crc'. The file
+// starts with a header, holding the number of complete blocks as well as
+// the length and checksum of the trailing, incomplete block, followed by
+// the checksums of all complete blocks. Writes update the checksums in
+// memory, they are only stored by Flush. Data appended by other means,
+// e.g. by files written before checksums were introduced, is covered when
+// the checksums are opened.
+//
+// Reads verify all blocks they touch, the first read of a complete block
+// costs an additional read of up to one block before and after the
+// requested range. Complete blocks are only verified once, until the
+// checksums are reloaded, use Scrub to re-verify all data.
+type checksums struct {
+	file *os.File
+	data *os.File
+
+	sums     []uint32 // checksums of complete blocks
+	verified []uint64 // bitmap of verified complete blocks
+	tail     uint32   // checksum of the trailing block
+	tlen     int64    // length of the trailing block
+	stored   int      // number of complete block checksums on disk
+	dirty    bool     // the header is out of date
+	mu       sync.RWMutex
+}
+
+// openChecksums opens the checksums of data
+func openChecksums(data *os.File) (*checksums, error) {
+	file, err := os.OpenFile(data.Name()+".crc", os.O_CREATE|os.O_RDWR, 0664)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &checksums{file: file, data: data}
+	if err := s.open(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// open loads the checksums and covers all data appended by other means
+func (s *checksums) open() error {
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	info, err := s.data.Stat()
+	if err != nil {
+		return err
+	} else if info.Size() == s.covered() {
+		return nil
+	} else if err := s.catchUp(info.Size()); err != nil {
+		return err
+	}
+	return s.persist()
+}
+
+// ReadAt reads len(p) bytes from the data file and verifies all
+// covered blocks
+func (s *checksums) ReadAt(p []byte, off int64) error {
+	err := s.readAt(p, off)
+	if _, ok := err.(*CorruptionError); ok {
+		// Checksums may have been updated by another process, retry
+		if err := s.Reload(); err != nil {
+			return err
+		}
+		err = s.readAt(p, off)
+	}
+	return err
+}
+
+// WriteAt writes p to the data file at off, discarding the checksums of
+// all data beyond off. Checksums of p are stored by the next Flush.
+func (s *checksums) WriteAt(p []byte, off int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if covered := s.covered(); off < covered {
+		if err := s.cut(off); err != nil {
+			return err
+		}
+	} else if off > covered {
+		if err := s.catchUp(off); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.data.WriteAt(p, off); err != nil {
+		return err
+	}
+	s.extend(p)
+	s.dirty = true
+	return nil
+}
+
+// Flush stores all checksums written since the last flush
+func (s *checksums) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+	return s.persist()
+}
+
+// Truncate truncates the data file to size
+func (s *checksums) Truncate(size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if size < s.covered() {
+		if err := s.cut(size); err != nil {
+			return err
+		}
+	}
+	return s.data.Truncate(size)
+}
+
+// Reload picks up checksums written by other processes, after flushing
+// its own
+func (s *checksums) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dirty {
+		if err := s.persist(); err != nil {
+			return err
+		}
+	}
+	return s.load()
+}
+
+// Scrub verifies all covered blocks
+func (s *checksums) Scrub() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	buf := make([]byte, checksumBlockSize)
+	for pos, covered := int64(0), s.covered(); pos < covered; pos += checksumBlockSize {
+		n := covered - pos
+		if n > checksumBlockSize {
+			n = checksumBlockSize
+		}
+		if _, err := s.data.ReadAt(buf[:n], pos); err != nil {
+			return err
+		}
+		if err := s.check(buf[:n], pos); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close flushes and closes the checksum file
+func (s *checksums) Close() error {
+	err := s.Flush()
+	if e := s.file.Close(); e != nil {
+		err = e
+	}
+	return err
+}
+
+func (s *checksums) covered() int64 {
+	return int64(len(s.sums))*checksumBlockSize + s.tlen
+}
+
+func (s *checksums) readAt(p []byte, off int64) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	end := off + int64(len(p))
+	start := off - off%checksumBlockSize
+	vend := (end + checksumBlockSize - 1) / checksumBlockSize * checksumBlockSize
+	if covered := s.covered(); vend > covered {
+		vend = covered
+	}
+	if off < 0 || vend <= off || s.isVerified(start, vend) {
+		_, err := s.data.ReadAt(p, off)
+		return err
+	}
+
+	buf := make([]byte, vend-start)
+	if _, err := s.data.ReadAt(buf, start); err != nil {
+		return err
+	}
+	if err := s.check(buf, start); err != nil {
+		return err
+	}
+	s.markVerified(start, vend)
+
+	if n := copy(p, buf[off-start:]); n < len(p) {
+		_, err := s.data.ReadAt(p[n:], off+int64(n))
+		return err
+	}
+	return nil
+}
+
+// isVerified returns true if all blocks between start and end are
+// complete and verified
+func (s *checksums) isVerified(start, end int64) bool {
+	for n := start / checksumBlockSize; n*checksumBlockSize < end; n++ {
+		if n >= int64(len(s.sums)) || atomic.LoadUint64(&s.verified[n/64])&(1<<uint(n%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// markVerified marks all complete blocks between start and end as verified.
+// Safe to call while holding the read lock.
+func (s *checksums) markVerified(start, end int64) {
+	for n := start / checksumBlockSize; n*checksumBlockSize < end && n < int64(len(s.sums)); n++ {
+		addr, bit := &s.verified[n/64], uint64(1)<<uint(n%64)
+		for v := atomic.LoadUint64(addr); v&bit == 0; v = atomic.LoadUint64(addr) {
+			if atomic.CompareAndSwapUint64(addr, v, v|bit) {
+				break
+			}
+		}
+	}
+}
+
+// check verifies buf, read from start
+func (s *checksums) check(buf []byte, start int64) error {
+	for i := 0; i < len(buf); i += checksumBlockSize {
+		j := i + checksumBlockSize
+		if j > len(buf) {
+			j = len(buf)
+		}
+
+		want := s.tail
+		if n := int((start + int64(i)) / checksumBlockSize); n < len(s.sums) {
+			want = s.sums[n]
+		}
+		if crc32.Checksum(buf[i:j], castagnoli) != want {
+			return &CorruptionError{File: s.data.Name(), Offset: start + int64(i)}
+		}
+	}
+	return nil
+}
+
+// extend extends the checksums by p, appended to the covered data
+func (s *checksums) extend(p []byte) {
+	for len(p) > 0 {
+		n := checksumBlockSize - s.tlen
+		if n > int64(len(p)) {
+			n = int64(len(p))
+		}
+
+		s.tail = crc32.Update(s.tail, castagnoli, p[:n])
+		s.tlen += n
+		p = p[n:]
+
+		if s.tlen == checksumBlockSize {
+			s.sums = append(s.sums, s.tail)
+			s.tail, s.tlen = 0, 0
+			if n := (len(s.sums) + 63) / 64; n > len(s.verified) {
+				s.verified = append(s.verified, 0)
+			}
+		}
+	}
+}
+
+// catchUp extends the checksums by the data up to pos
+func (s *checksums) catchUp(pos int64) error {
+	buf := make([]byte, checksumBlockSize)
+	for covered := s.covered(); covered < pos; covered = s.covered() {
+		n := checksumBlockSize - s.tlen
+		if rest := pos - covered; n > rest {
+			n = rest
+		}
+		if _, err := s.data.ReadAt(buf[:n], covered); err != nil {
+			return err
+		}
+		s.extend(buf[:n])
+	}
+	return nil
+}
+
+// cut discards the checksums of all data beyond size
+func (s *checksums) cut(size int64) error {
+	n := size / checksumBlockSize
+	s.sums = s.sums[:n]
+	s.tail, s.tlen = 0, 0
+	s.unverify()
+
+	buf := make([]byte, size-n*checksumBlockSize)
+	if _, err := s.data.ReadAt(buf, n*checksumBlockSize); err != nil {
+		return err
+	}
+	s.extend(buf)
+	return s.persist()
+}
+
+// persist writes all pending checksums to disk. Checksums of complete
+// blocks are written before the header, which holds their number.
+func (s *checksums) persist() error {
+	if pending := len(s.sums) - s.stored; pending > 0 {
+		buf := make([]byte, 4*pending)
+		for i, sum := range s.sums[s.stored:] {
+			binary.BigEndian.PutUint32(buf[4*i:], sum)
+		}
+		if _, err := s.file.WriteAt(buf, checksumHeaderSize+4*int64(s.stored)); err != nil {
+			return err
+		}
+	}
+
+	hdr := make([]byte, checksumHeaderSize)
+	binary.BigEndian.PutUint64(hdr[0:], uint64(len(s.sums)))
+	binary.BigEndian.PutUint32(hdr[8:], uint32(s.tlen))
+	binary.BigEndian.PutUint32(hdr[12:], s.tail)
+	if _, err := s.file.WriteAt(hdr, 0); err != nil {
+		return err
+	}
+
+	if len(s.sums) < s.stored {
+		if err := s.file.Truncate(checksumHeaderSize + 4*int64(len(s.sums))); err != nil {
+			return err
+		}
+	}
+	s.stored = len(s.sums)
+	s.dirty = false
+	return nil
+}
+
+// load reads checksums from disk, discarding all beyond the end of
+// the data file
+func (s *checksums) load() error {
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, info.Size())
+	if _, err := s.file.ReadAt(buf, 0); err != nil && err != io.EOF {
+		return err
+	}
+
+	s.sums, s.tail, s.tlen = s.sums[:0], 0, 0
+	if len(buf) >= checksumHeaderSize {
+		n := binary.BigEndian.Uint64(buf[0:])
+		for i := checksumHeaderSize; i+4 <= len(buf) && uint64(len(s.sums)) < n; i += 4 {
+			s.sums = append(s.sums, binary.BigEndian.Uint32(buf[i:]))
+		}
+		if uint64(len(s.sums)) == n {
+			s.tlen = int64(binary.BigEndian.Uint32(buf[8:]))
+			s.tail = binary.BigEndian.Uint32(buf[12:])
+		}
+	}
+
+	if info, err = s.data.Stat(); err != nil {
+		return err
+	}
+	if n := info.Size() / checksumBlockSize; n < int64(len(s.sums)) {
+		s.sums = s.sums[:n]
+		s.tail, s.tlen = 0, 0
+	}
+	if s.tlen >= checksumBlockSize || s.covered() > info.Size() {
+		s.tail, s.tlen = 0, 0
+	}
+	s.stored, s.dirty = len(s.sums), false
+	s.verified = make([]uint64, (len(s.sums)+63)/64)
+	return nil
+}
+
+// unverify clears the verified flags of all blocks beyond the complete
+// ones and shrinks the bitmap accordingly
+func (s *checksums) unverify() {
+	n := len(s.sums)
+	s.verified = s.verified[:(n+63)/64]
+	if n%64 != 0 {
+		s.verified[n/64] &= 1<<uint(n%64) - 1
+	}
+}
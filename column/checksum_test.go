@@ -0,0 +1,167 @@
+package column
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("checksums", func() {
+	var subject *checksums
+	var data *os.File
+
+	open := func() *checksums {
+		sums, err := openChecksums(data)
+		Expect(err).NotTo(HaveOccurred())
+		return sums
+	}
+
+	read := func(off, n int64) ([]byte, error) {
+		buf := make([]byte, n)
+		err := subject.ReadAt(buf, off)
+		return buf, err
+	}
+
+	corrupt := func(off int64) {
+		_, err := data.WriteAt([]byte{'X'}, off)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		var err error
+		data, err = os.OpenFile(filepath.Join(testDir, "data"), os.O_CREATE|os.O_RDWR, 0664)
+		Expect(err).NotTo(HaveOccurred())
+		subject = open()
+
+		Expect(subject.WriteAt(bytes.Repeat([]byte("a"), 5000), 0)).To(Succeed())
+		Expect(subject.WriteAt(bytes.Repeat([]byte("b"), 4000), 5000)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+		data.Close()
+	})
+
+	It("should maintain checksums", func() {
+		Expect(subject.sums).To(HaveLen(2))
+		Expect(subject.tlen).To(Equal(int64(808)))
+		Expect(subject.covered()).To(Equal(int64(9000)))
+
+		info, err := subject.file.Stat()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Size()).To(Equal(int64(0)))
+
+		Expect(subject.Flush()).To(Succeed())
+		info, err = subject.file.Stat()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Size()).To(Equal(int64(24)))
+	})
+
+	It("should read and verify", func() {
+		Expect(read(4998, 4)).To(Equal([]byte("aabb")))
+		Expect(read(8998, 2)).To(Equal([]byte("bb")))
+		Expect(subject.Scrub()).To(Succeed())
+
+		_, err := read(8999, 2)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should detect corruption", func() {
+		corrupt(4500)
+
+		Expect(read(0, 4)).To(Equal([]byte("aaaa")))
+		_, err := read(8000, 4)
+		Expect(err).To(Equal(&CorruptionError{File: data.Name(), Offset: 4096}))
+		Expect(subject.Scrub()).To(Equal(&CorruptionError{File: data.Name(), Offset: 4096}))
+
+		corrupt(8999)
+		_, err = read(8999, 1)
+		Expect(err).To(Equal(&CorruptionError{File: data.Name(), Offset: 8192}))
+		Expect(err.Error()).To(HaveSuffix(" at byte 8192"))
+	})
+
+	It("should verify complete blocks once", func() {
+		Expect(read(10, 4)).To(Equal([]byte("aaaa")))
+		corrupt(100)
+		Expect(read(10, 4)).To(Equal([]byte("aaaa")))
+		Expect(subject.Scrub()).To(Equal(&CorruptionError{File: data.Name(), Offset: 0}))
+
+		Expect(subject.Reload()).To(Succeed())
+		_, err := read(10, 4)
+		Expect(err).To(Equal(&CorruptionError{File: data.Name(), Offset: 0}))
+
+		Expect(read(8998, 2)).To(Equal([]byte("bb")))
+		corrupt(8999)
+		_, err = read(8998, 2)
+		Expect(err).To(Equal(&CorruptionError{File: data.Name(), Offset: 8192}))
+	})
+
+	It("should reopen", func() {
+		Expect(subject.Close()).To(Succeed())
+		subject = open()
+		Expect(subject.sums).To(HaveLen(2))
+		Expect(subject.covered()).To(Equal(int64(9000)))
+
+		corrupt(100)
+		Expect(subject.Scrub()).To(Equal(&CorruptionError{File: data.Name(), Offset: 0}))
+	})
+
+	It("should truncate and overwrite", func() {
+		Expect(subject.Truncate(4100)).To(Succeed())
+		Expect(subject.covered()).To(Equal(int64(4100)))
+		Expect(subject.Scrub()).To(Succeed())
+
+		Expect(subject.WriteAt([]byte("cc"), 4000)).To(Succeed())
+		Expect(subject.covered()).To(Equal(int64(4002)))
+		Expect(read(3999, 3)).To(Equal([]byte("acc")))
+		Expect(subject.Scrub()).To(Succeed())
+
+		Expect(subject.Close()).To(Succeed())
+		subject = open()
+		Expect(subject.covered()).To(Equal(int64(4100)))
+		Expect(subject.Scrub()).To(Succeed())
+	})
+
+	It("should catch up with uncovered data", func() {
+		Expect(subject.Close()).To(Succeed())
+		Expect(os.Remove(data.Name() + ".crc")).To(Succeed())
+		subject = open()
+		Expect(subject.covered()).To(Equal(int64(9000)))
+		Expect(subject.stored).To(Equal(2))
+		Expect(read(0, 4)).To(Equal([]byte("aaaa")))
+		Expect(subject.Scrub()).To(Succeed())
+
+		_, err := data.WriteAt([]byte("c"), 9000)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.WriteAt([]byte("d"), 9001)).To(Succeed())
+		Expect(subject.covered()).To(Equal(int64(9002)))
+		Expect(subject.Scrub()).To(Succeed())
+	})
+
+	It("should discard checksums beyond the data", func() {
+		Expect(data.Truncate(6000)).To(Succeed())
+		Expect(subject.Reload()).To(Succeed())
+		Expect(subject.covered()).To(Equal(int64(4096)))
+		Expect(subject.Scrub()).To(Succeed())
+	})
+
+	It("should pick up checksums written by others", func() {
+		other := open()
+		defer other.Close()
+
+		Expect(subject.WriteAt(bytes.Repeat([]byte("c"), 100), 9000)).To(Succeed())
+		Expect(other.Reload()).To(Succeed())
+		Expect(other.covered()).To(Equal(int64(9000)))
+
+		Expect(subject.Flush()).To(Succeed())
+
+		corrupt(9050)
+		Expect(other.Reload()).To(Succeed())
+		Expect(other.covered()).To(Equal(int64(9100)))
+		Expect(other.Scrub()).To(Equal(&CorruptionError{File: data.Name(), Offset: 8192}))
+	})
+
+})
@@ -14,7 +14,15 @@ type Column interface {
 	Add([]byte) error
 	Get(int64) ([]byte, error)
 	Len() int64
+	// Size returns the number of bytes stored
+	Size() int64
 	Truncate(int64) error
+	// Refresh picks up rows appended by other processes
+	Refresh() error
+	// Scrub verifies the checksums of all stored data
+	Scrub() error
+	// Flush stores the checksums of all added values
+	Flush() error
 	Close() error
 }
 
@@ -22,28 +30,56 @@ type Column interface {
 
 type abstract struct {
 	file *os.File
+	sums *checksums
 	rows int64
 }
 
+func newAbstract(file *os.File, rows int64) (abstract, error) {
+	sums, err := openChecksums(file)
+	if err != nil {
+		file.Close()
+		return abstract{}, err
+	}
+	return abstract{file: file, sums: sums, rows: rows}, nil
+}
+
 func (c *abstract) Close() (err error) {
+	if c.sums != nil {
+		err = c.sums.Close()
+		c.sums = nil
+	}
 	if c.file != nil {
-		err = c.file.Close()
+		if e := c.file.Close(); e != nil {
+			err = e
+		}
 		c.file = nil
 	}
 	return
 }
 
+func (c *abstract) Flush() error {
+	return c.sums.Flush()
+}
+
 func (c *abstract) Len() int64  { return atomic.LoadInt64(&c.rows) }
 func (c *abstract) inc(n int64) { atomic.AddInt64(&c.rows, n) }
 func (c *abstract) set(n int64) { atomic.StoreInt64(&c.rows, n) }
 func (c *abstract) truncate(pos, off int64) error {
-	err := c.file.Truncate(pos)
+	err := c.sums.Truncate(pos)
 	if err == nil {
 		c.set(off)
 	}
 	return err
 }
 
+func (c *abstract) size() (int64, error) {
+	info, err := c.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
 // HELPERS
 
 func openFile(fname string) (*os.File, int64, error) {
@@ -12,13 +12,17 @@ func OpenFixed(fname string, maxLen int) (*Fixed, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Fixed{abstract{file, total / int64(maxLen)}, maxLen}, nil
+	abs, err := newAbstract(file, total/int64(maxLen))
+	if err != nil {
+		return nil, err
+	}
+	return &Fixed{abs, maxLen}, nil
 }
 
 func (c *Fixed) Get(offset int64) ([]byte, error) {
 	min := offset * int64(c.maxLen)
 	buf := make([]byte, c.maxLen)
-	if _, err := c.file.ReadAt(buf, min); err != nil {
+	if err := c.sums.ReadAt(buf, min); err != nil {
 		return nil, checkNotFound(err)
 	}
 	return buf, nil
@@ -32,13 +36,33 @@ func (c *Fixed) Add(b []byte) error {
 	buf := make([]byte, c.maxLen)
 	copy(buf, b)
 
-	_, err := c.file.WriteAt(buf, c.Len()*int64(c.maxLen))
+	err := c.sums.WriteAt(buf, c.Len()*int64(c.maxLen))
 	if err == nil {
 		c.inc(1)
 	}
 	return err
 }
 
+func (c *Fixed) Size() int64 {
+	return c.Len() * int64(c.maxLen)
+}
+
+func (c *Fixed) Refresh() error {
+	if err := c.sums.Reload(); err != nil {
+		return err
+	}
+
+	size, err := c.size()
+	if err == nil {
+		c.set(size / int64(c.maxLen))
+	}
+	return err
+}
+
+func (c *Fixed) Scrub() error {
+	return c.sums.Scrub()
+}
+
 func (c *Fixed) Truncate(offset int64) error {
 	return c.truncate(offset*int64(c.maxLen), offset)
 }
@@ -46,6 +46,7 @@ var _ = Describe("Fixed", func() {
 		fill()
 		Expect(subject.rows).To(Equal(int64(9)))
 		Expect(subject.Len()).To(Equal(int64(9)))
+		Expect(subject.Size()).To(Equal(int64(36)))
 	})
 
 	It("should reopen columns", func() {
@@ -59,6 +60,18 @@ var _ = Describe("Fixed", func() {
 		Expect(subject.Len()).To(Equal(int64(9)))
 	})
 
+	It("should refresh rows appended by other writers", func() {
+		other, err := OpenFixed(filepath.Join(testDir, "col"), 4)
+		Expect(err).NotTo(HaveOccurred())
+		defer other.Close()
+
+		fill()
+		Expect(other.Len()).To(Equal(int64(0)))
+		Expect(other.Refresh()).NotTo(HaveOccurred())
+		Expect(other.Len()).To(Equal(int64(9)))
+		Expect(other.Get(8)).To(Equal([]byte{'a', 0, 0, 0}))
+	})
+
 	It("should recover from partial writes", func() {
 		fill()
 		_, err := subject.file.WriteAt([]byte{'x', 'y', 'z'}, 36)
@@ -102,6 +115,17 @@ var _ = Describe("Fixed", func() {
 		Expect(err).To(Equal(ErrNotFound))
 	})
 
+	It("should detect corruption", func() {
+		fill()
+		Expect(subject.Scrub()).To(Succeed())
+
+		_, err := subject.file.WriteAt([]byte{'x'}, 5)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = subject.Get(2)
+		Expect(err).To(BeAssignableToTypeOf(&CorruptionError{}))
+		Expect(subject.Scrub()).To(Equal(&CorruptionError{File: subject.file.Name(), Offset: 0}))
+	})
+
 	It("should read/write concurrently", func() {
 		wait := sync.Mutex{}
 		wait.Lock()
@@ -3,34 +3,58 @@ package column
 import (
 	"bytes"
 	"encoding/binary"
-	"hash/fnv"
-	"sync"
+	"errors"
 
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
-// Hash buckets
-
-const hashBuckets = 4096
-
-func hashBucket(key []byte) int {
-	hash := fnv.New32a()
-	_, _ = hash.Write(key)
-	return int(hash.Sum32() % hashBuckets)
-}
+// Postings map index values to offsets
+type Postings map[string][]int64
 
 // Index interface
 type Index interface {
 	Get([]byte) ([]int64, error)
 	Add([]byte, ...int64) error
-	Undo([]byte, int64) error
+	// Write adds all postings atomically
+	Write(Postings) error
+	// Remove removes all postings atomically
+	Remove(Postings) error
+	// Iterate iterates over all values with a given prefix
+	Iterate([]byte) IndexIterator
 	Close() error
 }
 
+// IndexIterator iterates over distinct index values in ascending
+// order, must be released after use
+type IndexIterator interface {
+	Next() bool
+	// Value returns the current value
+	Value() []byte
+	// Offsets returns the ascending offsets of the current value
+	Offsets() []int64
+	Error() error
+	Release()
+}
+
+// IndexSnapshot is a consistent, point-in-time view of an index,
+// must be released after use
+type IndexSnapshot interface {
+	// Iterate iterates over all values with a given prefix
+	Iterate([]byte) IndexIterator
+	Release()
+}
+
 // A Hash index type
+//
+// Postings are stored as individual leveldb keys, each composed of the
+// escaped index value, a terminator and the big-endian offset. Appends
+// are therefore constant-cost and the postings of a value can be
+// retrieved in ascending order with a single range scan.
 type HashIndex struct {
-	db    *leveldb.DB
-	locks []sync.Mutex
+	db *leveldb.DB
 }
 
 // OpenHashIndex opens a HashIndex in dir
@@ -39,70 +63,245 @@ func OpenHashIndex(dir string) (*HashIndex, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &HashIndex{db, make([]sync.Mutex, hashBuckets)}, nil
+
+	idx := &HashIndex{db}
+	if err := idx.upgrade(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return idx, nil
+}
+
+// OpenHashIndexReadOnly opens a HashIndex in dir without write access.
+// Indices stored in the legacy layout must be opened for writing once
+// to be upgraded.
+func OpenHashIndexReadOnly(dir string) (*HashIndex, error) {
+	db, err := leveldb.OpenFile(dir, &opt.Options{ReadOnly: true, ErrorIfMissing: true})
+	if err != nil {
+		return nil, err
+	}
+	return &HashIndex{db}, nil
 }
 
 func (i *HashIndex) Add(b []byte, offs ...int64) error {
 	if b == nil {
 		return nil
 	}
+	return i.Write(Postings{string(b): offs})
+}
 
-	buf := make([]byte, 8*len(offs))
-	for i := 0; i < len(offs); i++ {
-		binary.BigEndian.PutUint64(buf[i*8:], uint64(offs[i]))
+func (i *HashIndex) Write(p Postings) error {
+	batch := new(leveldb.Batch)
+	for val, offs := range p {
+		for _, off := range offs {
+			batch.Put(postingKey([]byte(val), off), nil)
+		}
 	}
+	return i.db.Write(batch, nil)
+}
 
-	slot := hashBucket(b)
-	i.locks[slot].Lock()
-	defer i.locks[slot].Unlock()
-
-	val, err := i.db.Get(b, nil)
-	if err != nil && err != leveldb.ErrNotFound {
-		return err
+func (i *HashIndex) Remove(p Postings) error {
+	batch := new(leveldb.Batch)
+	for val, offs := range p {
+		for _, off := range offs {
+			batch.Delete(postingKey([]byte(val), off))
+		}
 	}
-	return i.db.Put(b, append(val, buf...), nil)
+	return i.db.Write(batch, nil)
 }
 
 func (i *HashIndex) Get(b []byte) ([]int64, error) {
-	val, err := i.db.Get(b, nil)
-	if err == leveldb.ErrNotFound {
-		return nil, nil
-	} else if err != nil {
+	var res []int64
+
+	iter := i.db.NewIterator(postingRange(b), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		key := iter.Key()
+		res = append(res, int64(binary.BigEndian.Uint64(key[len(key)-8:])))
+	}
+	return res, iter.Error()
+}
+
+func (i *HashIndex) Iterate(prefix []byte) IndexIterator {
+	return &hashIndexIterator{iter: i.db.NewIterator(prefixRange(prefix), nil)}
+}
+
+// Snapshot returns a point-in-time view of the index
+func (i *HashIndex) Snapshot() (IndexSnapshot, error) {
+	snap, err := i.db.GetSnapshot()
+	if err != nil {
 		return nil, err
 	}
+	return &hashIndexSnapshot{snap}, nil
+}
+
+func (i *HashIndex) Close() error {
+	return i.db.Close()
+}
+
+// upgrade converts postings stored in the legacy layout, where
+// each value key held the complete list of offsets
+func (i *HashIndex) upgrade() error {
+	if ok, err := i.db.Has(hashIndexVersionKey, nil); err != nil || ok {
+		return err
+	}
+
+	batch := new(leveldb.Batch)
+	iter := i.db.NewIterator(nil, nil)
+	for iter.Next() {
+		key, val := iter.Key(), iter.Value()
+		if len(val) == 0 {
+			continue
+		}
+
+		batch.Delete(key)
+		for n := 0; n+8 <= len(val); n += 8 {
+			batch.Put(postingKey(key, int64(binary.BigEndian.Uint64(val[n:]))), nil)
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	batch.Put(hashIndexVersionKey, []byte{hashIndexVersion})
+	return i.db.Write(batch, nil)
+}
+
+// Key layout
 
-	res := make([]int64, 0, len(val)/8)
-	for i := 0; i < len(val); i += 8 {
-		off := binary.BigEndian.Uint64(val[i : i+8])
-		res = append(res, int64(off))
+const hashIndexVersion = 2
+
+// Keys starting with two zero bytes never occur in the posting
+// layout and are reserved for metadata
+var hashIndexVersionKey = []byte("\x00\x00version")
+
+// escapeValue appends the order-preserving encoding of val to dst,
+// zero bytes are escaped as 0x00 0xFF
+func escapeValue(dst, val []byte) []byte {
+	for _, c := range val {
+		if c == 0 {
+			dst = append(dst, 0, 0xFF)
+		} else {
+			dst = append(dst, c)
+		}
 	}
-	return res, nil
+	return dst
 }
 
-func (i *HashIndex) Undo(b []byte, off int64) error {
+// valuePrefix returns the key prefix shared by all postings of val
+func valuePrefix(val []byte) []byte {
+	pfx := make([]byte, 0, len(val)+2+8)
+	pfx = escapeValue(pfx, val)
+	return append(pfx, 0, 1)
+}
+
+func postingKey(val []byte, off int64) []byte {
 	buf := make([]byte, 8)
 	binary.BigEndian.PutUint64(buf, uint64(off))
+	return append(valuePrefix(val), buf...)
+}
 
-	slot := hashBucket(b)
-	i.locks[slot].Lock()
-	defer i.locks[slot].Unlock()
+func postingRange(val []byte) *util.Range {
+	start := valuePrefix(val)
+	limit := append(start[:len(start)-1:len(start)-1], 2)
+	return &util.Range{Start: start, Limit: limit}
+}
 
-	val, err := i.db.Get(b, nil)
-	vlen := len(val)
-	if err == leveldb.ErrNotFound || vlen < 8 {
-		return nil
-	} else if err != nil {
-		return err
+// prefixRange returns the range of all postings with
+// values starting with prefix
+func prefixRange(prefix []byte) *util.Range {
+	if len(prefix) == 0 {
+		return &util.Range{Start: []byte{0, 1}}
 	}
+	return util.BytesPrefix(escapeValue(nil, prefix))
+}
 
-	if vlen == 8 && bytes.Equal(buf, val) {
-		return i.db.Delete(b, nil)
-	} else if bytes.Equal(buf, val[vlen-8:vlen]) {
-		return i.db.Put(b, val[:vlen-8], nil)
+// unescapeValue decodes a value prefix
+func unescapeValue(enc []byte) ([]byte, bool) {
+	val := make([]byte, 0, len(enc))
+	for n := 0; n < len(enc); n++ {
+		if enc[n] != 0 {
+			val = append(val, enc[n])
+		} else if n+1 < len(enc) && enc[n+1] == 0xFF {
+			val = append(val, 0)
+			n++
+		} else if n+2 == len(enc) && enc[n+1] == 1 {
+			return val, true
+		} else {
+			break
+		}
 	}
-	return nil
+	return nil, false
 }
 
-func (i *HashIndex) Close() error {
-	return i.db.Close()
+// Iterator
+
+var errBadPostingKey = errors.New("collie: bad posting key")
+
+type hashIndexSnapshot struct {
+	snap *leveldb.Snapshot
+}
+
+func (s *hashIndexSnapshot) Iterate(prefix []byte) IndexIterator {
+	return &hashIndexIterator{iter: s.snap.NewIterator(prefixRange(prefix), nil)}
+}
+
+func (s *hashIndexSnapshot) Release() { s.snap.Release() }
+
+type hashIndexIterator struct {
+	iter    iterator.Iterator
+	started bool
+	pending bool
+
+	enc  []byte
+	val  []byte
+	offs []int64
+	err  error
+}
+
+func (i *hashIndexIterator) Next() bool {
+	if !i.started {
+		i.started = true
+		i.pending = i.iter.Next()
+	}
+	if !i.pending || i.err != nil {
+		return false
+	}
+
+	key := i.iter.Key()
+	if len(key) < 10 {
+		i.err = errBadPostingKey
+		return false
+	}
+
+	var ok bool
+	i.enc = append(i.enc[:0], key[:len(key)-8]...)
+	if i.val, ok = unescapeValue(i.enc); !ok {
+		i.err = errBadPostingKey
+		return false
+	}
+
+	i.offs = nil
+	for i.pending {
+		key = i.iter.Key()
+		if len(key) < 10 || !bytes.Equal(key[:len(key)-8], i.enc) {
+			break
+		}
+		i.offs = append(i.offs, int64(binary.BigEndian.Uint64(key[len(key)-8:])))
+		i.pending = i.iter.Next()
+	}
+	return true
+}
+
+func (i *hashIndexIterator) Value() []byte    { return i.val }
+func (i *hashIndexIterator) Offsets() []int64 { return i.offs }
+func (i *hashIndexIterator) Release()         { i.iter.Release() }
+
+func (i *hashIndexIterator) Error() error {
+	if i.err != nil {
+		return i.err
+	}
+	return i.iter.Error()
 }
@@ -1,11 +1,13 @@
 package column
 
 import (
+	"os"
 	"path/filepath"
 	"sync"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/syndtr/goleveldb/leveldb"
 )
 
 var _ = Describe("HashIndex", func() {
@@ -48,22 +50,150 @@ var _ = Describe("HashIndex", func() {
 		Expect(offs).To(BeEmpty())
 	})
 
-	It("should undo", func() {
-		Expect(subject.Undo([]byte("a"), 1)).NotTo(HaveOccurred())
+	It("should write/remove postings", func() {
+		Expect(subject.Remove(Postings{"a": {1}})).NotTo(HaveOccurred())
 		offs, err := subject.Get([]byte("a"))
 		Expect(err).NotTo(HaveOccurred())
 		Expect(offs).To(BeNil())
 
-		fill()
-		Expect(subject.Undo([]byte("a"), 1)).NotTo(HaveOccurred())
+		Expect(subject.Write(Postings{"a": {1, 2}, "b": {3}, "c": {4}})).NotTo(HaveOccurred())
 		offs, err = subject.Get([]byte("a"))
 		Expect(err).NotTo(HaveOccurred())
 		Expect(offs).To(Equal([]int64{1, 2}))
 
-		Expect(subject.Undo([]byte("a"), 2)).NotTo(HaveOccurred())
+		Expect(subject.Remove(Postings{"a": {1}, "c": {4}})).NotTo(HaveOccurred())
 		offs, err = subject.Get([]byte("a"))
 		Expect(err).NotTo(HaveOccurred())
+		Expect(offs).To(Equal([]int64{2}))
+		offs, err = subject.Get([]byte("b"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offs).To(Equal([]int64{3}))
+		offs, err = subject.Get([]byte("c"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offs).To(BeNil())
+	})
+
+	It("should keep values apart", func() {
+		Expect(subject.Add([]byte{'a'}, 1)).NotTo(HaveOccurred())
+		Expect(subject.Add([]byte{'a', 0}, 2)).NotTo(HaveOccurred())
+		Expect(subject.Add([]byte{'a', 0, 1}, 3)).NotTo(HaveOccurred())
+		Expect(subject.Add([]byte{'a', 1}, 4)).NotTo(HaveOccurred())
+		Expect(subject.Add([]byte{}, 5)).NotTo(HaveOccurred())
+
+		offs, err := subject.Get([]byte{'a'})
+		Expect(err).NotTo(HaveOccurred())
 		Expect(offs).To(Equal([]int64{1}))
+		offs, err = subject.Get([]byte{'a', 0})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offs).To(Equal([]int64{2}))
+		offs, err = subject.Get([]byte{'a', 0, 1})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offs).To(Equal([]int64{3}))
+		offs, err = subject.Get([]byte{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offs).To(Equal([]int64{5}))
+	})
+
+	It("should return offsets in order", func() {
+		Expect(subject.Add([]byte("a"), 300, 7)).NotTo(HaveOccurred())
+		Expect(subject.Add([]byte("a"), 256)).NotTo(HaveOccurred())
+		Expect(subject.Add([]byte("a"), 1)).NotTo(HaveOccurred())
+
+		offs, err := subject.Get([]byte("a"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offs).To(Equal([]int64{1, 7, 256, 300}))
+	})
+
+	It("should iterate over values", func() {
+		fill()
+		Expect(subject.Add([]byte("ab"), 4, 5)).NotTo(HaveOccurred())
+		Expect(subject.Add([]byte{'a', 0}, 6)).NotTo(HaveOccurred())
+
+		type pair struct {
+			val  string
+			offs []int64
+		}
+		iterate := func(prefix string) []pair {
+			iter := subject.Iterate([]byte(prefix))
+			defer iter.Release()
+
+			var res []pair
+			for iter.Next() {
+				res = append(res, pair{string(iter.Value()), iter.Offsets()})
+			}
+			Expect(iter.Error()).NotTo(HaveOccurred())
+			return res
+		}
+
+		Expect(iterate("")).To(Equal([]pair{
+			{"a", []int64{1, 2}},
+			{"a\x00", []int64{6}},
+			{"ab", []int64{4, 5}},
+			{"b", []int64{3}},
+		}))
+		Expect(iterate("a")).To(Equal([]pair{
+			{"a", []int64{1, 2}},
+			{"a\x00", []int64{6}},
+			{"ab", []int64{4, 5}},
+		}))
+		Expect(iterate("a\x00")).To(Equal([]pair{
+			{"a\x00", []int64{6}},
+		}))
+		Expect(iterate("c")).To(BeEmpty())
+	})
+
+	It("should upgrade legacy layouts", func() {
+		Expect(subject.Close()).NotTo(HaveOccurred())
+		Expect(os.RemoveAll(filepath.Join(testDir, "index"))).NotTo(HaveOccurred())
+
+		db, err := leveldb.OpenFile(filepath.Join(testDir, "index"), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(db.Put([]byte("a"), []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 2}, nil)).NotTo(HaveOccurred())
+		Expect(db.Put([]byte("b"), []byte{0, 0, 0, 0, 0, 0, 0, 3}, nil)).NotTo(HaveOccurred())
+		Expect(db.Close()).NotTo(HaveOccurred())
+
+		subject, err = OpenHashIndex(filepath.Join(testDir, "index"))
+		Expect(err).NotTo(HaveOccurred())
+
+		offs, err := subject.Get([]byte("a"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offs).To(Equal([]int64{1, 2}))
+		offs, err = subject.Get([]byte("b"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offs).To(Equal([]int64{3}))
+	})
+
+	It("should take snapshots", func() {
+		Expect(subject.Add([]byte("a"), 1, 2)).NotTo(HaveOccurred())
+
+		snap, err := subject.Snapshot()
+		Expect(err).NotTo(HaveOccurred())
+		defer snap.Release()
+
+		Expect(subject.Add([]byte("a"), 3)).NotTo(HaveOccurred())
+		Expect(subject.Add([]byte("b"), 4)).NotTo(HaveOccurred())
+
+		iter := snap.Iterate(nil)
+		defer iter.Release()
+		Expect(iter.Next()).To(BeTrue())
+		Expect(iter.Value()).To(Equal([]byte("a")))
+		Expect(iter.Offsets()).To(Equal([]int64{1, 2}))
+		Expect(iter.Next()).To(BeFalse())
+		Expect(iter.Error()).NotTo(HaveOccurred())
+	})
+
+	It("should open read-only", func() {
+		Expect(subject.Add([]byte("a"), 1, 2)).NotTo(HaveOccurred())
+		Expect(subject.Close()).NotTo(HaveOccurred())
+
+		var err error
+		subject, err = OpenHashIndexReadOnly(filepath.Join(testDir, "index"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Get([]byte("a"))).To(Equal([]int64{1, 2}))
+		Expect(subject.Add([]byte("b"), 3)).To(HaveOccurred())
+
+		_, err = OpenHashIndexReadOnly(filepath.Join(testDir, "missing"))
+		Expect(err).To(HaveOccurred())
 	})
 
 	It("should add values atomically", func() {
@@ -0,0 +1,317 @@
+package column
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Writers mark memory indices as open by creating a file with this suffix
+const openMarkerSuffix = ".open"
+
+var (
+	errMemoryIndexFormat = errors.New("collie: invalid memory index file")
+	errReadOnly          = errors.New("collie: index is read-only")
+)
+
+// A Memory index type
+//
+// Postings are held in memory, trading durability for speed. They are
+// loaded when the index is opened and stored when it is closed. Postings
+// written since are lost on crashes. Indices opened read-only reflect the
+// state stored by the last writer. Writers keep a marker file next to the
+// index while open, indices not closed by their last writer report their
+// postings as lost.
+type MemoryIndex struct {
+	fname    string
+	readOnly bool
+	lost     bool
+
+	postings map[string][]int64
+	mu       sync.RWMutex
+}
+
+// OpenMemoryIndex opens a MemoryIndex, stored in fname
+func OpenMemoryIndex(fname string) (*MemoryIndex, error) {
+	return openMemoryIndex(fname, false)
+}
+
+// OpenMemoryIndexReadOnly opens a MemoryIndex, stored in fname, without
+// write access
+func OpenMemoryIndexReadOnly(fname string) (*MemoryIndex, error) {
+	return openMemoryIndex(fname, true)
+}
+
+func openMemoryIndex(fname string, readOnly bool) (*MemoryIndex, error) {
+	idx := &MemoryIndex{fname: fname, readOnly: readOnly, postings: make(map[string][]int64)}
+	if err := idx.load(); err != nil {
+		return nil, err
+	} else if readOnly {
+		return idx, nil
+	}
+
+	if _, err := os.Stat(fname + openMarkerSuffix); err == nil {
+		idx.lost = true
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(fname+openMarkerSuffix, nil, 0644); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Lost returns true if postings were lost, because a previous writer
+// did not close the index. Lost postings are reported until the index
+// files are removed.
+func (i *MemoryIndex) Lost() bool { return i.lost }
+
+func (i *MemoryIndex) Add(b []byte, offs ...int64) error {
+	if b == nil {
+		return nil
+	}
+	return i.Write(Postings{string(b): offs})
+}
+
+func (i *MemoryIndex) Write(p Postings) error {
+	if i.readOnly {
+		return errReadOnly
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for val, offs := range p {
+		list := i.postings[val]
+		for _, off := range offs {
+			if n := len(list); n == 0 || list[n-1] < off {
+				list = append(list, off)
+			} else if n = sort.Search(n, func(j int) bool { return list[j] >= off }); list[n] != off {
+				list = append(list, 0)
+				copy(list[n+1:], list[n:])
+				list[n] = off
+			}
+		}
+		if len(list) != 0 {
+			i.postings[val] = list
+		}
+	}
+	return nil
+}
+
+func (i *MemoryIndex) Remove(p Postings) error {
+	if i.readOnly {
+		return errReadOnly
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for val, offs := range p {
+		list := i.postings[val]
+		for _, off := range offs {
+			if n := sort.Search(len(list), func(j int) bool { return list[j] >= off }); n < len(list) && list[n] == off {
+				list = append(list[:n], list[n+1:]...)
+			}
+		}
+		if len(list) == 0 {
+			delete(i.postings, val)
+		} else {
+			i.postings[val] = list
+		}
+	}
+	return nil
+}
+
+func (i *MemoryIndex) Get(b []byte) ([]int64, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if list := i.postings[string(b)]; len(list) != 0 {
+		return append([]int64(nil), list...), nil
+	}
+	return nil, nil
+}
+
+func (i *MemoryIndex) Iterate(prefix []byte) IndexIterator {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return newMemoryIterator(i.postings, &i.mu, prefix)
+}
+
+// Snapshot returns a point-in-time view of the index
+func (i *MemoryIndex) Snapshot() (IndexSnapshot, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	snap := make(memoryIndexSnapshot, len(i.postings))
+	for val, list := range i.postings {
+		snap[val] = append([]int64(nil), list...)
+	}
+	return snap, nil
+}
+
+// Close stores the index, unless opened read-only
+func (i *MemoryIndex) Close() error {
+	if i.readOnly {
+		return nil
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if err := i.store(); err != nil || i.lost {
+		return err
+	}
+	return os.Remove(i.fname + openMarkerSuffix)
+}
+
+// load reads the stored postings, if any
+func (i *MemoryIndex) load() error {
+	file, err := os.Open(i.fname)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Lengths and counts cannot exceed the file size
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	size := uint64(info.Size())
+
+	r := bufio.NewReader(file)
+	for {
+		n, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return nil
+		} else if err != nil || n > size {
+			return errMemoryIndexFormat
+		}
+		val := make([]byte, int(n))
+		if _, err := io.ReadFull(r, val); err != nil {
+			return errMemoryIndexFormat
+		}
+
+		if n, err = binary.ReadUvarint(r); err != nil || n > size {
+			return errMemoryIndexFormat
+		}
+		list, off := make([]int64, 0, int(n)), int64(0)
+		for j := uint64(0); j < n; j++ {
+			delta, err := binary.ReadUvarint(r)
+			if err != nil {
+				return errMemoryIndexFormat
+			}
+			off += int64(delta)
+			list = append(list, off)
+		}
+		i.postings[string(val)] = list
+	}
+}
+
+// store writes all postings, as value-sorted, delta-encoded lists, and
+// atomically replaces the stored file
+func (i *MemoryIndex) store() error {
+	tmp := i.fname + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	vals := make([]string, 0, len(i.postings))
+	for val := range i.postings {
+		vals = append(vals, val)
+	}
+	sort.Strings(vals)
+
+	w := bufio.NewWriter(file)
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, val := range vals {
+		list := i.postings[val]
+		w.Write(buf[:binary.PutUvarint(buf, uint64(len(val)))])
+		w.WriteString(val)
+		w.Write(buf[:binary.PutUvarint(buf, uint64(len(list)))])
+		prev := int64(0)
+		for _, off := range list {
+			w.Write(buf[:binary.PutUvarint(buf, uint64(off-prev))])
+			prev = off
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, i.fname)
+}
+
+type memoryIndexSnapshot map[string][]int64
+
+func (s memoryIndexSnapshot) Iterate(prefix []byte) IndexIterator {
+	return newMemoryIterator(s, nil, prefix)
+}
+
+func (s memoryIndexSnapshot) Release() {}
+
+// memoryIterator iterates over the values of a postings map, offsets are
+// read on demand, while holding mu, if set
+type memoryIterator struct {
+	postings map[string][]int64
+	mu       *sync.RWMutex
+	vals     []string
+
+	val  []byte
+	offs []int64
+}
+
+// newMemoryIterator creates a new iterator, must be called while holding mu
+func newMemoryIterator(postings map[string][]int64, mu *sync.RWMutex, prefix []byte) *memoryIterator {
+	var vals []string
+	for val := range postings {
+		if strings.HasPrefix(val, string(prefix)) {
+			vals = append(vals, val)
+		}
+	}
+	sort.Strings(vals)
+	return &memoryIterator{postings: postings, mu: mu, vals: vals}
+}
+
+func (i *memoryIterator) Next() bool {
+	if i.mu != nil {
+		i.mu.RLock()
+		defer i.mu.RUnlock()
+	}
+
+	for len(i.vals) != 0 {
+		val := i.vals[0]
+		i.vals = i.vals[1:]
+		if list := i.postings[val]; len(list) != 0 {
+			i.val = []byte(val)
+			i.offs = append(i.offs[:0:0], list...)
+			return true
+		}
+	}
+	return false
+}
+
+func (i *memoryIterator) Value() []byte    { return i.val }
+func (i *memoryIterator) Offsets() []int64 { return i.offs }
+func (i *memoryIterator) Error() error     { return nil }
+func (i *memoryIterator) Release()         { i.vals = nil }
@@ -0,0 +1,170 @@
+package column
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MemoryIndex", func() {
+	var subject *MemoryIndex
+	var err error
+	var fill = func() {
+		Expect(subject.Add([]byte("a"), 1, 2)).NotTo(HaveOccurred())
+		Expect(subject.Add([]byte("b"), 3)).NotTo(HaveOccurred())
+	}
+
+	type pair struct {
+		val  string
+		offs []int64
+	}
+	collect := func(iter IndexIterator) []pair {
+		defer iter.Release()
+
+		var res []pair
+		for iter.Next() {
+			res = append(res, pair{string(iter.Value()), iter.Offsets()})
+		}
+		Expect(iter.Error()).NotTo(HaveOccurred())
+		return res
+	}
+
+	BeforeEach(func() {
+		subject, err = OpenMemoryIndex(filepath.Join(testDir, "index"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should add/get values", func() {
+		offs, err := subject.Get([]byte("a"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offs).To(BeNil())
+
+		fill()
+		Expect(subject.Get([]byte("a"))).To(Equal([]int64{1, 2}))
+		Expect(subject.Get([]byte("b"))).To(Equal([]int64{3}))
+
+		Expect(subject.Add(nil, 4)).NotTo(HaveOccurred())
+		Expect(subject.Get(nil)).To(BeEmpty())
+	})
+
+	It("should write/remove postings", func() {
+		Expect(subject.Write(Postings{"a": {2, 1}, "b": {3}, "c": {4}})).NotTo(HaveOccurred())
+		Expect(subject.Write(Postings{"a": {1, 7, 5}})).NotTo(HaveOccurred())
+		Expect(subject.Get([]byte("a"))).To(Equal([]int64{1, 2, 5, 7}))
+
+		Expect(subject.Remove(Postings{"a": {1, 6}, "c": {4}})).NotTo(HaveOccurred())
+		Expect(subject.Get([]byte("a"))).To(Equal([]int64{2, 5, 7}))
+		Expect(subject.Get([]byte("c"))).To(BeNil())
+		Expect(collect(subject.Iterate(nil))).To(Equal([]pair{
+			{"a", []int64{2, 5, 7}},
+			{"b", []int64{3}},
+		}))
+	})
+
+	It("should iterate over values", func() {
+		fill()
+		Expect(subject.Add([]byte("ab"), 4, 5)).NotTo(HaveOccurred())
+		Expect(subject.Add([]byte{'a', 0}, 6)).NotTo(HaveOccurred())
+
+		Expect(collect(subject.Iterate(nil))).To(Equal([]pair{
+			{"a", []int64{1, 2}},
+			{"a\x00", []int64{6}},
+			{"ab", []int64{4, 5}},
+			{"b", []int64{3}},
+		}))
+		Expect(collect(subject.Iterate([]byte("a\x00")))).To(Equal([]pair{
+			{"a\x00", []int64{6}},
+		}))
+		Expect(collect(subject.Iterate([]byte("c")))).To(BeEmpty())
+	})
+
+	It("should take snapshots", func() {
+		fill()
+
+		snap, err := subject.Snapshot()
+		Expect(err).NotTo(HaveOccurred())
+		defer snap.Release()
+
+		Expect(subject.Add([]byte("a"), 3)).NotTo(HaveOccurred())
+		Expect(subject.Remove(Postings{"b": {3}})).NotTo(HaveOccurred())
+
+		Expect(collect(snap.Iterate(nil))).To(Equal([]pair{
+			{"a", []int64{1, 2}},
+			{"b", []int64{3}},
+		}))
+	})
+
+	It("should persist on close", func() {
+		fill()
+		Expect(subject.Add([]byte("a"), 1<<40)).NotTo(HaveOccurred())
+		Expect(subject.Close()).NotTo(HaveOccurred())
+
+		subject, err = OpenMemoryIndex(filepath.Join(testDir, "index"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Get([]byte("a"))).To(Equal([]int64{1, 2, 1 << 40}))
+		Expect(subject.Get([]byte("b"))).To(Equal([]int64{3}))
+
+		files, err := ioutil.ReadDir(testDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(2))
+		Expect(files[0].Name()).To(Equal("index"))
+		Expect(files[1].Name()).To(Equal("index" + openMarkerSuffix))
+	})
+
+	It("should open read-only", func() {
+		fill()
+		Expect(subject.Close()).NotTo(HaveOccurred())
+
+		subject, err = OpenMemoryIndexReadOnly(filepath.Join(testDir, "index"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Get([]byte("a"))).To(Equal([]int64{1, 2}))
+		Expect(subject.Add([]byte("b"), 4)).To(Equal(errReadOnly))
+		Expect(subject.Remove(Postings{"a": {1}})).To(Equal(errReadOnly))
+
+		empty, err := OpenMemoryIndexReadOnly(filepath.Join(testDir, "missing"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(empty.Get([]byte("a"))).To(BeNil())
+		Expect(empty.Close()).NotTo(HaveOccurred())
+	})
+
+	It("should reject corrupt files", func() {
+		fname := filepath.Join(testDir, "corrupt")
+		Expect(ioutil.WriteFile(fname, []byte{3, 'a'}, 0644)).NotTo(HaveOccurred())
+
+		_, err := OpenMemoryIndex(fname)
+		Expect(err).To(Equal(errMemoryIndexFormat))
+
+		huge := []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x40}
+		Expect(ioutil.WriteFile(fname, huge, 0644)).NotTo(HaveOccurred())
+		_, err = OpenMemoryIndex(fname)
+		Expect(err).To(Equal(errMemoryIndexFormat))
+
+		Expect(ioutil.WriteFile(fname, append([]byte{1, 'a'}, huge...), 0644)).NotTo(HaveOccurred())
+		_, err = OpenMemoryIndex(fname)
+		Expect(err).To(Equal(errMemoryIndexFormat))
+	})
+
+	It("should detect lost postings", func() {
+		Expect(subject.Lost()).To(BeFalse())
+		fill()
+
+		// Re-open without closing, as after a crash
+		crashed, err := OpenMemoryIndex(filepath.Join(testDir, "index"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(crashed.Lost()).To(BeTrue())
+		Expect(crashed.Get([]byte("a"))).To(BeNil())
+		Expect(crashed.Close()).NotTo(HaveOccurred())
+
+		reopened, err := OpenMemoryIndex(filepath.Join(testDir, "index"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reopened.Lost()).To(BeTrue())
+		Expect(reopened.Close()).NotTo(HaveOccurred())
+	})
+
+})
@@ -13,6 +13,7 @@ type Variable struct {
 
 	pos   int64
 	bfile *os.File
+	bsums *checksums
 	lock  sync.Mutex
 }
 
@@ -23,10 +24,18 @@ func OpenVariable(fname string) (*Variable, error) {
 		return nil, err
 	}
 
-	col := &Variable{abstract: abstract{file, size / 8}}
+	abs, err := newAbstract(file, size/8)
+	if err != nil {
+		return nil, err
+	}
+
+	col := &Variable{abstract: abs}
 	if col.bfile, err = os.OpenFile(fname, os.O_CREATE|os.O_RDWR, 0664); err != nil {
 		col.Close()
 		return nil, err
+	} else if col.bsums, err = openChecksums(col.bfile); err != nil {
+		col.Close()
+		return nil, err
 	} else if col.pos, err = col.offset(col.rows - 1); err != nil && err != ErrNotFound {
 		col.Close()
 		return nil, err
@@ -39,6 +48,12 @@ func (c *Variable) Close() (err error) {
 	defer c.lock.Unlock()
 
 	err = c.abstract.Close()
+	if c.bsums != nil {
+		if e := c.bsums.Close(); e != nil {
+			err = e
+		}
+		c.bsums = nil
+	}
 	if c.bfile != nil {
 		if e := c.bfile.Close(); e != nil {
 			err = e
@@ -62,7 +77,7 @@ func (c *Variable) Get(offset int64) ([]byte, error) {
 	}
 
 	buf := make([]byte, max-min)
-	if _, err = c.bfile.ReadAt(buf, min); err != nil {
+	if err = c.bsums.ReadAt(buf, min); err != nil {
 		return nil, err
 	}
 	return buf, nil
@@ -75,6 +90,13 @@ func (c *Variable) Len() int64 {
 	return c.rows
 }
 
+func (c *Variable) Size() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.pos + c.rows*8
+}
+
 func (c *Variable) Add(b []byte) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -84,10 +106,10 @@ func (c *Variable) Add(b []byte) error {
 	bps := make([]byte, 8)
 	binary.BigEndian.PutUint64(bps, uint64(pos))
 
-	_, err := c.bfile.WriteAt(b, c.pos)
+	err := c.bsums.WriteAt(b, c.pos)
 	if err != nil {
 		return err
-	} else if _, err = c.file.WriteAt(bps, c.rows*8); err != nil {
+	} else if err = c.sums.WriteAt(bps, c.rows*8); err != nil {
 		return err
 	}
 
@@ -109,7 +131,7 @@ func (c *Variable) Truncate(rows int64) (err error) {
 		rows = 0
 	}
 
-	if err = c.file.Truncate(rows * 8); err != nil {
+	if err = c.sums.Truncate(rows * 8); err != nil {
 		return
 	}
 	c.rows = rows
@@ -117,9 +139,82 @@ func (c *Variable) Truncate(rows int64) (err error) {
 	return nil
 }
 
+func (c *Variable) Refresh() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.sums.Reload(); err != nil {
+		return err
+	} else if err := c.bsums.Reload(); err != nil {
+		return err
+	}
+
+	size, err := c.size()
+	if err != nil {
+		return err
+	}
+
+	rows := size / 8
+	pos, err := c.offset(rows - 1)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	c.rows, c.pos = rows, pos
+	return nil
+}
+
+// ValidLen returns the number of leading rows with ascending offsets
+// within the bounds of the data file
+func (c *Variable) ValidLen() (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	info, err := c.bfile.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, 8*1024)
+	last, rows := int64(0), int64(0)
+	for rows < c.rows {
+		n, err := c.file.ReadAt(buf, rows*8)
+		if n < 8 && err != nil {
+			return rows, checkNotFound(err)
+		}
+		for i := 0; i+8 <= n && rows < c.rows; i += 8 {
+			pos := int64(binary.BigEndian.Uint64(buf[i:]))
+			if pos < last || pos > info.Size() {
+				return rows, nil
+			}
+			last = pos
+			rows++
+		}
+	}
+	return rows, nil
+}
+
+// Flush stores the checksums of offsets and data
+func (c *Variable) Flush() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.bsums.Flush(); err != nil {
+		return err
+	}
+	return c.sums.Flush()
+}
+
+// Scrub verifies the checksums of offsets and data
+func (c *Variable) Scrub() error {
+	if err := c.sums.Scrub(); err != nil {
+		return err
+	}
+	return c.bsums.Scrub()
+}
+
 func (c *Variable) offset(i int64) (int64, error) {
 	buf := make([]byte, 8)
-	if _, err := c.file.ReadAt(buf, i*8); err != nil {
+	if err := c.sums.ReadAt(buf, i*8); err != nil {
 		return 0, checkNotFound(err)
 	}
 	return int64(binary.BigEndian.Uint64(buf)), nil
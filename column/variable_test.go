@@ -57,6 +57,7 @@ var _ = Describe("Variable", func() {
 		Expect(subject.rows).To(Equal(int64(7)))
 		Expect(subject.pos).To(Equal(int64(16)))
 		Expect(offsets()).To(Equal([]int64{1, 3, 6, 10, 13, 15, 16}))
+		Expect(subject.Size()).To(Equal(int64(72)))
 	})
 
 	It("should reopen columns", func() {
@@ -71,6 +72,48 @@ var _ = Describe("Variable", func() {
 		Expect(offsets()).To(Equal([]int64{1, 3, 6, 10, 13, 15, 16}))
 	})
 
+	It("should refresh rows appended by other writers", func() {
+		other, err := OpenVariable(filepath.Join(testDir, "col"))
+		Expect(err).NotTo(HaveOccurred())
+		defer other.Close()
+
+		fill()
+		Expect(other.Len()).To(Equal(int64(0)))
+		Expect(other.Refresh()).NotTo(HaveOccurred())
+		Expect(other.Len()).To(Equal(int64(7)))
+		Expect(other.Size()).To(Equal(int64(72)))
+		Expect(other.Get(3)).To(Equal([]byte("abcd")))
+	})
+
+	It("should determine valid rows", func() {
+		fill()
+		Expect(subject.ValidLen()).To(Equal(int64(7)))
+
+		Expect(subject.bfile.Truncate(9)).NotTo(HaveOccurred())
+		Expect(subject.ValidLen()).To(Equal(int64(3)))
+
+		bps := make([]byte, 8)
+		binary.BigEndian.PutUint64(bps, 0)
+		_, err := subject.file.WriteAt(bps, 8)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.ValidLen()).To(Equal(int64(1)))
+	})
+
+	It("should detect corruption", func() {
+		fill()
+		Expect(subject.Scrub()).To(Succeed())
+
+		_, err := subject.bfile.WriteAt([]byte{'x'}, 3)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = subject.Get(2)
+		Expect(err).To(BeAssignableToTypeOf(&CorruptionError{}))
+		Expect(subject.Scrub()).To(Equal(&CorruptionError{File: subject.bfile.Name(), Offset: 0}))
+
+		_, err = subject.file.WriteAt([]byte{'x'}, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Scrub()).To(Equal(&CorruptionError{File: subject.file.Name(), Offset: 0}))
+	})
+
 	It("should recover index/data length mismatches", func() {
 		fill()
 		Expect(subject.Close()).NotTo(HaveOccurred())
@@ -1,6 +1,10 @@
 package collie
 
 import (
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/bsm/collie/column"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -16,7 +20,74 @@ var _ = Describe("Column", func() {
 		Expect(err).To(HaveOccurred())
 		Expect(err.Error()).To(Equal(`collie: invalid column name 'in valid'`))
 
+		err = (&Column{Name: "x", Index: IndexType(200)}).Validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal(`collie: unknown index type for column 'x'`))
+
 		Expect((&Column{Name: "x"}).Validate()).NotTo(HaveOccurred())
+		Expect((&Column{Name: "x", Index: IndexTypeMemory}).Validate()).NotTo(HaveOccurred())
+	})
+
+})
+
+var _ = Describe("IndexType", func() {
+
+	It("should register index types", func() {
+		Expect(testIndexTypeCounted).To(BeNumerically(">", IndexTypeMemory))
+		Expect(testIndexTypeCounted.String()).To(Equal("counted"))
+		Expect(IndexType(200).String()).To(Equal("IndexType(200)"))
+
+		Expect(func() {
+			RegisterIndexType("memory", openMemoryIndex)
+		}).To(Panic())
+	})
+
+	It("should encode as JSON", func() {
+		data, err := json.Marshal([]IndexType{IndexTypeNone, IndexTypeHash, IndexTypeMemory, testIndexTypeCounted})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal(`["none","hash","memory","counted"]`))
+
+		var types []IndexType
+		Expect(json.Unmarshal(data, &types)).To(Succeed())
+		Expect(types).To(Equal([]IndexType{IndexTypeNone, IndexTypeHash, IndexTypeMemory, testIndexTypeCounted}))
+
+		err = json.Unmarshal([]byte(`[""]`), &types)
+		Expect(err).To(MatchError(`collie: invalid index type ''`))
+	})
+
+	It("should decode unknown index types as opaque", func() {
+		var types []IndexType
+		Expect(json.Unmarshal([]byte(`["btree","btree"]`), &types)).To(Succeed())
+		Expect(types[0]).To(Equal(types[1]))
+		Expect(types[0].String()).To(Equal("btree"))
+		Expect((&Column{Name: "x", Index: types[0]}).Validate()).To(Succeed())
+
+		_, err := openIndex(types[0], filepath.Join(testDir, "x.ci"), false)
+		Expect(err).To(MatchError(`collie: unregistered index type 'btree'`))
+
+		Expect(RegisterIndexType("btree", openMemoryIndex)).To(Equal(types[0]))
+		idx, err := openIndex(types[0], filepath.Join(testDir, "x.ci"), false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(idx.Close()).To(Succeed())
+	})
+
+	It("should open registered index types", func() {
+		opened := testIndexOpens
+		idx, err := openIndex(testIndexTypeCounted, filepath.Join(testDir, "x.ci"), false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(idx).To(BeAssignableToTypeOf(&column.MemoryIndex{}))
+		Expect(idx.Close()).To(Succeed())
+		Expect(testIndexOpens).To(Equal(opened + 1))
+
+		_, err = openIndex(IndexType(200), filepath.Join(testDir, "y.ci"), false)
+		Expect(err).To(HaveOccurred())
 	})
 
 })
+
+var testIndexOpens int
+
+var testIndexTypeCounted = RegisterIndexType("counted", func(path string, readOnly bool) (column.Index, error) {
+	testIndexOpens++
+	return openMemoryIndex(path, readOnly)
+})
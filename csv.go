@@ -0,0 +1,66 @@
+package collie
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+)
+
+// ImportCSV imports CSV records from r and returns the number of imported
+// rows. The first record must contain the field names. Rows are committed
+// in batches, rows of failed batches are not imported.
+func (c *Collection) ImportCSV(r io.Reader, opts *ImportOptions) (int64, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	im := newImporter(c, opts)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return im.count, err
+		}
+
+		fields := make(map[string][]string, len(header))
+		for i, field := range header {
+			if i < len(record) {
+				fields[field] = record[i : i+1]
+			}
+		}
+		if err := im.add(fields); err != nil {
+			return im.count, err
+		}
+	}
+	return im.count, im.flush()
+}
+
+// ExportCSV writes a header with the names of the exported columns,
+// followed by one record per row, to w
+func (c *Collection) ExportCSV(w io.Writer, opts *ExportOptions) error {
+	return c.ExportCSVContext(context.Background(), w, opts)
+}
+
+// ExportCSVContext behaves like ExportCSV, but aborts when ctx is done,
+// checking between batches of ExportOptions.BatchRows rows
+func (c *Collection) ExportCSVContext(ctx context.Context, w io.Writer, opts *ExportOptions) error {
+	opts, err := opts.norm(c)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(opts.Columns); err != nil {
+		return err
+	}
+	if err := c.export(ctx, opts, cw.Write); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,86 @@
+package collie
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CSV", func() {
+	var subject *Collection
+
+	BeforeEach(func() {
+		schema := CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "code", Size: 4},
+			{Name: "hash", Size: 2},
+			{Name: "tag", Index: IndexTypeHash, NoData: true},
+		})
+
+		var err error
+		subject, err = OpenCollection(testDir, schema)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should import records", func() {
+		n, err := subject.ImportCSV(strings.NewReader("name,code,hash,category,other\nalice,ab,0a0b,x,1\nbob,cd,ff00,y,2\ncarol,ef,0102,x,3\n"), &ImportOptions{
+			Fields:    map[string]string{"name": "name", "code": "code", "hash": "hash", "category": "tag"},
+			Encodings: map[string]Encoding{"hash": EncodingHex},
+			BatchSize: 2,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(int64(3)))
+		Expect(subject.Offset()).To(Equal(int64(3)))
+
+		Expect(subject.Value("name", 1)).To(Equal([]byte("bob")))
+		Expect(subject.Value("code", 1)).To(Equal([]byte{'c', 'd', 0, 0}))
+		Expect(subject.Value("hash", 1)).To(Equal([]byte{0xff, 0}))
+		Expect(subject.Offsets("tag", []byte("x"))).To(Equal([]int64{0, 2}))
+	})
+
+	It("should fail on invalid values", func() {
+		n, err := subject.ImportCSV(strings.NewReader("name,hash\nalice,0a0b\nbob,zz\n"), &ImportOptions{
+			Encodings: map[string]Encoding{"hash": EncodingHex},
+			BatchSize: 1,
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(n).To(Equal(int64(1)))
+		Expect(subject.Offset()).To(Equal(int64(1)))
+	})
+
+	It("should export records", func() {
+		_, err := subject.ImportCSV(strings.NewReader("name,code,hash\nalice,ab,0a0b\nbob,cd,ff00\ncarol,ef,0102\n"), &ImportOptions{
+			Encodings: map[string]Encoding{"hash": EncodingHex},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		buf := new(bytes.Buffer)
+		Expect(subject.ExportCSV(buf, &ExportOptions{
+			Encodings: map[string]Encoding{"hash": EncodingHex},
+		})).To(Succeed())
+		Expect(buf.String()).To(Equal("name,code,hash\nalice,ab,0a0b\nbob,cd,ff00\ncarol,ef,0102\n"))
+
+		buf.Reset()
+		Expect(subject.ExportCSV(buf, &ExportOptions{
+			Columns:   []string{"hash", "name"},
+			From:      1,
+			To:        3,
+			Encodings: map[string]Encoding{"hash": EncodingBase64},
+		})).To(Succeed())
+		Expect(buf.String()).To(Equal("hash,name\n/wA=,bob\nAQI=,carol\n"))
+
+		Expect(subject.ExportCSV(buf, &ExportOptions{Columns: []string{"tag"}})).To(Equal(ErrColumnNotFound))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Expect(subject.ExportCSVContext(ctx, buf, nil)).To(Equal(context.Canceled))
+	})
+
+})
@@ -0,0 +1,144 @@
+package collie
+
+import "sync"
+
+const (
+	subscriptionBuffer    = 64
+	subscriptionBatchRows = 1000
+)
+
+// A Change describes a range of committed rows
+type Change struct {
+	// Offset of the first row
+	Offset int64
+	// Number of rows
+	Count int64
+	// The committed rows, only populated if requested
+	Rows []*Row
+}
+
+// Subscription delivers changes to a collection
+type Subscription struct {
+	c        *Collection
+	offset   int64
+	withRows bool
+
+	ch   chan *Change
+	stop chan struct{}
+	once sync.Once
+	err  error
+}
+
+// Subscribe returns a subscription, delivering all rows committed at
+// or after offset. Rolled back rows are never delivered. If withRows is
+// set, changes include the committed rows.
+//
+// Commits never block on subscribers. If a subscriber falls behind,
+// subsequent changes are coalesced into larger ranges. Subscriptions
+// must be closed after use.
+func (c *Collection) Subscribe(offset int64, withRows bool) *Subscription {
+	s := &Subscription{
+		c:        c,
+		offset:   offset,
+		withRows: withRows,
+		ch:       make(chan *Change, subscriptionBuffer),
+		stop:     make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+// Changes returns the channel changes are delivered on. The channel
+// is closed when the subscription is closed or fails.
+func (s *Subscription) Changes() <-chan *Change { return s.ch }
+
+// Err returns the error that terminated the subscription, if any.
+// Must only be called after the changes channel is closed.
+func (s *Subscription) Err() error { return s.err }
+
+// Close closes the subscription
+func (s *Subscription) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	for range s.ch {
+	}
+	return nil
+}
+
+func (s *Subscription) loop() {
+	defer close(s.ch)
+
+	for !isClosed(s.stop) {
+		s.c.wmux.Lock()
+		l, current := s.c.listen(subscriptionBuffer)
+		s.c.wmux.Unlock()
+
+		err := s.deliver(l, current)
+		s.c.unlisten(l)
+		if err != nil {
+			s.err = err
+			return
+		}
+	}
+}
+
+// deliver catches up with current, then delivers batches received by
+// the listener. Returns without an error if the listener can't keep up.
+func (s *Subscription) deliver(l *listener, current int64) error {
+	batches := s.c.newBatchReader()
+	for s.offset < current {
+		change := &Change{Offset: s.offset, Count: current - s.offset}
+		if s.withRows {
+			limit := s.offset + subscriptionBatchRows
+			if limit > current {
+				limit = current
+			}
+
+			batch, err := batches.read(s.offset, limit)
+			if err != nil {
+				return err
+			}
+			change.Count, change.Rows = batch.Rows, batch.rows()
+		}
+
+		if !s.send(change) {
+			return nil
+		}
+	}
+
+	for {
+		select {
+		case <-s.stop:
+			return nil
+		case batch, ok := <-l.ch:
+			if !ok {
+				return nil
+			}
+
+			skip := s.offset - batch.Offset
+			if skip >= batch.Rows {
+				continue
+			} else if skip < 0 {
+				skip = 0
+			}
+
+			change := &Change{Offset: batch.Offset + skip, Count: batch.Rows - skip}
+			if s.withRows {
+				change.Rows = batch.rows()[skip:]
+			}
+			if !s.send(change) {
+				return nil
+			}
+		}
+	}
+}
+
+// send delivers a change, returns false if the subscription was closed
+func (s *Subscription) send(change *Change) bool {
+	select {
+	case s.ch <- change:
+		s.offset = change.Offset + change.Count
+		return true
+	case <-s.stop:
+		return false
+	}
+}
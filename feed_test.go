@@ -0,0 +1,133 @@
+package collie
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Subscription", func() {
+	var subject *Collection
+
+	add := func(recs ...Record) error {
+		txn := subject.Begin(len(recs))
+		for _, rec := range recs {
+			txn.Add(rec)
+		}
+		_, err := txn.Commit()
+		return err
+	}
+
+	next := func(sub *Subscription) *Change {
+		var change *Change
+		Eventually(sub.Changes()).Should(Receive(&change))
+		return change
+	}
+
+	BeforeEach(func() {
+		schema := CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "tag", Index: IndexTypeHash, NoData: true},
+		})
+
+		var err error
+		subject, err = OpenCollection(testDir, schema)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(add(
+			testRecord{"name": Value("alice"), "tag": Value("a")},
+			testRecord{"name": Value("bob"), "tag": Value("b")},
+		)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should deliver committed ranges", func() {
+		sub := subject.Subscribe(0, false)
+		defer sub.Close()
+
+		Expect(next(sub)).To(Equal(&Change{Offset: 0, Count: 2}))
+
+		Expect(add(testRecord{"name": Value("carol")})).To(Succeed())
+		Expect(next(sub)).To(Equal(&Change{Offset: 2, Count: 1}))
+
+		Expect(add(testRecord{"name": Value("dave")}, testRecordBadCol{})).To(HaveOccurred())
+		Expect(add(testRecord{"name": Value("eve")}, testRecord{"name": Value("fred")})).To(Succeed())
+		Expect(next(sub)).To(Equal(&Change{Offset: 3, Count: 2}))
+		Consistently(sub.Changes(), 20*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("should deliver rows", func() {
+		sub := subject.Subscribe(1, true)
+		defer sub.Close()
+
+		change := next(sub)
+		Expect(change.Offset).To(Equal(int64(1)))
+		Expect(change.Count).To(Equal(int64(1)))
+		Expect(change.Rows).To(HaveLen(1))
+		Expect(change.Rows[0].ValueAt("name")).To(Equal(Value("bob")))
+		Expect(change.Rows[0].IValuesAt("tag")).To(Equal([]Value{Value("b")}))
+
+		Expect(add(testRecord{"name": Value("carol"), "tag": Value("c")})).To(Succeed())
+		change = next(sub)
+		Expect(change.Offset).To(Equal(int64(2)))
+		Expect(change.Rows).To(HaveLen(1))
+		Expect(change.Rows[0].ValueAt("name")).To(Equal(Value("carol")))
+	})
+
+	It("should not retain committed buffers", func() {
+		sub := subject.Subscribe(2, true)
+		defer sub.Close()
+
+		Eventually(func() int {
+			subject.lmux.Lock()
+			defer subject.lmux.Unlock()
+			return len(subject.listeners)
+		}).Should(Equal(1))
+
+		buf := []byte("original")
+		Expect(add(testRecord{"name": buf})).To(Succeed())
+		copy(buf, "MUTATED!")
+
+		change := next(sub)
+		Expect(change.Rows).To(HaveLen(1))
+		Expect(change.Rows[0].ValueAt("name")).To(Equal(Value("original")))
+	})
+
+	It("should wait for future offsets", func() {
+		sub := subject.Subscribe(3, false)
+		defer sub.Close()
+
+		Expect(add(testRecord{"name": Value("carol")}, testRecord{"name": Value("dave")})).To(Succeed())
+		Expect(next(sub)).To(Equal(&Change{Offset: 3, Count: 1}))
+	})
+
+	It("should coalesce changes for slow subscribers", func() {
+		sub := subject.Subscribe(2, false)
+		defer sub.Close()
+
+		for i := 0; i < 2*subscriptionBuffer+10; i++ {
+			Expect(add(testRecord{"name": Value("x")})).To(Succeed())
+		}
+
+		total := int64(0)
+		for total < 2*subscriptionBuffer+10 {
+			change := next(sub)
+			Expect(change.Offset).To(Equal(2 + total))
+			total += change.Count
+		}
+		Expect(total).To(Equal(int64(2*subscriptionBuffer + 10)))
+	})
+
+	It("should close", func() {
+		sub := subject.Subscribe(0, false)
+		Expect(sub.Close()).To(Succeed())
+		Expect(sub.Close()).To(Succeed())
+		Eventually(sub.Changes()).Should(BeClosed())
+		Expect(sub.Err()).NotTo(HaveOccurred())
+	})
+
+})
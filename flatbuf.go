@@ -0,0 +1,172 @@
+package collie
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// Minimal flatbuffers encoder, as required by the Arrow IPC format.
+// Buffers are written front to back, children always follow the
+// objects referencing them.
+
+// fbTable is a table, fields are addressed by slot
+type fbTable struct {
+	fields []fbField
+}
+
+type fbField struct {
+	slot  int
+	size  int
+	value uint64
+	ref   interface{}
+}
+
+// fbString is a string
+type fbString string
+
+// fbTables is a vector of tables
+type fbTables []*fbTable
+
+// fbStructs is a vector of fixed-size structs, aligned to 8 bytes
+type fbStructs struct {
+	n    int
+	data []byte
+}
+
+func (t *fbTable) Uint8(slot int, v uint8) { t.scalar(slot, 1, uint64(v)) }
+func (t *fbTable) Int16(slot int, v int16) { t.scalar(slot, 2, uint64(uint16(v))) }
+func (t *fbTable) Int32(slot int, v int32) { t.scalar(slot, 4, uint64(uint32(v))) }
+func (t *fbTable) Int64(slot int, v int64) { t.scalar(slot, 8, uint64(v)) }
+func (t *fbTable) Ref(slot int, v interface{}) {
+	t.fields = append(t.fields, fbField{slot: slot, size: 4, ref: v})
+}
+
+func (t *fbTable) scalar(slot, size int, v uint64) {
+	t.fields = append(t.fields, fbField{slot: slot, size: size, value: v})
+}
+
+// fbEncode encodes root, the result is padded to 8 bytes
+func fbEncode(root *fbTable) []byte {
+	b := &fbBuilder{buf: make([]byte, 4)}
+	b.patch(0, b.write(root))
+	b.pad(8)
+	return b.buf
+}
+
+type fbBuilder struct {
+	buf []byte
+}
+
+func (b *fbBuilder) pad(align int) {
+	for len(b.buf)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+// patch stores the offset from pos to target at pos
+func (b *fbBuilder) patch(pos, target int) {
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(target-pos))
+}
+
+func (b *fbBuilder) uint32(v uint32) {
+	b.buf = append(b.buf, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(b.buf[len(b.buf)-4:], v)
+}
+
+// write writes an object and returns its position
+func (b *fbBuilder) write(obj interface{}) int {
+	switch v := obj.(type) {
+	case *fbTable:
+		return b.writeTable(v)
+	case fbString:
+		b.pad(4)
+		pos := len(b.buf)
+		b.uint32(uint32(len(v)))
+		b.buf = append(append(b.buf, v...), 0)
+		return pos
+	case fbTables:
+		b.pad(4)
+		pos := len(b.buf)
+		b.uint32(uint32(len(v)))
+		for range v {
+			b.uint32(0)
+		}
+		for i, t := range v {
+			b.patch(pos+4+4*i, b.writeTable(t))
+		}
+		return pos
+	case *fbStructs:
+		for (len(b.buf)+4)%8 != 0 {
+			b.buf = append(b.buf, 0)
+		}
+		pos := len(b.buf)
+		b.uint32(uint32(v.n))
+		b.buf = append(b.buf, v.data...)
+		return pos
+	}
+	panic("collie: unsupported flatbuffer object")
+}
+
+func (b *fbBuilder) writeTable(t *fbTable) int {
+	fields := make([]fbField, len(t.fields))
+	copy(fields, t.fields)
+	sort.Stable(fbFieldsBySize(fields))
+
+	// Layout inline fields, largest first
+	slots, size := 0, 4
+	offsets := make([]int, len(fields))
+	for i, f := range fields {
+		for size%f.size != 0 {
+			size++
+		}
+		offsets[i] = size
+		size += f.size
+		if f.slot >= slots {
+			slots = f.slot + 1
+		}
+	}
+
+	// Write vtable
+	b.pad(2)
+	vtable := len(b.buf)
+	vt := make([]byte, 4+2*slots)
+	binary.LittleEndian.PutUint16(vt[0:], uint16(len(vt)))
+	binary.LittleEndian.PutUint16(vt[2:], uint16(size))
+	for i, f := range fields {
+		binary.LittleEndian.PutUint16(vt[4+2*f.slot:], uint16(offsets[i]))
+	}
+	b.buf = append(b.buf, vt...)
+
+	// Write table
+	b.pad(8)
+	pos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(int32(pos-vtable)))
+	for i, f := range fields {
+		at := b.buf[pos+offsets[i]:]
+		switch f.size {
+		case 1:
+			at[0] = byte(f.value)
+		case 2:
+			binary.LittleEndian.PutUint16(at, uint16(f.value))
+		case 4:
+			binary.LittleEndian.PutUint32(at, uint32(f.value))
+		case 8:
+			binary.LittleEndian.PutUint64(at, f.value)
+		}
+	}
+
+	// Write children
+	for i, f := range fields {
+		if f.ref != nil {
+			b.patch(pos+offsets[i], b.write(f.ref))
+		}
+	}
+	return pos
+}
+
+type fbFieldsBySize []fbField
+
+func (p fbFieldsBySize) Len() int           { return len(p) }
+func (p fbFieldsBySize) Less(i, j int) bool { return p[i].size > p[j].size }
+func (p fbFieldsBySize) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
@@ -0,0 +1,91 @@
+package collie
+
+import (
+	"encoding/binary"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("flatbuffers", func() {
+
+	It("should encode tables", func() {
+		child := new(fbTable)
+		child.Int32(0, 7)
+
+		root := new(fbTable)
+		root.Uint8(0, 3)
+		root.Int64(2, -1)
+		root.Ref(3, fbString("name"))
+		root.Ref(4, fbTables{child, child})
+		root.Ref(5, &fbStructs{n: 2, data: appendInt64s(nil, 11, 12)})
+
+		buf := fbEncode(root)
+		Expect(len(buf) % 8).To(Equal(0))
+
+		r := fbReader(buf)
+		tbl := r.root()
+		Expect(r.uint8(tbl, 0)).To(Equal(uint8(3)))
+		Expect(r.field(tbl, 1)).To(Equal(0))
+		Expect(r.int64(tbl, 2)).To(Equal(int64(-1)))
+		Expect(r.str(tbl, 3)).To(Equal("name"))
+
+		tables := r.tables(tbl, 4)
+		Expect(tables).To(HaveLen(2))
+		Expect(r.int32(tables[1], 0)).To(Equal(int32(7)))
+
+		n, data := r.structs(tbl, 5)
+		Expect(n).To(Equal(2))
+		Expect(data[:16]).To(Equal(appendInt64s(nil, 11, 12)))
+	})
+
+})
+
+// fbReader decodes flatbuffers in tests
+type fbReader []byte
+
+func (r fbReader) uoffset(pos int) int { return pos + int(binary.LittleEndian.Uint32(r[pos:])) }
+func (r fbReader) root() int           { return r.uoffset(0) }
+
+// field returns the position of a table field, or 0 if not set
+func (r fbReader) field(tbl, slot int) int {
+	vtable := tbl - int(int32(binary.LittleEndian.Uint32(r[tbl:])))
+	if size := int(binary.LittleEndian.Uint16(r[vtable:])); 4+2*slot >= size {
+		return 0
+	}
+	if off := int(binary.LittleEndian.Uint16(r[vtable+4+2*slot:])); off != 0 {
+		return tbl + off
+	}
+	return 0
+}
+
+func (r fbReader) uint8(tbl, slot int) uint8 { return r[r.field(tbl, slot)] }
+func (r fbReader) int16(tbl, slot int) int16 {
+	return int16(binary.LittleEndian.Uint16(r[r.field(tbl, slot):]))
+}
+func (r fbReader) int32(tbl, slot int) int32 {
+	return int32(binary.LittleEndian.Uint32(r[r.field(tbl, slot):]))
+}
+func (r fbReader) int64(tbl, slot int) int64 {
+	return int64(binary.LittleEndian.Uint64(r[r.field(tbl, slot):]))
+}
+func (r fbReader) table(tbl, slot int) int { return r.uoffset(r.field(tbl, slot)) }
+
+func (r fbReader) str(tbl, slot int) string {
+	pos := r.table(tbl, slot)
+	return string(r[pos+4 : pos+4+int(binary.LittleEndian.Uint32(r[pos:]))])
+}
+
+func (r fbReader) tables(tbl, slot int) []int {
+	pos := r.table(tbl, slot)
+	res := make([]int, binary.LittleEndian.Uint32(r[pos:]))
+	for i := range res {
+		res[i] = r.uoffset(pos + 4 + 4*i)
+	}
+	return res
+}
+
+func (r fbReader) structs(tbl, slot int) (int, []byte) {
+	pos := r.table(tbl, slot)
+	return int(binary.LittleEndian.Uint32(r[pos:])), r[pos+4:]
+}
@@ -0,0 +1,41 @@
+package collie
+
+import "time"
+
+// SegmentInfo describes a segment
+type SegmentInfo struct {
+	// Offset of the first row
+	Base int64
+	// Creation time
+	Created time.Time
+	// Data columns, in schema order
+	Columns []ColumnInfo
+}
+
+// ColumnInfo describes the data of a column within a segment
+type ColumnInfo struct {
+	Name string
+	// The number of stored values
+	Len int64
+	// The number of bytes stored
+	Size int64
+}
+
+// Segments describes all segments of the collection, in ascending order.
+// Collections which are not segmented consist of a single segment.
+func (c *Collection) Segments() []SegmentInfo {
+	c.smux.RLock()
+	defer c.smux.RUnlock()
+
+	infos := make([]SegmentInfo, 0, len(c.segs))
+	for _, seg := range c.segs {
+		info := SegmentInfo{Base: seg.base, Created: seg.created}
+		for _, col := range c.schema.Columns() {
+			if cc, ok := seg.columns[col.Name]; ok {
+				info.Columns = append(info.Columns, ColumnInfo{Name: col.Name, Len: cc.Len(), Size: cc.Size()})
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
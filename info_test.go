@@ -0,0 +1,52 @@
+package collie
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SegmentInfo", func() {
+	var subject *Collection
+
+	BeforeEach(func() {
+		schema := CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "code", Size: 2},
+			{Name: "tag", Index: IndexTypeHash, NoData: true},
+		})
+
+		var err error
+		subject, err = OpenCollectionWithOptions(testDir, schema, &Options{SegmentRows: 2})
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, name := range []string{"alice", "bob", "carol"} {
+			txn := subject.Begin(1)
+			txn.Add(testRecord{"name": Value(name), "code": Value(name[:2])})
+			_, err = txn.Commit()
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should describe segments", func() {
+		infos := subject.Segments()
+		Expect(infos).To(HaveLen(2))
+		Expect(infos[0].Base).To(Equal(int64(0)))
+		Expect(infos[0].Created).To(BeTemporally("~", time.Now(), time.Second))
+		Expect(infos[0].Columns).To(Equal([]ColumnInfo{
+			{Name: "name", Len: 2, Size: 24},
+			{Name: "code", Len: 2, Size: 4},
+		}))
+		Expect(infos[1].Base).To(Equal(int64(2)))
+		Expect(infos[1].Columns).To(Equal([]ColumnInfo{
+			{Name: "name", Len: 1, Size: 13},
+			{Name: "code", Len: 1, Size: 2},
+		}))
+	})
+
+})
@@ -0,0 +1,117 @@
+package collie
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// ImportJSONL imports JSON Lines from r and returns the number of imported
+// rows. Each line must hold an object, numbers and booleans are imported
+// as text and arrays add multiple values to index-only columns. Rows are
+// committed in batches, rows of failed batches are not imported.
+func (c *Collection) ImportJSONL(r io.Reader, opts *ImportOptions) (int64, error) {
+	im := newImporter(c, opts)
+	dec := json.NewDecoder(bufio.NewReader(r))
+	dec.UseNumber()
+
+	for {
+		var obj map[string]interface{}
+		if err := dec.Decode(&obj); err == io.EOF {
+			break
+		} else if err != nil {
+			return im.count, err
+		}
+
+		fields := make(map[string][]string, len(obj))
+		for field, v := range obj {
+			vals, err := jsonValues(v)
+			if err != nil {
+				return im.count, fmt.Errorf("collie: invalid value for field '%s': %s", field, err.Error())
+			}
+			fields[field] = vals
+		}
+		if err := im.add(fields); err != nil {
+			return im.count, err
+		}
+	}
+	return im.count, im.flush()
+}
+
+// ExportJSONL writes one object per row, mapping the names
+// of the exported columns to their values, to w. Text values must be
+// valid UTF-8, use EncodingHex or EncodingBase64 for binary columns.
+func (c *Collection) ExportJSONL(w io.Writer, opts *ExportOptions) error {
+	return c.ExportJSONLContext(context.Background(), w, opts)
+}
+
+// ExportJSONLContext behaves like ExportJSONL, but aborts when ctx is
+// done, checking between batches of ExportOptions.BatchRows rows
+func (c *Collection) ExportJSONLContext(ctx context.Context, w io.Writer, opts *ExportOptions) error {
+	opts, err := opts.norm(c)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	buf := new(bytes.Buffer)
+	err = c.export(ctx, opts, func(vals []string) error {
+		buf.Reset()
+		buf.WriteByte('{')
+		for i, name := range opts.Columns {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if !utf8.ValidString(vals[i]) {
+				return fmt.Errorf("collie: value of column '%s' is not valid UTF-8", name)
+			}
+			buf.WriteString(strconv.Quote(name))
+			buf.WriteByte(':')
+			data, err := json.Marshal(vals[i])
+			if err != nil {
+				return err
+			}
+			buf.Write(data)
+		}
+		buf.WriteString("}\n")
+		_, err := bw.Write(buf.Bytes())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// jsonValues converts a decoded JSON value into text values
+func jsonValues(v interface{}) ([]string, error) {
+	switch x := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{x}, nil
+	case json.Number:
+		return []string{x.String()}, nil
+	case bool:
+		return []string{strconv.FormatBool(x)}, nil
+	case []interface{}:
+		var vals []string
+		for _, el := range x {
+			if _, ok := el.([]interface{}); ok {
+				return nil, fmt.Errorf("nested arrays are not supported")
+			}
+			sub, err := jsonValues(el)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, sub...)
+		}
+		return vals, nil
+	}
+	return nil, fmt.Errorf("unsupported type %T", v)
+}
@@ -0,0 +1,102 @@
+package collie
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSONL", func() {
+	var subject *Collection
+
+	BeforeEach(func() {
+		schema := CreateSchema([]Column{
+			{Name: "name", Index: IndexTypeHash},
+			{Name: "age", Size: 3},
+			{Name: "tags", Index: IndexTypeHash, NoData: true},
+		})
+
+		var err error
+		subject, err = OpenCollection(testDir, schema)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should import objects", func() {
+		n, err := subject.ImportJSONL(strings.NewReader(`{"name":"alice","age":27,"tags":["a","b"],"active":true}
+{"name":"bob","age":null,"tags":"b"}
+`), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(int64(2)))
+
+		Expect(subject.Value("age", 0)).To(Equal([]byte{'2', '7', 0}))
+		Expect(subject.Value("name", 1)).To(Equal([]byte("bob")))
+		Expect(subject.Offsets("name", []byte("bob"))).To(Equal([]int64{1}))
+		Expect(subject.Offsets("tags", []byte("a"))).To(Equal([]int64{0}))
+		Expect(subject.Offsets("tags", []byte("b"))).To(Equal([]int64{0, 1}))
+	})
+
+	It("should reject invalid values", func() {
+		_, err := subject.ImportJSONL(strings.NewReader(`{"name":["alice","bob"]}`), nil)
+		Expect(err).To(MatchError("collie: multiple values for column 'name'"))
+
+		_, err = subject.ImportJSONL(strings.NewReader(`{"name":{"first":"alice"}}`), nil)
+		Expect(err).To(MatchError("collie: invalid value for field 'name': unsupported type map[string]interface {}"))
+
+		_, err = subject.ImportJSONL(strings.NewReader(`[1]`), nil)
+		Expect(err).To(HaveOccurred())
+		Expect(subject.Offset()).To(Equal(int64(0)))
+	})
+
+	It("should export objects", func() {
+		_, err := subject.ImportJSONL(strings.NewReader(`{"name":"alice","age":27}
+{"name":"bob","age":"\u0000\u0001"}
+`), nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		buf := new(bytes.Buffer)
+		Expect(subject.ExportJSONL(buf, nil)).To(Succeed())
+		Expect(buf.String()).To(Equal(`{"name":"alice","age":"27"}
+{"name":"bob","age":"\u0000\u0001"}
+`))
+
+		buf.Reset()
+		Expect(subject.ExportJSONL(buf, &ExportOptions{
+			Columns:   []string{"age"},
+			From:      1,
+			Encodings: map[string]Encoding{"age": EncodingHex},
+		})).To(Succeed())
+		Expect(buf.String()).To(Equal(`{"age":"000100"}
+`))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		buf.Reset()
+		Expect(subject.ExportJSONLContext(ctx, buf, nil)).To(Equal(context.Canceled))
+		Expect(buf.Len()).To(BeZero())
+	})
+
+	It("should reject invalid UTF-8 text", func() {
+		txn := subject.Begin(1)
+		txn.Add(testRecord{"name": Value{0xff, 0xfe}, "age": Value("1")})
+		_, err := txn.Commit()
+		Expect(err).NotTo(HaveOccurred())
+
+		err = subject.ExportJSONL(new(bytes.Buffer), nil)
+		Expect(err).To(MatchError("collie: value of column 'name' is not valid UTF-8"))
+
+		buf := new(bytes.Buffer)
+		Expect(subject.ExportJSONL(buf, &ExportOptions{
+			Encodings: map[string]Encoding{"name": EncodingBase64},
+		})).To(Succeed())
+		Expect(buf.String()).To(Equal(`{"name":"//4=","age":"1"}
+`))
+	})
+
+})
@@ -0,0 +1,54 @@
+package collie
+
+// A listener receives batches of committed rows
+type listener struct {
+	ch chan *commitBatch
+}
+
+// listen registers a listener, receiving all batches committed after the
+// returned offset. Listeners which are unable to keep up are removed and
+// their channels closed. Must only be called while holding the write lock.
+func (c *Collection) listen(buffer int) (*listener, int64) {
+	l := &listener{ch: make(chan *commitBatch, buffer)}
+
+	c.lmux.Lock()
+	defer c.lmux.Unlock()
+
+	if c.listeners == nil {
+		c.listeners = make(map[*listener]struct{})
+	}
+	c.listeners[l] = struct{}{}
+	return l, c.Offset()
+}
+
+// unlisten removes a listener
+func (c *Collection) unlisten(l *listener) {
+	c.lmux.Lock()
+	defer c.lmux.Unlock()
+
+	if _, ok := c.listeners[l]; ok {
+		delete(c.listeners, l)
+		close(l.ch)
+	}
+}
+
+// broadcast passes a batch to all listeners, without blocking. Listeners
+// receive the batch asynchronously, its values are therefore copied.
+func (c *Collection) broadcast(batch *commitBatch) {
+	c.lmux.Lock()
+	defer c.lmux.Unlock()
+
+	if len(c.listeners) == 0 {
+		return
+	}
+	batch.detach()
+
+	for l := range c.listeners {
+		select {
+		case l.ch <- batch:
+		default:
+			delete(c.listeners, l)
+			close(l.ch)
+		}
+	}
+}
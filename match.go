@@ -0,0 +1,101 @@
+package collie
+
+import (
+	"context"
+	"time"
+)
+
+// Lookup selects rows indexed by a value, or by values starting with
+// a prefix
+type Lookup struct {
+	Index  string
+	Value  []byte
+	Prefix bool
+}
+
+// Match returns the ascending offsets of all rows matching every lookup,
+// but no more than limit, if limit > 0. Segments are evaluated in order,
+// until limit offsets are found, reading the postings of one segment at
+// a time.
+func (c *Collection) Match(lookups []Lookup, limit int) ([]int64, error) {
+	return c.MatchContext(context.Background(), lookups, limit)
+}
+
+// MatchContext behaves like Match, but aborts when ctx is done, checking
+// between segments and index values
+func (c *Collection) MatchContext(ctx context.Context, lookups []Lookup, limit int) ([]int64, error) {
+	defer c.counters.lookup(time.Now())
+
+	c.smux.RLock()
+	defer c.smux.RUnlock()
+
+	if len(c.segs) == 0 {
+		return nil, ErrClosed
+	}
+	for _, l := range lookups {
+		if _, ok := c.segs[0].indices[l.Index]; !ok {
+			return nil, ErrColumnNotFound
+		}
+	}
+
+	var res []int64
+	for _, seg := range c.segs {
+		offs, err := seg.match(ctx, lookups)
+		if err != nil {
+			return nil, err
+		}
+		if res = append(res, offs...); limit > 0 && len(res) >= limit {
+			return res[:limit], nil
+		}
+	}
+	return res, nil
+}
+
+// match returns the ascending offsets of all rows within the segment,
+// matching every lookup
+func (s *segment) match(ctx context.Context, lookups []Lookup) ([]int64, error) {
+	var res []int64
+	for i, l := range lookups {
+		offs, err := s.lookup(ctx, l)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			res = offs
+		} else {
+			res = intersectOffsets(res, offs)
+		}
+		if len(res) == 0 {
+			return nil, nil
+		}
+	}
+	return res, nil
+}
+
+// lookup returns the ascending offsets of all rows within the segment,
+// matching l
+func (s *segment) lookup(ctx context.Context, l Lookup) ([]int64, error) {
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+
+	idx := s.indices[l.Index]
+	if !l.Prefix {
+		return idx.Get(l.Value)
+	}
+
+	iter := idx.Iterate(l.Value)
+	defer iter.Release()
+
+	var lists [][]int64
+	for iter.Next() {
+		if err := canceled(ctx); err != nil {
+			return nil, err
+		}
+		lists = append(lists, iter.Offsets())
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return mergeOffsets(lists), nil
+}
@@ -0,0 +1,63 @@
+package collie
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Match", func() {
+	var subject *Collection
+
+	BeforeEach(func() {
+		schema := CreateSchema([]Column{
+			{Name: "name", Index: IndexTypeHash},
+			{Name: "tag", Index: IndexTypeHash, NoData: true},
+		})
+
+		var err error
+		subject, err = OpenCollectionWithOptions(testDir, schema, &Options{SegmentRows: 2})
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, name := range []string{"alice", "bob", "anna", "amber", "arnold"} {
+			txn := subject.Begin(1)
+			txn.Add(testRecord{"name": Value(name), "tag": Value(name[:1])})
+			_, err = txn.Commit()
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should match lookups", func() {
+		Expect(subject.Match([]Lookup{{Index: "tag", Value: []byte("a")}}, 0)).To(Equal([]int64{0, 2, 3, 4}))
+		Expect(subject.Match([]Lookup{
+			{Index: "tag", Value: []byte("a")},
+			{Index: "name", Value: []byte("a"), Prefix: true},
+		}, 0)).To(Equal([]int64{0, 2, 3, 4}))
+		Expect(subject.Match([]Lookup{
+			{Index: "tag", Value: []byte("a")},
+			{Index: "name", Value: []byte("b"), Prefix: true},
+		}, 0)).To(BeEmpty())
+		Expect(subject.Match([]Lookup{{Index: "name", Value: []byte("ar"), Prefix: true}}, 0)).To(Equal([]int64{4}))
+	})
+
+	It("should limit matches", func() {
+		Expect(subject.Match([]Lookup{{Index: "tag", Value: []byte("a")}}, 2)).To(Equal([]int64{0, 2}))
+		Expect(subject.Match([]Lookup{{Index: "name", Value: []byte("a"), Prefix: true}}, 3)).To(Equal([]int64{0, 2, 3}))
+	})
+
+	It("should fail on invalid lookups", func() {
+		_, err := subject.Match([]Lookup{{Index: "bogus"}}, 0)
+		Expect(err).To(Equal(ErrColumnNotFound))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = subject.MatchContext(ctx, []Lookup{{Index: "tag", Value: []byte("a")}}, 0)
+		Expect(err).To(Equal(context.Canceled))
+	})
+
+})
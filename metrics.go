@@ -0,0 +1,111 @@
+package collie
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PublishExpvar publishes the statistics of the collection as an expvar
+// variable with the given name. Index keys are not counted. Like
+// expvar.Publish, it panics if name is already registered.
+func (c *Collection) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		stats, err := c.Stats(false)
+		if err != nil {
+			return nil
+		}
+		return stats
+	}))
+}
+
+// MetricsHandler returns a handler serving the statistics of colls in the
+// Prometheus text exposition format, labelled by collection name. If keys
+// is true, index keys are counted on every request.
+func MetricsHandler(colls map[string]*Collection, keys bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := WritePrometheus(w, colls, keys); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// promMetric describes a metric, derived from collection statistics
+type promMetric struct {
+	name, kind, help string
+	value            func(*Stats) float64
+}
+
+var promMetrics = []promMetric{
+	{"collie_offset", "gauge", "Current offset.", func(s *Stats) float64 { return float64(s.Offset) }},
+	{"collie_first_offset", "gauge", "Offset of the first stored row.", func(s *Stats) float64 { return float64(s.FirstOffset) }},
+	{"collie_rows", "gauge", "Number of stored rows.", func(s *Stats) float64 { return float64(s.Rows) }},
+	{"collie_segments", "gauge", "Number of segments.", func(s *Stats) float64 { return float64(s.Segments) }},
+	{"collie_commits_total", "counter", "Number of commits.", func(s *Stats) float64 { return float64(s.Commits) }},
+	{"collie_committed_rows_total", "counter", "Number of committed rows.", func(s *Stats) float64 { return float64(s.CommittedRows) }},
+	{"collie_rollbacks_total", "counter", "Number of rolled back commits.", func(s *Stats) float64 { return float64(s.Rollbacks) }},
+	{"collie_commit_seconds_total", "counter", "Total time spent writing commits.", func(s *Stats) float64 { return s.CommitTime.Seconds() }},
+	{"collie_reads_total", "counter", "Number of values read.", func(s *Stats) float64 { return float64(s.Reads) }},
+	{"collie_read_seconds_total", "counter", "Total time spent reading values.", func(s *Stats) float64 { return s.ReadTime.Seconds() }},
+	{"collie_lookups_total", "counter", "Number of index lookups.", func(s *Stats) float64 { return float64(s.Lookups) }},
+	{"collie_lookup_seconds_total", "counter", "Total time spent on index lookups.", func(s *Stats) float64 { return s.LookupTime.Seconds() }},
+}
+
+// WritePrometheus writes the statistics of colls to w in the Prometheus
+// text exposition format. If keys is true, index keys are counted.
+func WritePrometheus(w io.Writer, colls map[string]*Collection, keys bool) error {
+	names := make([]string, 0, len(colls))
+	for name := range colls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	stats := make([]*Stats, len(names))
+	for i, name := range names {
+		var err error
+		if stats[i], err = colls[name].Stats(keys); err != nil {
+			return err
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	header := func(name, kind, help string) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	for _, m := range promMetrics {
+		header(m.name, m.kind, m.help)
+		for i, s := range stats {
+			fmt.Fprintf(bw, "%s{collection=\"%s\"} %s\n", m.name, promEscape(names[i]), promValue(m.value(s)))
+		}
+	}
+
+	header("collie_column_bytes", "gauge", "Number of bytes stored per column.")
+	for i, s := range stats {
+		for _, col := range s.Columns {
+			fmt.Fprintf(bw, "collie_column_bytes{collection=\"%s\",column=\"%s\"} %d\n", promEscape(names[i]), promEscape(col.Name), col.Size)
+		}
+	}
+
+	if keys {
+		header("collie_index_keys", "gauge", "Number of distinct values per index.")
+		for i, s := range stats {
+			for _, idx := range s.Indices {
+				fmt.Fprintf(bw, "collie_index_keys{collection=\"%s\",index=\"%s\"} %d\n", promEscape(names[i]), promEscape(idx.Name), idx.Keys)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promEscape(s string) string { return promEscaper.Replace(s) }
+
+func promValue(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
@@ -0,0 +1,72 @@
+package collie
+
+import "container/heap"
+
+// intersectOffsets returns the offsets contained in both ascending lists
+func intersectOffsets(a, b []int64) []int64 {
+	var res []int64
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			res = append(res, a[i])
+			i++
+			j++
+		}
+	}
+	return res
+}
+
+// mergeOffsets merges multiple ascending offset lists into a
+// single ascending list, without duplicates
+func mergeOffsets(lists [][]int64) []int64 {
+	switch len(lists) {
+	case 0:
+		return nil
+	case 1:
+		return lists[0]
+	}
+
+	size := 0
+	heads := make(offsetHeads, 0, len(lists))
+	for _, offs := range lists {
+		if len(offs) != 0 {
+			heads = append(heads, offs)
+			size += len(offs)
+		}
+	}
+	heap.Init(&heads)
+
+	res := make([]int64, 0, size)
+	for len(heads) != 0 {
+		off := heads[0][0]
+		if n := len(res); n == 0 || res[n-1] != off {
+			res = append(res, off)
+		}
+
+		if heads[0] = heads[0][1:]; len(heads[0]) == 0 {
+			heap.Pop(&heads)
+		} else {
+			heap.Fix(&heads, 0)
+		}
+	}
+	return res
+}
+
+// offsetHeads is a min-heap of offset lists, ordered by their first element
+type offsetHeads [][]int64
+
+func (h offsetHeads) Len() int            { return len(h) }
+func (h offsetHeads) Less(i, j int) bool  { return h[i][0] < h[j][0] }
+func (h offsetHeads) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *offsetHeads) Push(x interface{}) { *h = append(*h, x.([]int64)) }
+func (h *offsetHeads) Pop() interface{} {
+	old := *h
+	n := len(old) - 1
+	x := old[n]
+	*h = old[:n]
+	return x
+}
@@ -0,0 +1,30 @@
+package collie
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("mergeOffsets", func() {
+
+	It("should merge offset lists", func() {
+		Expect(mergeOffsets(nil)).To(BeEmpty())
+		Expect(mergeOffsets([][]int64{{1, 4}})).To(Equal([]int64{1, 4}))
+		Expect(mergeOffsets([][]int64{
+			{2, 5, 9},
+			{},
+			{1, 3, 5, 11},
+			{4},
+		})).To(Equal([]int64{1, 2, 3, 4, 5, 9, 11}))
+	})
+
+})
+
+var _ = Describe("intersectOffsets", func() {
+
+	It("should intersect offsets", func() {
+		Expect(intersectOffsets([]int64{1, 3, 5, 7}, []int64{2, 3, 4, 7, 8})).To(Equal([]int64{3, 7}))
+		Expect(intersectOffsets([]int64{1, 3}, nil)).To(BeEmpty())
+	})
+
+})
@@ -0,0 +1,113 @@
+package collie
+
+import (
+	"errors"
+	"runtime"
+	"time"
+)
+
+// Options can be used to tune collections
+type Options struct {
+	// The maximum number of columns and indices written
+	// concurrently on commit. Default: runtime.NumCPU()
+	CommitConcurrency int
+
+	// Collections can be split into segments, each with their own set of
+	// column files and indices. Before each commit, the collection rolls
+	// over to a new segment once the current one holds SegmentRows rows,
+	// SegmentBytes bytes of column data or is older than SegmentAge.
+	// Collections are not segmented by default.
+	SegmentRows  int64
+	SegmentBytes int64
+	SegmentAge   time.Duration
+
+	// Segmented collections can discard old data. Whole segments are
+	// dropped, oldest first, while the collection holds more than
+	// RetainRows rows or RetainBytes bytes of column data, or once the
+	// newest row of a segment is older than RetainAge. The current
+	// segment is never dropped. Offsets of remaining rows are stable.
+	RetainRows  int64
+	RetainBytes int64
+	RetainAge   time.Duration
+
+	// The name of the column holding row timestamps, required by
+	// RetainAge. Timestamps must be stored as 8-byte big-endian
+	// Unix seconds.
+	RetainColumn string
+
+	// Read-only collections reject commits and pick up rows committed
+	// by other processes on Refresh or WaitFor. Indices are opened
+	// read-only and only reflect their state at the time of opening.
+	ReadOnly bool
+
+	// Do not open indices, index lookups fail with ErrColumnNotFound.
+	// Indices are locked by the writing process, tailing a collection
+	// while it is written to therefore requires NoIndices.
+	// Only supported by read-only collections.
+	NoIndices bool
+
+	// Fail with ErrSchemaMismatch if the collection directory holds a
+	// different schema, applies to writable and read-only collections.
+	// By default, writable collections replace the stored schema and
+	// read-only collections ignore it.
+	CheckSchema bool
+
+	// Called with errors of followers served by ServeReplication and
+	// with connection failures retried by Follow. Optional.
+	OnReplicationError func(error)
+}
+
+func (o *Options) norm() *Options {
+	var opts Options
+	if o != nil {
+		opts = *o
+	}
+	if opts.CommitConcurrency < 1 {
+		opts.CommitConcurrency = runtime.NumCPU()
+	}
+	return &opts
+}
+
+func (o *Options) validate(schema *Schema) error {
+	if o.NoIndices && !o.ReadOnly {
+		return errors.New("collie: indices can only be skipped by read-only collections")
+	} else if !o.retained() {
+		return nil
+	} else if !o.segmented() {
+		return errors.New("collie: retention requires a segmented collection")
+	} else if o.RetainAge <= 0 {
+		return nil
+	}
+
+	for _, col := range schema.Columns() {
+		if col.Name == o.RetainColumn {
+			if col.NoData || col.Size != 8 {
+				return errors.New("collie: retention column '" + col.Name + "' must store 8-byte values")
+			}
+			return nil
+		}
+	}
+	return errors.New("collie: invalid retention column '" + o.RetainColumn + "'")
+}
+
+func (o *Options) retained() bool {
+	return o.RetainRows > 0 || o.RetainBytes > 0 || o.RetainAge > 0
+}
+
+func (o *Options) segmented() bool {
+	return o.SegmentRows > 0 || o.SegmentBytes > 0 || o.SegmentAge > 0
+}
+
+// rolloverDue returns true if seg, currently holding n rows, is full
+func (o *Options) rolloverDue(seg *segment, n int64) bool {
+	if n < 1 {
+		return false
+	} else if o.SegmentRows > 0 && n >= o.SegmentRows {
+		return true
+	} else if o.SegmentBytes > 0 && seg.Size() >= o.SegmentBytes {
+		return true
+	} else if o.SegmentAge > 0 && time.Since(seg.created) >= o.SegmentAge {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package collie
+
+import (
+	"runtime"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Options", func() {
+
+	It("should apply defaults", func() {
+		var opts *Options
+		Expect(opts.norm()).To(Equal(&Options{
+			CommitConcurrency: runtime.NumCPU(),
+		}))
+		Expect((&Options{CommitConcurrency: 2}).norm()).To(Equal(&Options{
+			CommitConcurrency: 2,
+		}))
+	})
+
+	It("should check for rollovers", func() {
+		seg := &segment{created: time.Now().Add(-time.Minute)}
+		Expect((&Options{}).rolloverDue(seg, 10)).To(BeFalse())
+		Expect((&Options{SegmentRows: 10}).rolloverDue(seg, 9)).To(BeFalse())
+		Expect((&Options{SegmentRows: 10}).rolloverDue(seg, 10)).To(BeTrue())
+		Expect((&Options{SegmentAge: time.Hour}).rolloverDue(seg, 1)).To(BeFalse())
+		Expect((&Options{SegmentAge: time.Second}).rolloverDue(seg, 1)).To(BeTrue())
+		Expect((&Options{SegmentAge: time.Second}).rolloverDue(seg, 0)).To(BeFalse())
+	})
+
+})
@@ -0,0 +1,180 @@
+package collie
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+)
+
+// Parquet format constants
+const (
+	parquetTypeByteArray         = 6
+	parquetTypeFixedLenByteArray = 7
+	parquetRequired              = 0
+	parquetEncodingPlain         = 0
+	parquetEncodingRLE           = 3
+	parquetPageData              = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+// parquetChunk describes a column chunk
+type parquetChunk struct {
+	offset int64
+	size   int64
+}
+
+// parquetRowGroup describes a row group
+type parquetRowGroup struct {
+	rows   int64
+	chunks []parquetChunk
+}
+
+// ExportParquet writes the selected columns and rows to w as an
+// uncompressed Parquet file. Fixed-length columns are exported as
+// FIXED_LEN_BYTE_ARRAY, variable ones as BYTE_ARRAY. Rows are streamed
+// in row groups of ExportOptions.BatchRows rows each, with one plain
+// encoded data page per column chunk. Encodings are ignored.
+func (c *Collection) ExportParquet(w io.Writer, opts *ExportOptions) error {
+	return c.ExportParquetContext(context.Background(), w, opts)
+}
+
+// ExportParquetContext behaves like ExportParquet, but aborts when ctx is
+// done, checking between row groups
+func (c *Collection) ExportParquetContext(ctx context.Context, w io.Writer, opts *ExportOptions) error {
+	opts, err := opts.norm(c)
+	if err != nil {
+		return err
+	}
+
+	cols := c.exportColumns(opts)
+	pw := &countingWriter{w: bufio.NewWriter(w)}
+	if _, err := pw.Write(parquetMagic); err != nil {
+		return err
+	}
+
+	var groups []parquetRowGroup
+	err = c.exportBatches(ctx, opts, func(n int64, vals [][]Value) error {
+		group := parquetRowGroup{rows: n, chunks: make([]parquetChunk, len(cols))}
+		for i, col := range cols {
+			chunk, err := writeParquetChunk(pw, col, n, vals[i])
+			if err != nil {
+				return err
+			}
+			group.chunks[i] = chunk
+		}
+		groups = append(groups, group)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	meta := parquetMetadata(cols, groups, opts.To-opts.From)
+	size := make([]byte, 4)
+	binary.LittleEndian.PutUint32(size, uint32(meta.Len()))
+	for _, p := range [][]byte{meta.Bytes(), size, parquetMagic} {
+		if _, err := pw.Write(p); err != nil {
+			return err
+		}
+	}
+	return pw.Flush()
+}
+
+// writeParquetChunk writes a column chunk, consisting of a single data page
+func writeParquetChunk(w *countingWriter, col Column, n int64, vals []Value) (parquetChunk, error) {
+	var data []byte
+	for _, val := range vals {
+		if col.Size < 1 {
+			data = append(data, 0, 0, 0, 0)
+			binary.LittleEndian.PutUint32(data[len(data)-4:], uint32(len(val)))
+		}
+		data = append(data, val...)
+	}
+
+	hdr := new(thriftWriter)
+	hdr.I32(1, parquetPageData)
+	hdr.I32(2, int32(len(data)))
+	hdr.I32(3, int32(len(data)))
+	hdr.Struct(5)
+	hdr.I32(1, int32(n))
+	hdr.I32(2, parquetEncodingPlain)
+	hdr.I32(3, parquetEncodingRLE)
+	hdr.I32(4, parquetEncodingRLE)
+	hdr.End()
+	hdr.End()
+
+	chunk := parquetChunk{offset: w.n, size: int64(hdr.Len() + len(data))}
+	if _, err := w.Write(hdr.Bytes()); err != nil {
+		return chunk, err
+	}
+	_, err := w.Write(data)
+	return chunk, err
+}
+
+// parquetMetadata encodes the file metadata
+func parquetMetadata(cols []Column, groups []parquetRowGroup, rows int64) *thriftWriter {
+	meta := new(thriftWriter)
+	meta.I32(1, 1)
+
+	meta.List(2, thriftStruct, len(cols)+1)
+	meta.Begin()
+	meta.String(4, "schema")
+	meta.I32(5, int32(len(cols)))
+	meta.End()
+	for _, col := range cols {
+		meta.Begin()
+		meta.I32(1, parquetType(col))
+		if col.Size > 0 {
+			meta.I32(2, int32(col.Size))
+		}
+		meta.I32(3, parquetRequired)
+		meta.String(4, col.Name)
+		meta.End()
+	}
+
+	meta.I64(3, rows)
+	meta.List(4, thriftStruct, len(groups))
+	for _, group := range groups {
+		meta.Begin()
+		meta.List(1, thriftStruct, len(cols))
+
+		var total int64
+		for i, col := range cols {
+			chunk := group.chunks[i]
+			total += chunk.size
+
+			meta.Begin()
+			meta.I64(2, chunk.offset)
+			meta.Struct(3)
+			meta.I32(1, parquetType(col))
+			meta.List(2, thriftI32, 2)
+			meta.varint(parquetEncodingPlain)
+			meta.varint(parquetEncodingRLE)
+			meta.List(3, thriftBinary, 1)
+			meta.str(col.Name)
+			meta.I32(4, 0)
+			meta.I64(5, group.rows)
+			meta.I64(6, chunk.size)
+			meta.I64(7, chunk.size)
+			meta.I64(9, chunk.offset)
+			meta.End()
+			meta.End()
+		}
+
+		meta.I64(2, total)
+		meta.I64(3, group.rows)
+		meta.End()
+	}
+	meta.String(6, "collie")
+	meta.End()
+	return meta
+}
+
+func parquetType(col Column) int32 {
+	if col.Size > 0 {
+		return parquetTypeFixedLenByteArray
+	}
+	return parquetTypeByteArray
+}
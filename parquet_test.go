@@ -0,0 +1,85 @@
+package collie
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Parquet", func() {
+	var subject *Collection
+
+	BeforeEach(func() {
+		schema := CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "code", Size: 2},
+		})
+
+		var err error
+		subject, err = OpenCollection(testDir, schema)
+		Expect(err).NotTo(HaveOccurred())
+
+		txn := subject.Begin(5)
+		for i := 0; i < 5; i++ {
+			txn.Add(testRecord{"name": Value("name" + strconv.Itoa(i)), "code": Value(strconv.Itoa(i))})
+		}
+		_, err = txn.Commit()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should export files", func() {
+		buf := new(bytes.Buffer)
+		Expect(subject.ExportParquet(buf, &ExportOptions{From: 1, BatchRows: 3})).To(Succeed())
+
+		data := buf.Bytes()
+		Expect(data[:4]).To(Equal([]byte("PAR1")))
+		Expect(data[len(data)-4:]).To(Equal([]byte("PAR1")))
+
+		size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+		meta := data[len(data)-8-size : len(data)-8]
+		Expect(meta[:2]).To(Equal([]byte{0x15, 0x02}))
+		Expect(meta).To(ContainSubstring("collie"))
+
+		Expect(data).To(ContainSubstring("\x05\x00\x00\x00name1\x05\x00\x00\x00name2\x05\x00\x00\x00name3"))
+		Expect(data).To(ContainSubstring("\x05\x00\x00\x00name4"))
+		Expect(data).To(ContainSubstring("1\x002\x003\x00"))
+	})
+
+	It("should encode metadata", func() {
+		meta := parquetMetadata([]Column{{Name: "a", Size: 1}}, []parquetRowGroup{
+			{rows: 2, chunks: []parquetChunk{{offset: 4, size: 10}}},
+		}, 2)
+		Expect(meta.Bytes()).To(Equal([]byte{
+			0x15, 0x02, // 1: version 1
+			0x19, 0x2c, // 2: list<struct>, 2 elements
+			0x48, 0x06, 's', 'c', 'h', 'e', 'm', 'a', 0x15, 0x02, 0x00, // root
+			0x15, 0x0e, 0x15, 0x02, 0x15, 0x00, 0x18, 0x01, 'a', 0x00, // a
+			0x16, 0x04, // 3: num_rows 2
+			0x19, 0x1c, // 4: list<struct>, 1 element
+			0x19, 0x1c, // 1: columns
+			0x26, 0x08, // 2: file_offset 4
+			0x1c, // 3: meta_data
+			0x15, 0x0e, 0x19, 0x25, 0x00, 0x06, 0x19, 0x18, 0x01, 'a', 0x15, 0x00,
+			0x16, 0x04, 0x16, 0x14, 0x16, 0x14, 0x26, 0x08, 0x00,
+			0x00,
+			0x16, 0x14, 0x16, 0x04, 0x00, // total_byte_size, num_rows
+			0x28, 0x06, 'c', 'o', 'l', 'l', 'i', 'e', // 6: created_by
+			0x00,
+		}))
+	})
+
+	It("should abort when cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Expect(subject.ExportParquetContext(ctx, new(bytes.Buffer), nil)).To(Equal(context.Canceled))
+	})
+
+})
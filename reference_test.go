@@ -0,0 +1,118 @@
+//go:build reference
+// +build reference
+
+// Decodes exported files with the reference implementation. Requires
+// github.com/apache/arrow/go/v14, run with: go test -tags reference
+
+package collie
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/apache/arrow/go/v14/parquet/file"
+	"github.com/apache/arrow/go/v14/parquet/pqarrow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reference decoding", func() {
+	var subject *Collection
+
+	BeforeEach(func() {
+		schema := CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "code", Size: 2},
+			{Name: "tag", Index: IndexTypeHash, NoData: true},
+		})
+
+		var err error
+		subject, err = OpenCollection(testDir, schema)
+		Expect(err).NotTo(HaveOccurred())
+
+		txn := subject.Begin(25)
+		for i := 0; i < 25; i++ {
+			txn.Add(testRecord{"name": Value("name" + strconv.Itoa(i)), "code": Value(strconv.Itoa(i))})
+		}
+		_, err = txn.Commit()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should decode IPC files", func() {
+		buf := new(bytes.Buffer)
+		Expect(subject.ExportArrow(buf, &ExportOptions{From: 2, BatchRows: 10})).To(Succeed())
+
+		r, err := ipc.NewFileReader(bytes.NewReader(buf.Bytes()), ipc.WithAllocator(memory.DefaultAllocator))
+		Expect(err).NotTo(HaveOccurred())
+		defer r.Close()
+
+		Expect(r.Schema().Fields()).To(HaveLen(2))
+		Expect(r.Schema().Field(0).Name).To(Equal("name"))
+		Expect(r.Schema().Field(0).Type.ID()).To(Equal(arrow.BINARY))
+		Expect(r.Schema().Field(1).Name).To(Equal("code"))
+		Expect(r.Schema().Field(1).Type).To(Equal(&arrow.FixedSizeBinaryType{ByteWidth: 2}))
+
+		var names, codes []string
+		Expect(r.NumRecords()).To(Equal(3))
+		for i := 0; i < r.NumRecords(); i++ {
+			rec, err := r.Record(i)
+			Expect(err).NotTo(HaveOccurred())
+			for j := 0; j < int(rec.NumRows()); j++ {
+				names = append(names, string(rec.Column(0).(*array.Binary).Value(j)))
+				codes = append(codes, string(rec.Column(1).(*array.FixedSizeBinary).Value(j)))
+			}
+		}
+		Expect(names).To(HaveLen(23))
+		Expect(names[0]).To(Equal("name2"))
+		Expect(names[22]).To(Equal("name24"))
+		Expect(codes[0]).To(Equal("2\x00"))
+		Expect(codes[22]).To(Equal("24"))
+	})
+
+	It("should decode Parquet files", func() {
+		buf := new(bytes.Buffer)
+		Expect(subject.ExportParquet(buf, &ExportOptions{From: 2, BatchRows: 10})).To(Succeed())
+
+		r, err := file.NewParquetReader(bytes.NewReader(buf.Bytes()))
+		Expect(err).NotTo(HaveOccurred())
+		defer r.Close()
+		Expect(r.NumRowGroups()).To(Equal(3))
+		Expect(r.NumRows()).To(Equal(int64(23)))
+
+		fr, err := pqarrow.NewFileReader(r, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+		Expect(err).NotTo(HaveOccurred())
+		tbl, err := fr.ReadTable(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		defer tbl.Release()
+
+		Expect(tbl.Schema().Field(0).Name).To(Equal("name"))
+		Expect(tbl.Schema().Field(1).Name).To(Equal("code"))
+
+		var names, codes []string
+		for _, chunk := range tbl.Column(0).Data().Chunks() {
+			for j := 0; j < chunk.Len(); j++ {
+				names = append(names, string(chunk.(*array.Binary).Value(j)))
+			}
+		}
+		for _, chunk := range tbl.Column(1).Data().Chunks() {
+			for j := 0; j < chunk.Len(); j++ {
+				codes = append(codes, string(chunk.(*array.FixedSizeBinary).Value(j)))
+			}
+		}
+		Expect(names).To(HaveLen(23))
+		Expect(names[0]).To(Equal("name2"))
+		Expect(names[22]).To(Equal("name24"))
+		Expect(codes[0]).To(Equal("2\x00"))
+		Expect(codes[22]).To(Equal("24"))
+	})
+
+})
@@ -0,0 +1,279 @@
+package collie
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+)
+
+const (
+	replicationBatchRows = 1000
+	replicationBuffer    = 64
+)
+
+// Interval between reconnection attempts of followers
+var replicationRetry = time.Second
+
+var (
+	errReplicationGap         = errors.New("collie: replication gap")
+	errReplicationUnavailable = errors.New("collie: replication offset no longer available")
+	errReplicationAhead       = errors.New("collie: replication offset ahead of the leader")
+)
+
+// replicationFrame is the unit of replication streams, it either holds
+// a batch or the error which ended the stream on the leader
+type replicationFrame struct {
+	Batch *commitBatch
+	Error string
+}
+
+// leaderError is returned to followers when the leader failed
+func leaderError(msg string) error {
+	switch msg {
+	case errReplicationUnavailable.Error():
+		return errReplicationUnavailable
+	case errReplicationAhead.Error():
+		return errReplicationAhead
+	}
+	return errors.New(msg)
+}
+
+// ReplicateTo streams all committed rows starting at offset to w and
+// continues to stream new commits until stop is closed or w fails. If
+// stop is already closed, only rows committed so far are streamed.
+// The stream can be applied to a follower collection via ApplyFrom.
+// Errors are also sent to the follower before returning.
+func (c *Collection) ReplicateTo(w io.Writer, offset int64, stop <-chan struct{}) error {
+	enc := gob.NewEncoder(w)
+	err := c.replicateFrom(enc, offset, stop)
+	if err != nil {
+		_ = enc.Encode(replicationFrame{Error: err.Error()})
+	}
+	return err
+}
+
+func (c *Collection) replicateFrom(enc *gob.Encoder, offset int64, stop <-chan struct{}) error {
+	if offset > c.Offset() {
+		return errReplicationAhead
+	}
+	for {
+		if offset < c.FirstOffset() {
+			return errReplicationUnavailable
+		}
+
+		c.wmux.Lock()
+		l, current := c.listen(replicationBuffer)
+		c.wmux.Unlock()
+
+		err := c.replicate(enc, l, &offset, current, stop)
+		c.unlisten(l)
+		if err != nil || isClosed(stop) {
+			return err
+		}
+	}
+}
+
+// replicate catches up with current, then streams batches received by
+// the listener. Returns without an error if the listener can't keep up.
+func (c *Collection) replicate(enc *gob.Encoder, l *listener, offset *int64, current int64, stop <-chan struct{}) error {
+	batches := c.newBatchReader()
+	for *offset < current {
+		limit := *offset + replicationBatchRows
+		if limit > current {
+			limit = current
+		}
+
+		batch, err := batches.read(*offset, limit)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(replicationFrame{Batch: batch}); err != nil {
+			return err
+		}
+		*offset += batch.Rows
+	}
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case batch, ok := <-l.ch:
+			if !ok {
+				return nil
+			}
+			if err := enc.Encode(replicationFrame{Batch: batch}); err != nil {
+				return err
+			}
+			*offset += batch.Rows
+		}
+	}
+}
+
+// ApplyFrom applies a replication stream, read from r, until r is
+// exhausted. Batches must follow on from the current offset, previously
+// applied rows are skipped. Errors sent by the leader are returned.
+func (c *Collection) ApplyFrom(r io.Reader) error {
+	_, err := c.applyFrom(r)
+	return err
+}
+
+// applyFrom applies a replication stream, reports whether errors were
+// caused by reading r rather than by the leader or by applying batches
+func (c *Collection) applyFrom(r io.Reader) (bool, error) {
+	dec := gob.NewDecoder(r)
+	for {
+		var frame replicationFrame
+		if err := dec.Decode(&frame); err == io.EOF {
+			return false, nil
+		} else if err != nil {
+			return true, err
+		} else if frame.Error != "" {
+			return false, leaderError(frame.Error)
+		} else if frame.Batch == nil {
+			return false, errIncompleteBatch
+		}
+
+		if err := c.apply(frame.Batch); err != nil {
+			return false, err
+		}
+	}
+}
+
+func (c *Collection) apply(batch *commitBatch) error {
+	c.wmux.Lock()
+	defer c.wmux.Unlock()
+
+	offset := c.Offset()
+	if batch.Offset+batch.Rows <= offset {
+		return nil
+	} else if batch.Offset > offset {
+		return errReplicationGap
+	}
+
+	head := c.head()
+	if head == nil {
+		return ErrClosed
+	}
+	for name := range head.columns {
+		if int64(len(batch.Values[name])) != batch.Rows {
+			return errIncompleteBatch
+		}
+	}
+	for name := range head.indices {
+		if _, ok := batch.Postings[name]; !ok {
+			return errIncompleteBatch
+		}
+	}
+
+	recs := batch.records()[offset-batch.Offset:]
+	w, err := (&Txn{c: c, stash: recs}).write(context.Background())
+	if err != nil {
+		return err
+	}
+	_, err = w.publish()
+	return err
+}
+
+// ServeReplication accepts follower connections on ln and streams
+// committed rows to each of them, starting at the offset they announce.
+// It blocks until ln fails. Follower errors are passed to
+// Options.OnReplicationError.
+func (c *Collection) ServeReplication(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := c.serveFollower(conn); err != nil {
+				c.replicationError(err)
+			}
+		}()
+	}
+}
+
+func (c *Collection) serveFollower(conn net.Conn) error {
+	defer conn.Close()
+
+	var offset int64
+	if err := binary.Read(conn, binary.BigEndian, &offset); err != nil {
+		return err
+	}
+
+	// Stop once the follower disconnects
+	stop := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, conn)
+		close(stop)
+	}()
+
+	return c.ReplicateTo(conn, offset, stop)
+}
+
+// Follow connects to a replication leader at addr and applies its
+// stream. After connection failures, it reconnects and resumes from the
+// current offset, failures are passed to Options.OnReplicationError.
+// Follow blocks until stop is closed, errors reported by the leader or
+// while applying batches are returned.
+func (c *Collection) Follow(addr string, stop <-chan struct{}) error {
+	for {
+		retry, err := c.follow(addr, stop)
+		if isClosed(stop) {
+			return nil
+		} else if err != nil && !retry {
+			return err
+		} else if err != nil {
+			c.replicationError(err)
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(replicationRetry):
+		}
+	}
+}
+
+func (c *Collection) follow(addr string, stop <-chan struct{}) (bool, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return true, err
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-stop:
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := binary.Write(conn, binary.BigEndian, c.Offset()); err != nil {
+		return true, err
+	}
+	return c.applyFrom(conn)
+}
+
+func (c *Collection) replicationError(err error) {
+	if fn := c.opts.OnReplicationError; fn != nil {
+		fn(err)
+	}
+}
+
+// isClosed returns true if ch is closed
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,208 @@
+package collie
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Replication", func() {
+	var leader, follower *Collection
+	var stop chan struct{}
+
+	add := func(names ...string) {
+		txn := leader.Begin(len(names))
+		for _, name := range names {
+			txn.Add(testRecord{"name": Value(name), "tag": Value(name[:1])})
+		}
+		_, err := txn.Commit()
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	expectReplicated := func() {
+		Eventually(follower.Offset).Should(Equal(leader.Offset()))
+		for off := int64(0); off < leader.Offset(); off++ {
+			exp, err := leader.Value("name", off)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(follower.Value("name", off)).To(Equal(exp))
+		}
+		for _, tag := range []string{"a", "b", "c"} {
+			exp, err := leader.Offsets("tag", Value(tag))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(follower.Offsets("tag", Value(tag))).To(Equal(exp))
+		}
+	}
+
+	BeforeEach(func() {
+		schema := CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "tag", Index: IndexTypeHash, NoData: true},
+		})
+
+		var err error
+		leader, err = OpenCollectionWithOptions(filepath.Join(testDir, "leader"), schema, &Options{SegmentRows: 3})
+		Expect(err).NotTo(HaveOccurred())
+		follower, err = OpenCollection(filepath.Join(testDir, "follower"), schema)
+		Expect(err).NotTo(HaveOccurred())
+
+		stop = make(chan struct{})
+		add("alice", "bob")
+		add("carol", "anne")
+		add("bill")
+	})
+
+	AfterEach(func() {
+		leader.Close()
+		follower.Close()
+	})
+
+	It("should replicate committed rows", func() {
+		close(stop)
+
+		buf := new(bytes.Buffer)
+		Expect(leader.ReplicateTo(buf, 0, stop)).To(Succeed())
+		Expect(follower.ApplyFrom(buf)).To(Succeed())
+		expectReplicated()
+	})
+
+	It("should resume from the follower offset", func() {
+		close(stop)
+
+		buf := new(bytes.Buffer)
+		Expect(leader.ReplicateTo(buf, 0, stop)).To(Succeed())
+		Expect(follower.ApplyFrom(buf)).To(Succeed())
+
+		add("bert", "chris")
+		buf.Reset()
+		Expect(leader.ReplicateTo(buf, 0, stop)).To(Succeed())
+		Expect(follower.ApplyFrom(buf)).To(Succeed())
+		expectReplicated()
+
+		add("cecil")
+		buf.Reset()
+		Expect(leader.ReplicateTo(buf, follower.Offset(), stop)).To(Succeed())
+		Expect(follower.ApplyFrom(buf)).To(Succeed())
+		expectReplicated()
+	})
+
+	It("should reject gaps", func() {
+		close(stop)
+
+		buf := new(bytes.Buffer)
+		Expect(leader.ReplicateTo(buf, 2, stop)).To(Succeed())
+		Expect(follower.ApplyFrom(buf)).To(Equal(errReplicationGap))
+		Expect(follower.Offset()).To(Equal(int64(0)))
+	})
+
+	It("should stream new commits", func() {
+		r, w := io.Pipe()
+		go func() {
+			defer GinkgoRecover()
+			defer w.Close()
+			Expect(leader.ReplicateTo(w, 0, stop)).To(Succeed())
+		}()
+		go follower.ApplyFrom(r)
+		expectReplicated()
+
+		add("alfred")
+		add("barbara", "cynthia")
+		expectReplicated()
+
+		close(stop)
+	})
+
+	It("should replicate over TCP", func() {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer ln.Close()
+		go leader.ServeReplication(ln)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			follower.Follow(ln.Addr().String(), stop)
+		}()
+		expectReplicated()
+
+		add("alfred")
+		expectReplicated()
+
+		close(stop)
+		Eventually(done).Should(BeClosed())
+
+		add("barbara", "cynthia")
+		stop = make(chan struct{})
+		done = make(chan struct{})
+		go func() {
+			defer close(done)
+			follower.Follow(ln.Addr().String(), stop)
+		}()
+		expectReplicated()
+
+		close(stop)
+		Eventually(done, time.Second).Should(BeClosed())
+	})
+
+	It("should return leader errors", func() {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer ln.Close()
+
+		served := make(chan error, 1)
+		leader.opts.OnReplicationError = func(err error) { served <- err }
+		leader.opts.RetainRows = 1
+		Expect(leader.ApplyRetention()).To(Succeed())
+		go leader.ServeReplication(ln)
+
+		Expect(follower.Follow(ln.Addr().String(), stop)).To(Equal(errReplicationUnavailable))
+		Eventually(served).Should(Receive(Equal(errReplicationUnavailable)))
+		Expect(follower.Offset()).To(Equal(int64(0)))
+	})
+
+	It("should reject followers ahead of the leader", func() {
+		close(stop)
+
+		buf := new(bytes.Buffer)
+		Expect(leader.ReplicateTo(buf, 6, stop)).To(Equal(errReplicationAhead))
+		Expect(follower.ApplyFrom(buf)).To(Equal(errReplicationAhead))
+	})
+
+	It("should return apply errors", func() {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer ln.Close()
+		go leader.ServeReplication(ln)
+
+		Expect(follower.Close()).To(Succeed())
+		Expect(follower.Follow(ln.Addr().String(), stop)).To(Equal(ErrClosed))
+	})
+
+	It("should report and retry connection failures", func() {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		addr := ln.Addr().String()
+		Expect(ln.Close()).To(Succeed())
+
+		failed := make(chan error, 1)
+		follower.opts.OnReplicationError = func(err error) {
+			select {
+			case failed <- err:
+			default:
+			}
+		}
+
+		done := make(chan error, 1)
+		go func() { done <- follower.Follow(addr, stop) }()
+		Eventually(failed).Should(Receive(HaveOccurred()))
+		Consistently(done).ShouldNot(Receive())
+
+		close(stop)
+		Eventually(done, 2*time.Second).Should(Receive(BeNil()))
+	})
+
+})
@@ -0,0 +1,154 @@
+package collie
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const droppedSuffix = ".dropped"
+
+// ApplyRetention drops segments beyond the configured retention limits.
+// Retention is applied automatically whenever the collection rolls over
+// to a new segment.
+func (c *Collection) ApplyRetention() error {
+	if c.opts.ReadOnly {
+		return ErrReadOnly
+	}
+
+	c.wmux.Lock()
+	defer c.wmux.Unlock()
+
+	return c.retain()
+}
+
+// retain drops expired segments, must only be called
+// while holding the write lock
+func (c *Collection) retain() error {
+	if !c.opts.retained() {
+		return nil
+	}
+
+	c.smux.RLock()
+	if len(c.segs) == 0 {
+		c.smux.RUnlock()
+		return ErrClosed
+	}
+	n, err := c.expired()
+	c.smux.RUnlock()
+
+	if n > 0 {
+		if e := c.drop(n); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// expired returns the number of leading segments beyond the retention
+// limits, must be called while holding a read lock
+func (c *Collection) expired() (int, error) {
+	rows := c.Offset() - c.segs[0].base
+	size := int64(0)
+	if c.opts.RetainBytes > 0 {
+		for _, seg := range c.segs {
+			size += seg.Size()
+		}
+	}
+	cutoff := time.Now().Add(-c.opts.RetainAge)
+
+	for n := 0; n < len(c.segs)-1; n++ {
+		seg := c.segs[n]
+		segRows := c.segs[n+1].base - seg.base
+
+		switch {
+		case segRows == 0:
+		case c.opts.RetainRows > 0 && rows > c.opts.RetainRows:
+		case c.opts.RetainBytes > 0 && size > c.opts.RetainBytes:
+		case c.opts.RetainAge > 0:
+			last, err := seg.columns[c.opts.RetainColumn].Get(segRows - 1)
+			if err != nil {
+				return n, err
+			}
+			if !decodeTimestamp(last).Before(cutoff) {
+				return n, nil
+			}
+		default:
+			return n, nil
+		}
+
+		rows -= segRows
+		size -= seg.Size()
+	}
+	return len(c.segs) - 1, nil
+}
+
+// drop removes the n leading segments
+func (c *Collection) drop(n int) (err error) {
+	c.smux.Lock()
+	dropped := c.segs[:n]
+	c.segs = append([]*segment(nil), c.segs[n:]...)
+	c.smux.Unlock()
+
+	for _, seg := range dropped {
+		if e := c.remove(seg); e != nil {
+			err = e
+		}
+	}
+	return
+}
+
+// remove closes seg and deletes its files. Segment directories are
+// renamed before deletion, so partial removals are never mistaken for
+// segments when the collection is re-opened.
+func (c *Collection) remove(seg *segment) error {
+	if err := seg.Close(); err != nil {
+		return err
+	}
+
+	if seg.dir != c.dir {
+		trash := seg.dir + droppedSuffix
+		if err := os.Rename(seg.dir, trash); err != nil {
+			return err
+		}
+		return os.RemoveAll(trash)
+	}
+
+	// Remove all column and index files, including auxiliary files
+	// and temporary copies
+	for _, col := range c.schema.Columns() {
+		names, err := filepath.Glob(filepath.Join(c.dir, col.Name+".c[ci]*"))
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if err := os.RemoveAll(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removeDropped deletes leftovers of interrupted segment removals
+func removeDropped(dir string) error {
+	trash, err := filepath.Glob(filepath.Join(dir, "*"+droppedSuffix))
+	if err != nil {
+		return err
+	}
+	for _, name := range trash {
+		if err := os.RemoveAll(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeTimestamp decodes big-endian Unix seconds
+func decodeTimestamp(v Value) time.Time {
+	if len(v) < 8 {
+		return time.Time{}
+	}
+	return time.Unix(int64(binary.BigEndian.Uint64(v)), 0)
+}
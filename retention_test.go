@@ -0,0 +1,155 @@
+package collie
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retention", func() {
+	var subject *Collection
+	var schema *Schema
+
+	ts := func(t time.Time) Value {
+		v := make(Value, 8)
+		binary.BigEndian.PutUint64(v, uint64(t.Unix()))
+		return v
+	}
+
+	open := func(opts *Options) {
+		var err error
+		subject, err = OpenCollectionWithOptions(testDir, schema, opts)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	add := func(n int, t time.Time) {
+		txn := subject.Begin(n)
+		for i := 0; i < n; i++ {
+			txn.Add(testRecord{"name": Value("x"), "ts": ts(t), "tag": Value("y")})
+		}
+		_, err := txn.Commit()
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		schema = CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "ts", Size: 8},
+			{Name: "tag", Index: IndexTypeHash, NoData: true},
+		})
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should validate options", func() {
+		_, err := OpenCollectionWithOptions(testDir, schema, &Options{RetainRows: 10})
+		Expect(err).To(MatchError("collie: retention requires a segmented collection"))
+
+		_, err = OpenCollectionWithOptions(testDir, schema, &Options{SegmentRows: 10, RetainAge: time.Hour})
+		Expect(err).To(MatchError("collie: invalid retention column ''"))
+
+		_, err = OpenCollectionWithOptions(testDir, schema, &Options{SegmentRows: 10, RetainAge: time.Hour, RetainColumn: "name"})
+		Expect(err).To(MatchError("collie: retention column 'name' must store 8-byte values"))
+
+		open(&Options{SegmentRows: 10, RetainAge: time.Hour, RetainColumn: "ts"})
+	})
+
+	It("should drop segments by row count", func() {
+		open(&Options{SegmentRows: 3, RetainRows: 5})
+		for i := 0; i < 4; i++ {
+			add(3, time.Now())
+		}
+		Expect(subject.Offset()).To(Equal(int64(12)))
+		Expect(subject.FirstOffset()).To(Equal(int64(6)))
+		Expect(subject.segs).To(HaveLen(2))
+
+		_, err := os.Stat(filepath.Join(testDir, segmentName(0)))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		_, err = os.Stat(filepath.Join(testDir, segmentName(6)))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = subject.Value("name", 5)
+		Expect(err).To(Equal(ErrNotFound))
+		val, err := subject.Value("name", 6)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(val).To(Equal([]byte("x")))
+
+		offs, err := subject.Offsets("tag", Value("y"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offs).To(Equal([]int64{6, 7, 8, 9, 10, 11}))
+	})
+
+	It("should drop segments by size", func() {
+		open(&Options{SegmentRows: 2, RetainBytes: 40})
+		for i := 0; i < 4; i++ {
+			add(2, time.Now())
+		}
+		Expect(subject.FirstOffset()).To(Equal(int64(4)))
+		Expect(subject.segs).To(HaveLen(2))
+	})
+
+	It("should drop segments by age", func() {
+		open(&Options{SegmentRows: 2, RetainAge: time.Hour, RetainColumn: "ts"})
+		add(2, time.Now().Add(-3*time.Hour))
+		add(2, time.Now().Add(-2*time.Hour))
+		add(2, time.Now().Add(-time.Minute))
+		add(1, time.Now())
+		Expect(subject.FirstOffset()).To(Equal(int64(4)))
+
+		offs, err := subject.Offsets("tag", Value("y"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offs).To(Equal([]int64{4, 5, 6}))
+	})
+
+	It("should apply retention on demand", func() {
+		open(&Options{SegmentRows: 2})
+		for i := 0; i < 3; i++ {
+			add(2, time.Now())
+		}
+		Expect(subject.Close()).NotTo(HaveOccurred())
+
+		open(&Options{SegmentRows: 2, RetainRows: 2})
+		Expect(subject.FirstOffset()).To(Equal(int64(0)))
+		Expect(subject.ApplyRetention()).To(Succeed())
+		Expect(subject.FirstOffset()).To(Equal(int64(4)))
+		Expect(subject.Offset()).To(Equal(int64(6)))
+
+		Expect(subject.Close()).NotTo(HaveOccurred())
+		open(&Options{SegmentRows: 2})
+		Expect(subject.FirstOffset()).To(Equal(int64(4)))
+		Expect(subject.Offset()).To(Equal(int64(6)))
+	})
+
+	It("should remove all files of dropped root segments", func() {
+		open(nil)
+		add(2, time.Now())
+		Expect(subject.Close()).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(testDir, "tag.ci.tmp"), nil, 0644)).To(Succeed())
+
+		open(&Options{SegmentRows: 2, RetainRows: 2})
+		add(2, time.Now())
+		add(2, time.Now())
+		Expect(subject.FirstOffset()).To(Equal(int64(2)))
+
+		names, err := filepath.Glob(filepath.Join(testDir, "*.c?*"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names).To(BeEmpty())
+	})
+
+	It("should remove leftovers of interrupted drops", func() {
+		trash := filepath.Join(testDir, segmentName(0)+droppedSuffix)
+		Expect(os.MkdirAll(trash, 0755)).To(Succeed())
+
+		open(&Options{SegmentRows: 2})
+		_, err := os.Stat(trash)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+})
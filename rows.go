@@ -0,0 +1,97 @@
+package collie
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bsm/collie/column"
+)
+
+// Row returns the row at offset, populated with the values of the named
+// data columns, or of all data columns if no names are given. Index values
+// are not populated.
+func (c *Collection) Row(offset int64, names ...string) (*Row, error) {
+	rows, err := c.Rows([]int64{offset}, names...)
+	if err != nil {
+		return nil, err
+	}
+	return rows[0], nil
+}
+
+// Rows returns the rows at the given offsets, in the same order, see Row
+// for details. Values are fetched column by column, in ascending offset
+// order. Returns ErrNotFound if any of the offsets is out of range.
+func (c *Collection) Rows(offsets []int64, names ...string) ([]*Row, error) {
+	return c.RowsContext(context.Background(), offsets, names...)
+}
+
+// RowsContext behaves like Rows, but aborts when ctx is done, checking
+// between values
+func (c *Collection) RowsContext(ctx context.Context, offsets []int64, names ...string) ([]*Row, error) {
+	c.smux.RLock()
+	defer c.smux.RUnlock()
+
+	if len(c.segs) == 0 {
+		return nil, ErrClosed
+	}
+
+	if len(names) == 0 {
+		for _, col := range c.schema.Columns() {
+			if _, ok := c.segs[0].columns[col.Name]; ok {
+				names = append(names, col.Name)
+			}
+		}
+	}
+	for _, name := range names {
+		if _, ok := c.segs[0].columns[name]; !ok {
+			return nil, ErrColumnNotFound
+		}
+	}
+	defer c.counters.read(int64(len(offsets)*len(names)), time.Now())
+
+	order := &rowOrder{pos: make([]int, len(offsets)), offsets: offsets}
+	for i := range order.pos {
+		order.pos[i] = i
+	}
+	sort.Sort(order)
+
+	segs := make([]*segment, len(offsets))
+	rows := make([]*Row, len(offsets))
+	max := c.Offset()
+	for i, offset := range offsets {
+		if offset >= max {
+			return nil, ErrNotFound
+		} else if segs[i] = c.segmentAt(offset); segs[i] == nil {
+			return nil, ErrNotFound
+		}
+		rows[i] = newRow(len(names), 0)
+	}
+
+	for _, name := range names {
+		for _, i := range order.pos {
+			if err := canceled(ctx); err != nil {
+				return nil, err
+			}
+			seg := segs[i]
+			val, err := seg.columns[name].Get(offsets[i] - seg.base)
+			if err == column.ErrNotFound {
+				return nil, ErrNotFound
+			} else if err != nil {
+				return nil, err
+			}
+			rows[i].SetColumn(name, val)
+		}
+	}
+	return rows, nil
+}
+
+// rowOrder sorts row positions by offset
+type rowOrder struct {
+	pos     []int
+	offsets []int64
+}
+
+func (o *rowOrder) Len() int           { return len(o.pos) }
+func (o *rowOrder) Less(i, j int) bool { return o.offsets[o.pos[i]] < o.offsets[o.pos[j]] }
+func (o *rowOrder) Swap(i, j int)      { o.pos[i], o.pos[j] = o.pos[j], o.pos[i] }
@@ -0,0 +1,77 @@
+package collie
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rows", func() {
+	var subject *Collection
+
+	BeforeEach(func() {
+		schema := CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "code", Size: 2},
+			{Name: "tag", Index: IndexTypeHash, NoData: true},
+		})
+
+		var err error
+		subject, err = OpenCollectionWithOptions(testDir, schema, &Options{SegmentRows: 2})
+		Expect(err).NotTo(HaveOccurred())
+
+		txn := subject.Begin(3)
+		for _, name := range []string{"alice", "bob", "anna"} {
+			txn.Add(testRecord{"name": Value(name), "code": Value(name[:2]), "tag": Value(name[:1])})
+		}
+		_, err = txn.Commit()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should retrieve rows", func() {
+		row, err := subject.Row(1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(row.ValueAt("name")).To(Equal(Value("bob")))
+		Expect(row.ValueAt("code")).To(Equal(Value("bo")))
+		Expect(row.IValuesAt("tag")).To(BeEmpty())
+
+		row, err = subject.Row(2, "code")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(row.ValueAt("name")).To(BeNil())
+		Expect(row.ValueAt("code")).To(Equal(Value("an")))
+	})
+
+	It("should retrieve multiple rows across segments", func() {
+		rows, err := subject.Rows([]int64{2, 0, 2, 1}, "name")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rows).To(HaveLen(4))
+
+		names := make([]string, len(rows))
+		for i, row := range rows {
+			val, _ := row.ValueAt("name")
+			names[i] = string(val)
+		}
+		Expect(names).To(Equal([]string{"anna", "alice", "anna", "bob"}))
+
+		stats, err := subject.Stats(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.Reads).To(Equal(int64(4)))
+	})
+
+	It("should validate", func() {
+		_, err := subject.Row(0, "tag")
+		Expect(err).To(Equal(ErrColumnNotFound))
+		_, err = subject.Row(-1)
+		Expect(err).To(Equal(ErrNotFound))
+		_, err = subject.Rows([]int64{0, 3})
+		Expect(err).To(Equal(ErrNotFound))
+
+		rows, err := subject.Rows(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rows).To(BeEmpty())
+	})
+
+})
@@ -1,6 +1,16 @@
 package collie
 
-import "errors"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// The schema file holds the JSON-encoded schema of a collection
+const schemaFile = "SCHEMA"
 
 type Schema struct{ columns []Column }
 
@@ -28,6 +38,105 @@ func NewSchema(cols []Column) (*Schema, error) {
 
 func (s *Schema) Columns() []Column { return s.columns }
 
+// column returns the column with the given name
+func (s *Schema) column(name string) (Column, bool) {
+	for _, col := range s.columns {
+		if col.Name == name {
+			return col, true
+		}
+	}
+	return Column{}, false
+}
+
+// ReadSchema reads the schema stored in a collection directory. Schemas
+// are stored whenever a collection is opened for writing.
+func ReadSchema(dir string) (*Schema, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, schemaFile))
+	if err != nil {
+		return nil, err
+	}
+
+	schema := new(Schema)
+	if err := json.Unmarshal(data, schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string][]Column{"columns": s.columns})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Columns []Column `json:"columns"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	schema, err := NewSchema(v.Columns)
+	if err != nil {
+		return err
+	}
+	*s = *schema
+	return nil
+}
+
+// marshalSchema encodes a schema for storage
+func marshalSchema(schema *Schema) ([]byte, error) {
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// checkSchema fails with ErrSchemaMismatch if dir holds a different
+// schema. Directories without a stored schema pass.
+func checkSchema(dir string, schema *Schema) error {
+	stored, err := ReadSchema(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	data, err := marshalSchema(schema)
+	if err != nil {
+		return err
+	}
+	other, err := marshalSchema(stored)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(data, other) {
+		return ErrSchemaMismatch
+	}
+	return nil
+}
+
+// writeSchema stores the schema in dir, replacing any different one
+func writeSchema(dir string, schema *Schema) error {
+	data, err := marshalSchema(schema)
+	if err != nil {
+		return err
+	}
+
+	fname := filepath.Join(dir, schemaFile)
+	if stored, err := ioutil.ReadFile(fname); err == nil && bytes.Equal(stored, data) {
+		return nil
+	}
+
+	// Write atomically, via a temporary file
+	if err := ioutil.WriteFile(fname+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(fname+".tmp", fname)
+}
+
 func (s *Schema) validate(known map[string]bool, col *Column) (err error) {
 	if err = col.Validate(); err != nil {
 		return
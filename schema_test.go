@@ -1,6 +1,9 @@
 package collie
 
 import (
+	"encoding/json"
+	"path/filepath"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -32,4 +35,60 @@ var _ = Describe("Schema", func() {
 		Expect(err.Error()).To(Equal("collie: duplicate column 'first'"))
 	})
 
+	It("should encode to JSON", func() {
+		schema := CreateSchema([]Column{
+			{Name: "first", Size: 30},
+			{Name: "tag", Index: IndexTypeHash, NoData: true},
+		})
+		data, err := json.Marshal(schema)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal(`{"columns":[{"name":"first","size":30},{"name":"tag","index":"hash","nodata":true}]}`))
+
+		decoded := new(Schema)
+		Expect(json.Unmarshal(data, decoded)).To(Succeed())
+		Expect(decoded).To(Equal(schema))
+
+		Expect(json.Unmarshal([]byte(`{"columns":[{"name":"x","index":""}]}`), decoded)).To(MatchError("collie: invalid index type ''"))
+		Expect(json.Unmarshal([]byte(`{"columns":[{"name":"x"},{"name":"x"}]}`), decoded)).To(MatchError("collie: duplicate column 'x'"))
+	})
+
+	It("should be stored in collection directories", func() {
+		schema := CreateSchema([]Column{{Name: "first", Size: 30}})
+		coll, err := OpenCollection(testDir, schema)
+		Expect(err).NotTo(HaveOccurred())
+		defer coll.Close()
+
+		Expect(ReadSchema(testDir)).To(Equal(schema))
+		Expect(filepath.Join(testDir, schemaFile+".tmp")).NotTo(BeAnExistingFile())
+	})
+
+	It("should replace mismatching schemas", func() {
+		coll, err := OpenCollection(testDir, CreateSchema([]Column{{Name: "first", Size: 30}}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(coll.Close()).To(Succeed())
+
+		coll, err = OpenCollection(testDir, CreateSchema([]Column{{Name: "first", Size: 30}, {Name: "last"}}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(coll.Close()).To(Succeed())
+		Expect(ReadSchema(testDir)).To(Equal(CreateSchema([]Column{{Name: "first", Size: 30}, {Name: "last"}})))
+	})
+
+	It("should reject mismatching schemas, if checked", func() {
+		schema := CreateSchema([]Column{{Name: "first", Size: 30}})
+		coll, err := OpenCollectionWithOptions(testDir, schema, &Options{CheckSchema: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(coll.Close()).To(Succeed())
+
+		coll, err = OpenCollectionWithOptions(testDir, schema, &Options{CheckSchema: true, ReadOnly: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(coll.Close()).To(Succeed())
+
+		other := CreateSchema([]Column{{Name: "first"}})
+		_, err = OpenCollectionWithOptions(testDir, other, &Options{CheckSchema: true})
+		Expect(err).To(Equal(ErrSchemaMismatch))
+		_, err = OpenCollectionWithOptions(testDir, other, &Options{CheckSchema: true, ReadOnly: true})
+		Expect(err).To(Equal(ErrSchemaMismatch))
+		Expect(ReadSchema(testDir)).To(Equal(schema))
+	})
+
 })
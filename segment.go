@@ -0,0 +1,192 @@
+package collie
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bsm/collie/column"
+)
+
+var validSegmentName = regexp.MustCompile(`^\d{20}$`)
+
+const segmentCreatedFile = "CREATED"
+
+// A segment holds a contiguous range of rows, with its own set of
+// column files and indices. Indices store global offsets.
+type segment struct {
+	dir     string
+	base    int64
+	created time.Time
+	columns map[string]column.Column
+	indices map[string]column.Index
+}
+
+// segmentName returns the directory name of a segment starting at base
+func segmentName(base int64) string { return fmt.Sprintf("%020d", base) }
+
+// listSegments returns the base offsets of all segment
+// directories within dir, in ascending order
+func listSegments(dir string) ([]int64, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var bases []int64
+	for _, fi := range infos {
+		if !fi.IsDir() || !validSegmentName.MatchString(fi.Name()) {
+			continue
+		}
+		base, err := strconv.ParseInt(fi.Name(), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		bases = append(bases, base)
+	}
+	sort.Sort(int64Slice(bases))
+	return bases, nil
+}
+
+// hasSegmentFiles returns true if dir contains any column files of schema
+func hasSegmentFiles(dir string, schema *Schema) bool {
+	for _, col := range schema.Columns() {
+		for _, ext := range []string{".cc", ".ci"} {
+			if _, err := os.Stat(filepath.Join(dir, col.Name+ext)); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// createSegment creates a new segment directory
+func createSegment(dir string, base int64, schema *Schema, opts *Options) (*segment, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	created, err := time.Now().MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, segmentCreatedFile), created, 0644); err != nil {
+		return nil, err
+	}
+	return openSegment(dir, base, schema, opts)
+}
+
+// openSegment opens a segment in dir
+func openSegment(dir string, base int64, schema *Schema, opts *Options) (*segment, error) {
+	seg := &segment{
+		dir:     dir,
+		base:    base,
+		created: time.Now(),
+		columns: make(map[string]column.Column),
+		indices: make(map[string]column.Index),
+	}
+
+	if data, err := ioutil.ReadFile(filepath.Join(dir, segmentCreatedFile)); err == nil {
+		if err := seg.created.UnmarshalText(data); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, col := range schema.Columns() {
+		if err := seg.register(&col, opts); err != nil {
+			seg.Close()
+			return nil, err
+		}
+	}
+	return seg, nil
+}
+
+// Len returns the number of complete rows
+func (s *segment) Len() int64 {
+	rows := int64(-1)
+	for _, col := range s.columns {
+		if cln := col.Len(); rows < 0 || cln < rows {
+			rows = cln
+		}
+	}
+	if rows < 0 {
+		return 0
+	}
+	return rows
+}
+
+// Size returns the number of bytes stored in columns
+func (s *segment) Size() (size int64) {
+	for _, col := range s.columns {
+		size += col.Size()
+	}
+	return
+}
+
+// Refresh picks up rows appended by other processes
+func (s *segment) Refresh() error {
+	for _, col := range s.columns {
+		if err := col.Refresh(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the segment
+func (s *segment) Close() (err error) {
+	for _, c := range s.columns {
+		if e := c.Close(); e != nil {
+			err = e
+		}
+	}
+	for _, x := range s.indices {
+		if e := x.Close(); e != nil {
+			err = e
+		}
+	}
+	return
+}
+
+func (s *segment) register(col *Column, opts *Options) error {
+	prefix := filepath.Join(s.dir, col.Name)
+
+	if col.Index != IndexTypeNone && !opts.NoIndices {
+		idx, err := openIndex(col.Index, prefix+".ci", opts.ReadOnly)
+		if err != nil {
+			return err
+		}
+		s.indices[col.Name] = idx
+	}
+
+	if col.NoData {
+		return nil
+	} else if col.Size > 0 {
+		cc, err := column.OpenFixed(prefix+".cc", col.Size)
+		if err != nil {
+			return err
+		}
+		s.columns[col.Name] = cc
+	} else {
+		cc, err := column.OpenVariable(prefix + ".cc")
+		if err != nil {
+			return err
+		}
+		s.columns[col.Name] = cc
+	}
+	return nil
+}
+
+// int64Slice attaches the methods of sort.Interface to []int64
+type int64Slice []int64
+
+func (p int64Slice) Len() int           { return len(p) }
+func (p int64Slice) Less(i, j int) bool { return p[i] < p[j] }
+func (p int64Slice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
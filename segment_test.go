@@ -0,0 +1,74 @@
+package collie
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("segment", func() {
+	var subject *segment
+	var schema *Schema
+
+	BeforeEach(func() {
+		var err error
+		schema, err = NewSchema([]Column{
+			{Name: "first"},
+			{Name: "age", Size: 1, Index: IndexTypeHash},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		subject, err = createSegment(filepath.Join(testDir, segmentName(7)), 7, schema, new(Options))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should create segments", func() {
+		Expect(subject.base).To(Equal(int64(7)))
+		Expect(subject.created).To(BeTemporally("~", time.Now(), time.Second))
+		Expect(subject.columns).To(HaveLen(2))
+		Expect(subject.indices).To(HaveLen(1))
+		Expect(subject.Len()).To(Equal(int64(0)))
+		Expect(subject.Size()).To(Equal(int64(0)))
+	})
+
+	It("should re-open segments", func() {
+		Expect(subject.columns["first"].Add([]byte("Jane"))).NotTo(HaveOccurred())
+		Expect(subject.columns["first"].Add([]byte("John"))).NotTo(HaveOccurred())
+		Expect(subject.columns["age"].Add([]byte{27})).NotTo(HaveOccurred())
+		created := subject.created
+		Expect(subject.Close()).NotTo(HaveOccurred())
+
+		var err error
+		subject, err = openSegment(filepath.Join(testDir, segmentName(7)), 7, schema, new(Options))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.created.Equal(created)).To(BeTrue())
+		Expect(subject.Len()).To(Equal(int64(1)))
+		Expect(subject.Size()).To(Equal(int64(25)))
+	})
+
+	It("should fail to open segments with unreadable columns", func() {
+		dir := filepath.Join(testDir, segmentName(9))
+		Expect(os.MkdirAll(filepath.Join(dir, "first.cc"), 0755)).NotTo(HaveOccurred())
+
+		_, err := openSegment(dir, 9, schema, new(Options))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should list segments", func() {
+		Expect(os.MkdirAll(filepath.Join(testDir, segmentName(12)), 0755)).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(filepath.Join(testDir, segmentName(0)), 0755)).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(filepath.Join(testDir, "bogus"), 0755)).NotTo(HaveOccurred())
+
+		Expect(listSegments(testDir)).To(Equal([]int64{0, 7, 12}))
+		Expect(hasSegmentFiles(testDir, schema)).To(BeFalse())
+		Expect(hasSegmentFiles(subject.dir, schema)).To(BeTrue())
+	})
+
+})
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/bsm/collie"
+)
+
+// ContentType is the media type of the binary framing. Clients send
+// appended rows in this format by setting the Content-Type header and
+// receive rows, offsets and values in this format by setting the Accept
+// header.
+//
+// All frames are prefixed with their length, as an unsigned varint.
+// Appended rows are sent back to back, each consisting of:
+//
+//	uvarint                number of columns
+//	frame, frame           column name and value, per column
+//	uvarint                number of indices
+//	frame, uvarint, frame  index name, number of values and values, per index
+//
+// Returned rows are sent back to back, each consisting of:
+//
+//	varint                 offset
+//	uvarint                number of columns
+//	frame, frame           column name and value, per column
+//
+// Offsets are returned as consecutive varints, values as raw bytes.
+const ContentType = "application/x-collie"
+
+// maxFrameSize limits the size of decoded frames
+const maxFrameSize = 64 << 20
+
+var errFrameSize = errors.New("collie: frame too large")
+
+// frameWriter writes binary frames
+type frameWriter struct {
+	*bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+func newFrameWriter(w io.Writer) *frameWriter {
+	return &frameWriter{Writer: bufio.NewWriter(w)}
+}
+
+func (w *frameWriter) uvarint(n uint64) {
+	w.Write(w.buf[:binary.PutUvarint(w.buf[:], n)])
+}
+
+func (w *frameWriter) varint(n int64) {
+	w.Write(w.buf[:binary.PutVarint(w.buf[:], n)])
+}
+
+func (w *frameWriter) frame(p []byte) {
+	w.uvarint(uint64(len(p)))
+	w.Write(p)
+}
+
+// row writes a returned row, columns are written in the given order
+func (w *frameWriter) row(offset int64, names []string, row *collie.Row) error {
+	w.varint(offset)
+	w.uvarint(uint64(len(names)))
+	for _, name := range names {
+		val, err := row.ValueAt(name)
+		if err != nil {
+			return err
+		}
+		w.frame([]byte(name))
+		w.frame(val)
+	}
+	return nil
+}
+
+// frameReader reads binary frames
+type frameReader struct {
+	*bufio.Reader
+}
+
+func newFrameReader(r io.Reader) *frameReader {
+	return &frameReader{Reader: bufio.NewReader(r)}
+}
+
+func (r *frameReader) frame() ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, unexpected(err)
+	} else if n > maxFrameSize {
+		return nil, errFrameSize
+	}
+
+	p := make([]byte, int(n))
+	if _, err := io.ReadFull(r, p); err != nil {
+		return nil, unexpected(err)
+	}
+	return p, nil
+}
+
+// row reads an appended row, returns io.EOF when exhausted
+func (r *frameReader) row() (*record, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &record{columns: make(map[string]collie.Value)}
+	for i := uint64(0); i < n; i++ {
+		name, err := r.frame()
+		if err != nil {
+			return nil, err
+		}
+		val, err := r.frame()
+		if err != nil {
+			return nil, err
+		}
+		rec.columns[string(name)] = val
+	}
+
+	if n, err = binary.ReadUvarint(r); err != nil {
+		return nil, unexpected(err)
+	}
+	rec.indices = make(map[string][]collie.Value)
+	for i := uint64(0); i < n; i++ {
+		name, err := r.frame()
+		if err != nil {
+			return nil, err
+		}
+		m, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		for j := uint64(0); j < m; j++ {
+			val, err := r.frame()
+			if err != nil {
+				return nil, err
+			}
+			rec.indices[string(name)] = append(rec.indices[string(name)], val)
+		}
+	}
+	return rec, nil
+}
+
+// unexpected converts io.EOF to io.ErrUnexpectedEOF
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
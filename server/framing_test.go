@@ -0,0 +1,60 @@
+package server
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/bsm/collie"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("framing", func() {
+
+	It("should read rows", func() {
+		buf := new(bytes.Buffer)
+		fw := newFrameWriter(buf)
+		for _, name := range []string{"alice", "bob"} {
+			fw.uvarint(1)
+			fw.frame([]byte("name"))
+			fw.frame([]byte(name))
+			fw.uvarint(1)
+			fw.frame([]byte("tag"))
+			fw.uvarint(1)
+			fw.frame([]byte(name[:1]))
+		}
+		Expect(fw.Flush()).To(Succeed())
+
+		fr := newFrameReader(buf)
+		rec, err := fr.row()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rec.ValueAt("name")).To(Equal(collie.Value("alice")))
+		Expect(rec.IValuesAt("tag")).To(Equal([]collie.Value{collie.Value("a")}))
+
+		rec, err = fr.row()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rec.ValueAt("name")).To(Equal(collie.Value("bob")))
+
+		_, err = fr.row()
+		Expect(err).To(Equal(io.EOF))
+	})
+
+	It("should reject truncated and oversized frames", func() {
+		_, err := newFrameReader(bytes.NewReader([]byte{1, 4, 'n', 'a'})).row()
+		Expect(err).To(Equal(io.ErrUnexpectedEOF))
+		_, err = newFrameReader(bytes.NewReader([]byte{0})).row()
+		Expect(err).To(Equal(io.ErrUnexpectedEOF))
+		_, err = newFrameReader(bytes.NewReader([]byte{1, 0xff, 0xff, 0xff, 0xff, 0x0f})).row()
+		Expect(err).To(Equal(errFrameSize))
+	})
+
+	It("should write rows", func() {
+		row := &collie.Row{}
+		buf := new(bytes.Buffer)
+		fw := newFrameWriter(buf)
+		Expect(fw.row(-1, nil, row)).To(Succeed())
+		Expect(fw.Flush()).To(Succeed())
+		Expect(buf.Bytes()).To(Equal([]byte{1, 0}))
+	})
+
+})
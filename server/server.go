@@ -0,0 +1,539 @@
+// Package server exposes collections over HTTP.
+//
+// Collections are addressed by name, the following endpoints are
+// supported:
+//
+//	GET  /NAME                                  offsets and schema
+//	POST /NAME/rows                             append rows
+//	GET  /NAME/rows?offsets=1,2[&columns=a,b]   fetch rows
+//	GET  /NAME/rows/OFFSET[?columns=a,b]        fetch a row
+//	GET  /NAME/values/COLUMN/OFFSET             fetch a value
+//	GET  /NAME/offsets/INDEX?value=V[&prefix=1] look up index offsets
+//	POST /NAME/query                            run a query
+//
+// Lookups and queries return the lowest matching offsets, no more than
+// Options.MaxRows.
+//
+// Appended rows are committed in transactions of Options.BatchRows rows.
+// When a transaction fails after earlier ones were committed, the error
+// response includes the offset following the committed rows.
+//
+// Requests and responses are JSON encoded by default. Values are
+// represented as strings, encoded as specified by the encoding query
+// parameter: text (default), hex or base64. Trailing zero padding of
+// fixed-size columns is trimmed from text values. See ContentType for
+// the binary alternative.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bsm/collie"
+)
+
+var (
+	errNoCollection = errors.New("collie: no such collection")
+	errNoRoute      = errors.New("collie: no such endpoint")
+	errNoConditions = badRequest("collie: query requires conditions")
+	errTooManyRows  = badRequest("collie: too many rows requested")
+	errBodyTooLarge = errors.New("collie: request body too large")
+)
+
+// Options can be used to tune the server
+type Options struct {
+	// The maximum number of rows committed per transaction when
+	// appending. Default: 1000
+	BatchRows int
+	// The maximum number of rows or offsets returned per request.
+	// Default: 10000
+	MaxRows int
+	// The maximum size of request bodies. Default: 32MiB
+	MaxBodyBytes int64
+}
+
+func (o *Options) norm() *Options {
+	var opts Options
+	if o != nil {
+		opts = *o
+	}
+	if opts.BatchRows < 1 {
+		opts.BatchRows = 1000
+	}
+	if opts.MaxRows < 1 {
+		opts.MaxRows = 10000
+	}
+	if opts.MaxBodyBytes < 1 {
+		opts.MaxBodyBytes = 32 << 20
+	}
+	return &opts
+}
+
+// Info describes a collection
+type Info struct {
+	Offset      int64          `json:"offset"`
+	FirstOffset int64          `json:"first_offset"`
+	Schema      *collie.Schema `json:"schema"`
+}
+
+// Row is the JSON representation of a row. Indices are only used when
+// appending, offsets only when fetching.
+type Row struct {
+	Offset  int64               `json:"offset"`
+	Columns map[string]string   `json:"columns,omitempty"`
+	Indices map[string][]string `json:"indices,omitempty"`
+}
+
+// Query selects rows matching all conditions, in ascending offset order
+type Query struct {
+	Where []Condition `json:"where"`
+	// The data columns to return. Default: all data columns
+	Columns []string `json:"columns,omitempty"`
+	// The maximum number of rows to return. Default: Options.MaxRows
+	Limit int `json:"limit,omitempty"`
+}
+
+// Condition matches rows indexed by a value, or by values starting with
+// a prefix
+type Condition struct {
+	Index  string `json:"index"`
+	Value  string `json:"value"`
+	Prefix bool   `json:"prefix,omitempty"`
+}
+
+// Server serves collections over HTTP
+type Server struct {
+	colls map[string]*collie.Collection
+	opts  *Options
+}
+
+// New creates a new server for colls, by name
+func New(colls map[string]*collie.Collection, opts *Options) *Server {
+	return &Server{colls: colls, opts: opts.norm()}
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	coll, ok := s.colls[parts[0]]
+	if !ok {
+		writeError(w, errNoCollection)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxBodyBytes)
+	req := &request{
+		Server: s,
+		w:      w,
+		r:      r,
+		coll:   coll,
+		binary: strings.Contains(r.Header.Get("Accept"), ContentType),
+	}
+	if req.enc, ok = encodings[req.param("encoding")]; !ok {
+		writeError(w, badRequest("collie: unknown encoding '"+req.param("encoding")+"'"))
+		return
+	}
+
+	var err error
+	switch route := r.Method + " " + strings.Join(parts[1:], "/"); {
+	case len(parts) == 1 && r.Method == "GET":
+		err = req.info()
+	case route == "POST rows":
+		err = req.append()
+	case route == "GET rows":
+		err = req.rows()
+	case route == "POST query":
+		err = req.query()
+	case len(parts) == 3 && route == "GET rows/"+parts[2]:
+		err = req.row(parts[2])
+	case len(parts) == 3 && route == "GET offsets/"+parts[2]:
+		err = req.offsets(parts[2])
+	case len(parts) == 4 && route == "GET values/"+parts[2]+"/"+parts[3]:
+		err = req.value(parts[2], parts[3])
+	default:
+		err = errNoRoute
+	}
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+var encodings = map[string]collie.Encoding{
+	"":       collie.EncodingText,
+	"text":   collie.EncodingText,
+	"hex":    collie.EncodingHex,
+	"base64": collie.EncodingBase64,
+}
+
+// request holds the state of a single request
+type request struct {
+	*Server
+	w      http.ResponseWriter
+	r      *http.Request
+	coll   *collie.Collection
+	enc    collie.Encoding
+	binary bool
+}
+
+// param returns a query parameter
+func (q *request) param(name string) string { return q.r.URL.Query().Get(name) }
+
+func (q *request) info() error {
+	return writeJSON(q.w, &Info{
+		Offset:      q.coll.Offset(),
+		FirstOffset: q.coll.FirstOffset(),
+		Schema:      q.coll.Schema(),
+	})
+}
+
+func (q *request) append() error {
+	recs, err := q.records()
+	if err != nil {
+		return err
+	}
+
+	offset, committed := q.coll.Offset(), false
+	for len(recs) != 0 {
+		n := q.opts.BatchRows
+		if n > len(recs) {
+			n = len(recs)
+		}
+
+		txn := q.coll.Begin(n)
+		for _, rec := range recs[:n] {
+			txn.Add(rec)
+		}
+		next, err := txn.CommitContext(q.r.Context())
+		if err != nil && committed {
+			return &partialAppend{error: err, offset: offset}
+		} else if err != nil {
+			return err
+		}
+		offset, committed = next, true
+		recs = recs[n:]
+	}
+	return writeJSON(q.w, map[string]int64{"offset": offset})
+}
+
+func (q *request) rows() error {
+	var offsets []int64
+	if s := q.param("offsets"); s != "" {
+		for _, part := range strings.Split(s, ",") {
+			offset, err := parseOffset(part)
+			if err != nil {
+				return err
+			}
+			offsets = append(offsets, offset)
+		}
+	}
+	if len(offsets) > q.opts.MaxRows {
+		return errTooManyRows
+	}
+	return q.writeRows(offsets, q.columns())
+}
+
+func (q *request) row(s string) error {
+	offset, err := parseOffset(s)
+	if err != nil {
+		return err
+	}
+	return q.writeRows([]int64{offset}, q.columns())
+}
+
+func (q *request) value(name, s string) error {
+	offset, err := parseOffset(s)
+	if err != nil {
+		return err
+	}
+	val, err := q.coll.ValueContext(q.r.Context(), name, offset)
+	if err != nil {
+		return err
+	}
+
+	if q.binary {
+		q.w.Header().Set("Content-Type", ContentType)
+		_, err = q.w.Write(val)
+		return err
+	}
+	return writeJSON(q.w, map[string]string{"value": q.encode(name, val)})
+}
+
+func (q *request) offsets(name string) error {
+	l, err := q.lookup(Condition{Index: name, Value: q.param("value"), Prefix: q.param("prefix") != ""})
+	if err != nil {
+		return err
+	}
+	offsets, err := q.coll.MatchContext(q.r.Context(), []collie.Lookup{l}, q.opts.MaxRows)
+	if err != nil {
+		return err
+	}
+
+	if q.binary {
+		q.w.Header().Set("Content-Type", ContentType)
+		fw := newFrameWriter(q.w)
+		for _, offset := range offsets {
+			fw.varint(offset)
+		}
+		return fw.Flush()
+	}
+	if offsets == nil {
+		offsets = []int64{}
+	}
+	return writeJSON(q.w, map[string][]int64{"offsets": offsets})
+}
+
+func (q *request) query() error {
+	var query Query
+	if err := json.NewDecoder(q.r.Body).Decode(&query); err != nil {
+		return bodyError(err)
+	} else if len(query.Where) == 0 {
+		return errNoConditions
+	}
+
+	lookups := make([]collie.Lookup, 0, len(query.Where))
+	for _, cond := range query.Where {
+		l, err := q.lookup(cond)
+		if err != nil {
+			return err
+		}
+		lookups = append(lookups, l)
+	}
+
+	limit := q.opts.MaxRows
+	if query.Limit > 0 && query.Limit < limit {
+		limit = query.Limit
+	}
+	offsets, err := q.coll.MatchContext(q.r.Context(), lookups, limit)
+	if err != nil {
+		return err
+	}
+
+	names := query.Columns
+	if len(names) == 0 {
+		names = q.dataColumns()
+	}
+	return q.writeRows(offsets, names)
+}
+
+// lookup decodes a condition
+func (q *request) lookup(cond Condition) (collie.Lookup, error) {
+	val, err := q.enc.Decode(cond.Value)
+	if err != nil {
+		return collie.Lookup{}, badRequest(err.Error())
+	}
+	return collie.Lookup{Index: cond.Index, Value: val, Prefix: cond.Prefix}, nil
+}
+
+// records reads the appended rows from the request body
+func (q *request) records() ([]collie.Record, error) {
+	cols := make(map[string]collie.Column)
+	for _, col := range q.coll.Schema().Columns() {
+		cols[col.Name] = col
+	}
+	validate := func(rec *record) error {
+		for name := range rec.columns {
+			if col, ok := cols[name]; !ok || col.NoData {
+				return badRequest("collie: unknown column '" + name + "'")
+			}
+		}
+		for name := range rec.indices {
+			if col, ok := cols[name]; !ok || col.Index == collie.IndexTypeNone {
+				return badRequest("collie: unknown index '" + name + "'")
+			}
+		}
+		return nil
+	}
+
+	var recs []collie.Record
+	if strings.HasPrefix(q.r.Header.Get("Content-Type"), ContentType) {
+		fr := newFrameReader(q.r.Body)
+		for {
+			rec, err := fr.row()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, bodyError(err)
+			} else if err := validate(rec); err != nil {
+				return nil, err
+			}
+			recs = append(recs, rec)
+		}
+		return recs, nil
+	}
+
+	var body struct {
+		Rows []Row `json:"rows"`
+	}
+	if err := json.NewDecoder(q.r.Body).Decode(&body); err != nil {
+		return nil, bodyError(err)
+	}
+	for _, row := range body.Rows {
+		rec, err := q.decode(&row)
+		if err != nil {
+			return nil, err
+		} else if err := validate(rec); err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// decode converts a JSON row into a record
+func (q *request) decode(row *Row) (*record, error) {
+	rec := &record{
+		columns: make(map[string]collie.Value, len(row.Columns)),
+		indices: make(map[string][]collie.Value, len(row.Indices)),
+	}
+	for name, s := range row.Columns {
+		val, err := q.enc.Decode(s)
+		if err != nil {
+			return nil, badRequest(err.Error())
+		}
+		rec.columns[name] = val
+	}
+	for name, strs := range row.Indices {
+		for _, s := range strs {
+			val, err := q.enc.Decode(s)
+			if err != nil {
+				return nil, badRequest(err.Error())
+			}
+			rec.indices[name] = append(rec.indices[name], val)
+		}
+	}
+	return rec, nil
+}
+
+// writeRows fetches and writes the named columns of the rows at offsets
+func (q *request) writeRows(offsets []int64, names []string) error {
+	rows, err := q.coll.RowsContext(q.r.Context(), offsets, names...)
+	if err != nil {
+		return err
+	}
+
+	if q.binary {
+		q.w.Header().Set("Content-Type", ContentType)
+		fw := newFrameWriter(q.w)
+		for i, row := range rows {
+			if err := fw.row(offsets[i], names, row); err != nil {
+				return err
+			}
+		}
+		return fw.Flush()
+	}
+
+	res := make([]Row, len(rows))
+	for i, row := range rows {
+		res[i] = Row{Offset: offsets[i], Columns: make(map[string]string, len(names))}
+		for _, name := range names {
+			val, err := row.ValueAt(name)
+			if err != nil {
+				return err
+			}
+			res[i].Columns[name] = q.encode(name, val)
+		}
+	}
+	return writeJSON(q.w, map[string][]Row{"rows": res})
+}
+
+// columns returns the requested data columns
+func (q *request) columns() []string {
+	if s := q.param("columns"); s != "" {
+		return strings.Split(s, ",")
+	}
+	return q.dataColumns()
+}
+
+// dataColumns returns all data columns, in schema order
+func (q *request) dataColumns() []string {
+	var names []string
+	for _, col := range q.coll.Schema().Columns() {
+		if !col.NoData {
+			names = append(names, col.Name)
+		}
+	}
+	return names
+}
+
+// encode encodes a value of the named column
+func (q *request) encode(name string, val collie.Value) string {
+	if q.enc == collie.EncodingText {
+		for _, col := range q.coll.Schema().Columns() {
+			if col.Name == name && col.Size > 0 {
+				val = collie.Value(strings.TrimRight(string(val), "\x00"))
+			}
+		}
+	}
+	return q.enc.Encode(val)
+}
+
+// record is a collie.Record, decoded from a request
+type record struct {
+	columns map[string]collie.Value
+	indices map[string][]collie.Value
+}
+
+func (r *record) ValueAt(name string) (collie.Value, error)     { return r.columns[name], nil }
+func (r *record) IValuesAt(name string) ([]collie.Value, error) { return r.indices[name], nil }
+
+// badRequest errors are caused by invalid requests
+type badRequest string
+
+func (e badRequest) Error() string { return string(e) }
+
+func parseOffset(s string) (int64, error) {
+	offset, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, badRequest("collie: invalid offset '" + s + "'")
+	}
+	return offset, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	res := map[string]interface{}{"error": err.Error()}
+	if pa, ok := err.(*partialAppend); ok {
+		res["offset"] = pa.offset
+		err = pa.error
+	}
+
+	code := http.StatusInternalServerError
+	if _, ok := err.(badRequest); ok {
+		code = http.StatusBadRequest
+	}
+	switch err {
+	case errNoCollection, errNoRoute, collie.ErrNotFound, collie.ErrColumnNotFound:
+		code = http.StatusNotFound
+	case collie.ErrReadOnly:
+		code = http.StatusForbidden
+	case errBodyTooLarge:
+		code = http.StatusRequestEntityTooLarge
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(res)
+}
+
+// partialAppend is returned when appends fail after committing some rows
+type partialAppend struct {
+	error
+	offset int64
+}
+
+// bodyError converts errors reading request bodies
+func bodyError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return errBodyTooLarge
+	}
+	return badRequest(err.Error())
+}
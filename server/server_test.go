@@ -0,0 +1,275 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bsm/collie"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server", func() {
+	var coll *collie.Collection
+	var subject *httptest.Server
+
+	do := func(method, path, ctype, accept string, body []byte) (int, []byte) {
+		req, err := http.NewRequest(method, subject.URL+path, bytes.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		if ctype != "" {
+			req.Header.Set("Content-Type", ctype)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer res.Body.Close()
+
+		data, err := ioutil.ReadAll(res.Body)
+		Expect(err).NotTo(HaveOccurred())
+		return res.StatusCode, data
+	}
+
+	get := func(path string) (int, string) {
+		code, data := do("GET", path, "", "", nil)
+		return code, strings.TrimSpace(string(data))
+	}
+
+	post := func(path, body string) (int, string) {
+		code, data := do("POST", path, "application/json", "", []byte(body))
+		return code, strings.TrimSpace(string(data))
+	}
+
+	BeforeEach(func() {
+		schema := collie.CreateSchema([]collie.Column{
+			{Name: "name"},
+			{Name: "code", Size: 4, Index: collie.IndexTypeHash},
+			{Name: "tag", Index: collie.IndexTypeHash, NoData: true},
+		})
+
+		var err error
+		coll, err = collie.OpenCollection(testDir, schema)
+		Expect(err).NotTo(HaveOccurred())
+		subject = httptest.NewServer(New(map[string]*collie.Collection{"people": coll}, &Options{BatchRows: 2, MaxRows: 3}))
+
+		code, body := post("/people/rows", `{"rows":[
+			{"columns":{"name":"alice","code":"ab"},"indices":{"code":["ab"],"tag":["a","x"]}},
+			{"columns":{"name":"bob","code":"cd"},"indices":{"code":["cd"],"tag":["b","x"]}},
+			{"columns":{"name":"anna","code":"ab"},"indices":{"code":["ab"],"tag":["a"]}}
+		]}`)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(`{"offset":3}`))
+	})
+
+	AfterEach(func() {
+		subject.Close()
+		coll.Close()
+	})
+
+	It("should describe collections", func() {
+		code, body := get("/people")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(HavePrefix(`{"offset":3,"first_offset":0,"schema":{"columns":[{"name":"name"},`))
+
+		code, body = get("/missing")
+		Expect(code).To(Equal(http.StatusNotFound))
+		Expect(body).To(Equal(`{"error":"collie: no such collection"}`))
+
+		code, _ = get("/people/bogus")
+		Expect(code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should append rows", func() {
+		Expect(coll.Offset()).To(Equal(int64(3)))
+		stats, err := coll.Stats(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.Commits).To(Equal(int64(2)))
+
+		code, body := post("/people/rows?encoding=hex", `{"rows":[{"columns":{"name":"6361726f6c"}}]}`)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(`{"offset":4}`))
+		Expect(coll.Value("name", 3)).To(Equal([]byte("carol")))
+
+		code, body = post("/people/rows", `{"rows":[{"columns":{"tag":"x"}}]}`)
+		Expect(code).To(Equal(http.StatusBadRequest))
+		Expect(body).To(Equal(`{"error":"collie: unknown column 'tag'"}`))
+
+		code, body = post("/people/rows", `{"rows":[{"indices":{"name":["x"]}}]}`)
+		Expect(code).To(Equal(http.StatusBadRequest))
+		Expect(body).To(Equal(`{"error":"collie: unknown index 'name'"}`))
+
+		code, _ = post("/people/rows?encoding=hex", `{"rows":[{"columns":{"name":"zz"}}]}`)
+		Expect(code).To(Equal(http.StatusBadRequest))
+		code, _ = post("/people/rows", `{"rows":`)
+		Expect(code).To(Equal(http.StatusBadRequest))
+		Expect(coll.Offset()).To(Equal(int64(4)))
+	})
+
+	It("should limit request bodies", func() {
+		small := httptest.NewServer(New(map[string]*collie.Collection{"people": coll}, &Options{MaxBodyBytes: 32}))
+		defer small.Close()
+
+		req, err := http.NewRequest("POST", small.URL+"/people/rows", strings.NewReader(`{"rows":[{"columns":{"name":"carol"}},{"columns":{"name":"dave"}}]}`))
+		Expect(err).NotTo(HaveOccurred())
+		res, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer res.Body.Close()
+
+		data, err := ioutil.ReadAll(res.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+		Expect(strings.TrimSpace(string(data))).To(Equal(`{"error":"collie: request body too large"}`))
+		Expect(coll.Offset()).To(Equal(int64(3)))
+	})
+
+	It("should report offsets of partial appends", func() {
+		w := httptest.NewRecorder()
+		writeError(w, &partialAppend{error: collie.ErrReadOnly, offset: 5})
+		Expect(w.Code).To(Equal(http.StatusForbidden))
+		Expect(strings.TrimSpace(w.Body.String())).To(Equal(`{"error":"collie: collection is read-only","offset":5}`))
+	})
+
+	It("should fetch rows and values", func() {
+		code, body := get("/people/rows/1")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(`{"rows":[{"offset":1,"columns":{"code":"cd","name":"bob"}}]}`))
+
+		code, body = get("/people/rows?offsets=2,0&columns=name")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(`{"rows":[{"offset":2,"columns":{"name":"anna"}},{"offset":0,"columns":{"name":"alice"}}]}`))
+
+		code, body = get("/people/values/code/0?encoding=hex")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(`{"value":"61620000"}`))
+
+		code, _ = get("/people/rows/3")
+		Expect(code).To(Equal(http.StatusNotFound))
+		code, _ = get("/people/rows/x")
+		Expect(code).To(Equal(http.StatusBadRequest))
+		code, _ = get("/people/rows?offsets=0,1,2,0")
+		Expect(code).To(Equal(http.StatusBadRequest))
+		code, _ = get("/people/values/tag/0")
+		Expect(code).To(Equal(http.StatusNotFound))
+		code, _ = get("/people/values/name/0?encoding=rot13")
+		Expect(code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should look up offsets", func() {
+		code, body := get("/people/offsets/tag?value=x")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(`{"offsets":[0,1]}`))
+
+		code, body = get("/people/offsets/code?value=a&prefix=1")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(`{"offsets":[0,2]}`))
+
+		code, body = get("/people/offsets/tag?value=z")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(`{"offsets":[]}`))
+
+		code, _ = get("/people/offsets/name?value=bob")
+		Expect(code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should limit looked up offsets", func() {
+		code, _ := post("/people/rows", `{"rows":[{"columns":{"name":"amber","code":"ab"},"indices":{"code":["ab"]}}]}`)
+		Expect(code).To(Equal(http.StatusOK))
+
+		code, body := get("/people/offsets/code?value=&prefix=1")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(`{"offsets":[0,1,2]}`))
+	})
+
+	It("should run queries", func() {
+		code, body := post("/people/query", `{"where":[{"index":"tag","value":"a"},{"index":"tag","value":"x"}]}`)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(`{"rows":[{"offset":0,"columns":{"code":"ab","name":"alice"}}]}`))
+
+		code, body = post("/people/query", `{"where":[{"index":"code","value":"","prefix":true}],"columns":["name"],"limit":2}`)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(`{"rows":[{"offset":0,"columns":{"name":"alice"}},{"offset":1,"columns":{"name":"bob"}}]}`))
+
+		code, body = post("/people/query", `{"where":[]}`)
+		Expect(code).To(Equal(http.StatusBadRequest))
+		Expect(body).To(Equal(`{"error":"collie: query requires conditions"}`))
+	})
+
+	It("should support binary framing", func() {
+		buf := new(bytes.Buffer)
+		fw := newFrameWriter(buf)
+		fw.uvarint(1)
+		fw.frame([]byte("name"))
+		fw.frame([]byte("dave"))
+		fw.uvarint(1)
+		fw.frame([]byte("tag"))
+		fw.uvarint(2)
+		fw.frame([]byte("d"))
+		fw.frame([]byte("x"))
+		Expect(fw.Flush()).To(Succeed())
+
+		code, data := do("POST", "/people/rows", ContentType, "", buf.Bytes())
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(string(data)).To(Equal("{\"offset\":4}\n"))
+		Expect(coll.Offsets("tag", []byte("x"))).To(Equal([]int64{0, 1, 3}))
+
+		code, data = do("POST", "/people/rows", ContentType, "", []byte{1, 4, 'n'})
+		Expect(code).To(Equal(http.StatusBadRequest))
+		Expect(string(data)).To(ContainSubstring("unexpected EOF"))
+
+		code, data = do("GET", "/people/offsets/tag?value=x", "", ContentType, nil)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(data).To(Equal([]byte{0, 2, 6}))
+
+		code, data = do("GET", "/people/values/name/3", "", ContentType, nil)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(string(data)).To(Equal("dave"))
+
+		code, data = do("GET", "/people/rows/3?columns=name", "", ContentType, nil)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(data).To(Equal([]byte{6, 1, 4, 'n', 'a', 'm', 'e', 4, 'd', 'a', 'v', 'e'}))
+	})
+
+	It("should reject writes to read-only collections", func() {
+		Expect(coll.Close()).To(Succeed())
+
+		var err error
+		coll, err = collie.OpenCollectionWithOptions(testDir, coll.Schema(), &collie.Options{ReadOnly: true})
+		Expect(err).NotTo(HaveOccurred())
+
+		srv := httptest.NewServer(New(map[string]*collie.Collection{"people": coll}, nil))
+		defer srv.Close()
+
+		res, err := http.Post(srv.URL+"/people/rows", "application/json", strings.NewReader(`{"rows":[{"columns":{"name":"x"}}]}`))
+		Expect(err).NotTo(HaveOccurred())
+		defer res.Body.Close()
+		Expect(res.StatusCode).To(Equal(http.StatusForbidden))
+
+		var body map[string]string
+		Expect(json.NewDecoder(res.Body).Decode(&body)).To(Succeed())
+		Expect(body).To(Equal(map[string]string{"error": collie.ErrReadOnly.Error()}))
+	})
+
+})
+
+var testDir string
+
+func TestSuite(t *testing.T) {
+	BeforeEach(func() {
+		var err error
+		testDir, err = ioutil.TempDir("", "collie.server.test")
+		Expect(err).NotTo(HaveOccurred())
+	})
+	AfterEach(func() {
+		os.RemoveAll(testDir)
+	})
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "collie/server")
+}
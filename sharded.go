@@ -0,0 +1,256 @@
+package collie
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"sync/atomic"
+)
+
+// ShardOffset addresses a row within a sharded collection
+type ShardOffset struct {
+	Shard  int
+	Offset int64
+}
+
+// A Partitioner returns the shard a record should be stored in,
+// given the total number of shards
+type Partitioner func(rec Record, shards int) (int, error)
+
+// HashPartitioner partitions records by the hash of a column value
+func HashPartitioner(name string) Partitioner {
+	return func(rec Record, shards int) (int, error) {
+		val, err := rec.ValueAt(name)
+		if err != nil {
+			return 0, err
+		}
+
+		hash := fnv.New32a()
+		_, _ = hash.Write(val)
+		return int(hash.Sum32() % uint32(shards)), nil
+	}
+}
+
+// RoundRobinPartitioner distributes records evenly across shards
+func RoundRobinPartitioner() Partitioner {
+	var n uint64
+	return func(_ Record, shards int) (int, error) {
+		return int((atomic.AddUint64(&n, 1) - 1) % uint64(shards)), nil
+	}
+}
+
+// ShardedCollection partitions rows across multiple collections
+type ShardedCollection struct {
+	shards []*Collection
+	part   Partitioner
+}
+
+// OpenShardedCollection opens a collection for given schema, sharded
+// across multiple directories
+func OpenShardedCollection(dirs []string, schema *Schema, part Partitioner, opts *Options) (*ShardedCollection, error) {
+	if len(dirs) == 0 {
+		return nil, errors.New("collie: no shards")
+	}
+
+	s := &ShardedCollection{shards: make([]*Collection, 0, len(dirs)), part: part}
+	for _, dir := range dirs {
+		coll, err := OpenCollectionWithOptions(dir, schema, opts)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.shards = append(s.shards, coll)
+	}
+	return s, nil
+}
+
+// NumShards returns the number of shards
+func (s *ShardedCollection) NumShards() int { return len(s.shards) }
+
+// Shard returns the collection of the n-th shard
+func (s *ShardedCollection) Shard(n int) *Collection { return s.shards[n] }
+
+// Begin starts a new transaction. The rows argument defines
+// the capacity of the transactional cache and should hint
+// at the number of rows about to be added.
+func (s *ShardedCollection) Begin(rows int) *ShardedTxn {
+	return &ShardedTxn{s: s, stash: make([]Record, 0, rows)}
+}
+
+// Close closes all shards
+func (s *ShardedCollection) Close() (err error) {
+	for _, coll := range s.shards {
+		if e := coll.Close(); e != nil {
+			err = e
+		}
+	}
+	return
+}
+
+// Value returns a column value at a given offset
+func (s *ShardedCollection) Value(name string, off ShardOffset) ([]byte, error) {
+	if off.Shard < 0 || off.Shard >= len(s.shards) {
+		return nil, ErrNotFound
+	}
+	return s.shards[off.Shard].Value(name, off.Offset)
+}
+
+// Offsets returns the offsets for a given index/value pair across all
+// shards, ordered by shard and offset
+func (s *ShardedCollection) Offsets(name string, value []byte) ([]ShardOffset, error) {
+	return s.fanOut(func(coll *Collection) ([]int64, error) {
+		return coll.Offsets(name, value)
+	})
+}
+
+// OffsetsPrefix returns the offsets of all rows indexed by a value
+// starting with prefix across all shards, ordered by shard and offset
+func (s *ShardedCollection) OffsetsPrefix(name string, prefix []byte) ([]ShardOffset, error) {
+	return s.fanOut(func(coll *Collection) ([]int64, error) {
+		return coll.OffsetsPrefix(name, prefix)
+	})
+}
+
+// fanOut runs a lookup on all shards concurrently
+func (s *ShardedCollection) fanOut(lookup func(*Collection) ([]int64, error)) ([]ShardOffset, error) {
+	results := make([][]int64, len(s.shards))
+	tasks := make([]func() error, len(s.shards))
+	for n, coll := range s.shards {
+		n, coll := n, coll
+		tasks[n] = func() (err error) {
+			results[n], err = lookup(coll)
+			return
+		}
+	}
+	for _, err := range parallel(len(tasks), tasks) {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var res []ShardOffset
+	for n, offs := range results {
+		for _, off := range offs {
+			res = append(res, ShardOffset{Shard: n, Offset: off})
+		}
+	}
+	return res, nil
+}
+
+// A sharded collection transaction. Transactions are not thread-safe
+// and must not be used across multiple goroutines.
+type ShardedTxn struct {
+	s     *ShardedCollection
+	stash []Record
+}
+
+// New initializes an empty row and stashes it for the next commit
+func (t *ShardedTxn) New() *Row {
+	row := newRow(0, 0)
+	if head := t.s.shards[0].head(); head != nil {
+		row = newRow(len(head.columns), len(head.indices))
+	}
+	t.stash = append(t.stash, row)
+	return row
+}
+
+// Add stashes a record for the next commit
+func (t *ShardedTxn) Add(rec Record) {
+	t.stash = append(t.stash, rec)
+}
+
+// Discard reset the stash
+func (t *ShardedTxn) Discard() {
+	t.stash = t.stash[:0]
+}
+
+// Commit partitions the stashed records and commits them to all
+// affected shards concurrently. Records are only published once all
+// shards have been written and their offsets committed successfully,
+// otherwise all shards are rolled back. Returns the addresses of the
+// committed records, in the order they were added.
+func (t *ShardedTxn) Commit() ([]ShardOffset, error) {
+	return t.CommitContext(context.Background())
+}
+
+// CommitContext behaves like Commit, but aborts and rolls back all shards
+// when ctx is done before the records are published.
+func (t *ShardedTxn) CommitContext(ctx context.Context) ([]ShardOffset, error) {
+	n := len(t.s.shards)
+	parts := make([][]Record, n)
+	addrs := make([]ShardOffset, len(t.stash))
+	for i, rec := range t.stash {
+		shard, err := t.s.part(rec, n)
+		if err != nil {
+			return nil, err
+		} else if shard < 0 || shard >= n {
+			return nil, errors.New("collie: invalid shard " + strconv.Itoa(shard))
+		}
+		addrs[i] = ShardOffset{Shard: shard, Offset: int64(len(parts[shard]))}
+		parts[shard] = append(parts[shard], rec)
+	}
+
+	// Lock affected shards in order, to avoid deadlocks
+	shards := make([]int, 0, n)
+	for shard, recs := range parts {
+		if len(recs) != 0 {
+			shards = append(shards, shard)
+		}
+	}
+	for _, shard := range shards {
+		coll := t.s.shards[shard]
+		coll.wmux.Lock()
+		defer coll.wmux.Unlock()
+	}
+
+	writes := make([]*pendingWrite, n)
+	tasks := make([]func() error, 0, len(shards))
+	for _, shard := range shards {
+		shard, txn := shard, &Txn{c: t.s.shards[shard], stash: parts[shard]}
+		tasks = append(tasks, func() (err error) {
+			writes[shard], err = txn.write(ctx)
+			return
+		})
+	}
+	errs := append(parallel(len(tasks), tasks), canceled(ctx))
+	for _, err := range errs {
+		if err != nil {
+			for _, w := range writes {
+				if w != nil {
+					w.rollback()
+				}
+			}
+			return nil, err
+		}
+	}
+
+	// Commit all offsets before any records become visible
+	for i, w := range writes {
+		if w == nil {
+			continue
+		}
+		if err := w.commit(); err != nil {
+			for _, w := range writes[:i+1] {
+				if w != nil {
+					w.revert()
+				}
+			}
+			for _, w := range writes[i+1:] {
+				if w != nil {
+					w.rollback()
+				}
+			}
+			return nil, err
+		}
+	}
+	for _, w := range writes {
+		if w != nil {
+			w.release()
+		}
+	}
+	for i, addr := range addrs {
+		addrs[i].Offset += writes[addr.Shard].offset
+	}
+	return addrs, nil
+}
@@ -0,0 +1,195 @@
+package collie
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ShardedCollection", func() {
+	var subject *ShardedCollection
+	var schema *Schema
+	var dirs []string
+
+	BeforeEach(func() {
+		schema = CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "tag", Index: IndexTypeHash},
+		})
+		dirs = []string{
+			filepath.Join(testDir, "a"),
+			filepath.Join(testDir, "b"),
+			filepath.Join(testDir, "c"),
+		}
+
+		var err error
+		subject, err = OpenShardedCollection(dirs, schema, HashPartitioner("name"), nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	commit := func(names ...string) []ShardOffset {
+		txn := subject.Begin(len(names))
+		for _, name := range names {
+			txn.Add(testRecord{"name": Value(name), "tag": Value(name[:1])})
+		}
+		addrs, err := txn.Commit()
+		Expect(err).NotTo(HaveOccurred())
+		return addrs
+	}
+
+	It("should open shards", func() {
+		Expect(subject.NumShards()).To(Equal(3))
+		Expect(subject.Shard(1).dir).To(Equal(dirs[1]))
+
+		_, err := OpenShardedCollection(nil, schema, HashPartitioner("name"), nil)
+		Expect(err).To(MatchError("collie: no shards"))
+	})
+
+	It("should partition records by hash", func() {
+		addrs := commit("alice", "bob", "carol", "dave", "alice")
+		Expect(addrs).To(HaveLen(5))
+		Expect(addrs[4].Shard).To(Equal(addrs[0].Shard))
+		Expect(addrs[4].Offset).To(BeNumerically(">", addrs[0].Offset))
+
+		total := int64(0)
+		for n := 0; n < subject.NumShards(); n++ {
+			total += subject.Shard(n).Offset()
+		}
+		Expect(total).To(Equal(int64(5)))
+
+		for i, name := range []string{"alice", "bob", "carol", "dave", "alice"} {
+			val, err := subject.Value("name", addrs[i])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(val)).To(Equal(name))
+		}
+
+		_, err := subject.Value("name", ShardOffset{Shard: 3})
+		Expect(err).To(Equal(ErrNotFound))
+	})
+
+	It("should partition records round-robin", func() {
+		Expect(subject.Close()).To(Succeed())
+
+		var err error
+		subject, err = OpenShardedCollection(dirs, schema, RoundRobinPartitioner(), nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(commit("a", "b", "c", "d")).To(Equal([]ShardOffset{
+			{Shard: 0, Offset: 0},
+			{Shard: 1, Offset: 0},
+			{Shard: 2, Offset: 0},
+			{Shard: 0, Offset: 1},
+		}))
+		Expect(commit("e")).To(Equal([]ShardOffset{
+			{Shard: 1, Offset: 1},
+		}))
+	})
+
+	It("should look up offsets across shards", func() {
+		addrs := commit("alice", "bob", "anne", "arthur", "ben")
+
+		offs, err := subject.Offsets("tag", Value("a"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offs).To(ConsistOf(addrs[0], addrs[2], addrs[3]))
+
+		offs, err = subject.OffsetsPrefix("name", Value("b"))
+		Expect(err).To(Equal(ErrColumnNotFound))
+
+		offs, err = subject.OffsetsPrefix("tag", Value("b"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offs).To(ConsistOf(addrs[1], addrs[4]))
+	})
+
+	It("should rollback all shards on failures", func() {
+		commit("alice", "bob")
+		subject.part = RoundRobinPartitioner()
+
+		txn := subject.Begin(3)
+		txn.Add(testRecord{"name": Value("carol"), "tag": Value("c")})
+		txn.Add(testRecord{"name": Value("dave"), "tag": Value("d")})
+		txn.Add(testRecordBadCol{})
+		_, err := txn.Commit()
+		Expect(err).To(Equal(io.EOF))
+
+		total := int64(0)
+		for n := 0; n < subject.NumShards(); n++ {
+			total += subject.Shard(n).Offset()
+		}
+		Expect(total).To(Equal(int64(2)))
+
+		offs, err := subject.Offsets("tag", Value("c"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offs).To(BeEmpty())
+	})
+
+	It("should rollback all shards when offsets cannot be committed", func() {
+		commit("alice", "bob")
+		subject.part = RoundRobinPartitioner()
+
+		before := make([]int64, subject.NumShards())
+		for n := range before {
+			before[n] = subject.Shard(n).Offset()
+		}
+		Expect(subject.Shard(1).ofile.Close()).To(Succeed())
+
+		txn := subject.Begin(2)
+		txn.Add(testRecord{"name": Value("carol"), "tag": Value("c")})
+		txn.Add(testRecord{"name": Value("dave"), "tag": Value("d")})
+		_, err := txn.Commit()
+		Expect(err).To(HaveOccurred())
+
+		for n := range before {
+			Expect(subject.Shard(n).Offset()).To(Equal(before[n]))
+			Expect(readOffsetFile(dirs[n])).To(Equal(before[n]))
+		}
+		offs, err := subject.Offsets("tag", Value("c"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offs).To(BeEmpty())
+	})
+
+	It("should rollback all shards when cancelled", func() {
+		commit("alice", "bob")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		shard := subject.Shard(0)
+		shard.head().indices["tag"] = testIndexCancelWrite{shard.head().indices["tag"], cancel}
+		subject.part = RoundRobinPartitioner()
+
+		txn := subject.Begin(2)
+		txn.Add(testRecord{"name": Value("carol"), "tag": Value("c")})
+		txn.Add(testRecord{"name": Value("dave"), "tag": Value("d")})
+		_, err := txn.CommitContext(ctx)
+		Expect(err).To(Equal(context.Canceled))
+
+		total := int64(0)
+		for n := 0; n < subject.NumShards(); n++ {
+			total += subject.Shard(n).Offset()
+		}
+		Expect(total).To(Equal(int64(2)))
+	})
+
+	It("should reject invalid shards", func() {
+		Expect(subject.Close()).To(Succeed())
+
+		var err error
+		subject, err = OpenShardedCollection(dirs, schema, func(Record, int) (int, error) { return 3, nil }, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		txn := subject.Begin(1)
+		txn.Add(testRecord{"name": Value("x")})
+		_, err = txn.Commit()
+		Expect(err).To(MatchError("collie: invalid shard 3"))
+
+		subject.part = func(Record, int) (int, error) { return 0, io.EOF }
+		_, err = txn.Commit()
+		Expect(err).To(Equal(io.EOF))
+	})
+
+})
@@ -0,0 +1,142 @@
+package collie
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats holds collection statistics
+type Stats struct {
+	// The current offset and the offset of the first row
+	Offset      int64
+	FirstOffset int64
+	// The number of stored rows
+	Rows int64
+	// The number of segments
+	Segments int
+	// Data columns and indices, in schema order
+	Columns []ColumnStats
+	Indices []IndexStats
+
+	// The number of commits, committed rows and rolled back commits
+	Commits       int64
+	CommittedRows int64
+	Rollbacks     int64
+	// The total time spent writing commits
+	CommitTime time.Duration
+
+	// The number of values read and the total time spent reading them
+	Reads    int64
+	ReadTime time.Duration
+
+	// The number of index lookups and the total time spent on them
+	Lookups    int64
+	LookupTime time.Duration
+}
+
+// ColumnStats holds statistics of a data column
+type ColumnStats struct {
+	Name string
+	// The number of bytes stored, across all segments
+	Size int64
+}
+
+// IndexStats holds statistics of an index
+type IndexStats struct {
+	Name string
+	// The number of distinct values, across all segments,
+	// -1 unless counted
+	Keys int64
+}
+
+// counters hold the activity counters of a collection
+type counters struct {
+	commits, commitRows, commitTime int64
+	rollbacks                       int64
+	reads, readTime                 int64
+	lookups, lookupTime             int64
+}
+
+func (c *counters) commit(rows int64, start time.Time) {
+	atomic.AddInt64(&c.commits, 1)
+	atomic.AddInt64(&c.commitRows, rows)
+	atomic.AddInt64(&c.commitTime, int64(time.Since(start)))
+}
+
+func (c *counters) rollback() { atomic.AddInt64(&c.rollbacks, 1) }
+
+func (c *counters) read(n int64, start time.Time) {
+	atomic.AddInt64(&c.reads, n)
+	atomic.AddInt64(&c.readTime, int64(time.Since(start)))
+}
+
+func (c *counters) lookup(start time.Time) {
+	atomic.AddInt64(&c.lookups, 1)
+	atomic.AddInt64(&c.lookupTime, int64(time.Since(start)))
+}
+
+// Stats returns collection statistics. Counting index keys requires a
+// scan of all indices, set keys to false to skip.
+func (c *Collection) Stats(keys bool) (*Stats, error) {
+	c.smux.RLock()
+	defer c.smux.RUnlock()
+
+	if len(c.segs) == 0 {
+		return nil, ErrClosed
+	}
+
+	stats := &Stats{
+		Offset:      c.Offset(),
+		FirstOffset: c.segs[0].base,
+		Segments:    len(c.segs),
+
+		Commits:       atomic.LoadInt64(&c.counters.commits),
+		CommittedRows: atomic.LoadInt64(&c.counters.commitRows),
+		Rollbacks:     atomic.LoadInt64(&c.counters.rollbacks),
+		CommitTime:    time.Duration(atomic.LoadInt64(&c.counters.commitTime)),
+		Reads:         atomic.LoadInt64(&c.counters.reads),
+		ReadTime:      time.Duration(atomic.LoadInt64(&c.counters.readTime)),
+		Lookups:       atomic.LoadInt64(&c.counters.lookups),
+		LookupTime:    time.Duration(atomic.LoadInt64(&c.counters.lookupTime)),
+	}
+	stats.Rows = stats.Offset - stats.FirstOffset
+
+	for _, col := range c.schema.Columns() {
+		if _, ok := c.segs[0].columns[col.Name]; ok {
+			cs := ColumnStats{Name: col.Name}
+			for _, seg := range c.segs {
+				cs.Size += seg.columns[col.Name].Size()
+			}
+			stats.Columns = append(stats.Columns, cs)
+		}
+
+		if _, ok := c.segs[0].indices[col.Name]; ok {
+			is := IndexStats{Name: col.Name, Keys: -1}
+			if keys {
+				n, err := c.countKeys(col.Name)
+				if err != nil {
+					return nil, err
+				}
+				is.Keys = n
+			}
+			stats.Indices = append(stats.Indices, is)
+		}
+	}
+	return stats, nil
+}
+
+// countKeys counts the distinct values of an index, must be called
+// while holding a read lock
+func (c *Collection) countKeys(name string) (int64, error) {
+	iter, err := c.iterate(name, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer iter.Release()
+
+	var n int64
+	for iter.Next() {
+		n++
+	}
+	return n, iter.Error()
+}
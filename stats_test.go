@@ -0,0 +1,102 @@
+package collie
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stats", func() {
+	var subject *Collection
+
+	BeforeEach(func() {
+		schema := CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "code", Size: 2},
+			{Name: "tag", Index: IndexTypeHash, NoData: true},
+		})
+
+		var err error
+		subject, err = OpenCollectionWithOptions(testDir, schema, &Options{SegmentRows: 2})
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, name := range []string{"alice", "bob", "anna"} {
+			txn := subject.Begin(1)
+			txn.Add(testRecord{"name": Value(name), "code": Value(name[:2]), "tag": Value(name[:1])})
+			_, err = txn.Commit()
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should report stats", func() {
+		Expect(subject.Value("name", 1)).To(Equal([]byte("bob")))
+		Expect(subject.Offsets("tag", []byte("a"))).To(Equal([]int64{0, 2}))
+
+		txn := subject.Begin(1)
+		txn.Add(testRecord{"name": Value("x"), "code": Value("xx"), "tag": Value("x")})
+		w, err := txn.write(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		w.rollback()
+
+		stats, err := subject.Stats(true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.Offset).To(Equal(int64(3)))
+		Expect(stats.FirstOffset).To(Equal(int64(0)))
+		Expect(stats.Rows).To(Equal(int64(3)))
+		Expect(stats.Segments).To(Equal(2))
+		Expect(stats.Columns).To(Equal([]ColumnStats{{Name: "name", Size: 36}, {Name: "code", Size: 6}}))
+		Expect(stats.Indices).To(Equal([]IndexStats{{Name: "tag", Keys: 2}}))
+		Expect(stats.Commits).To(Equal(int64(3)))
+		Expect(stats.CommittedRows).To(Equal(int64(3)))
+		Expect(stats.Rollbacks).To(Equal(int64(1)))
+		Expect(stats.CommitTime).To(BeNumerically(">", 0))
+		Expect(stats.Reads).To(Equal(int64(1)))
+		Expect(stats.ReadTime).To(BeNumerically(">", 0))
+		Expect(stats.Lookups).To(Equal(int64(1)))
+		Expect(stats.LookupTime).To(BeNumerically(">", 0))
+
+		stats, err = subject.Stats(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.Indices).To(Equal([]IndexStats{{Name: "tag", Keys: -1}}))
+	})
+
+	It("should publish expvars", func() {
+		subject.PublishExpvar("collie.stats.test")
+
+		var stats Stats
+		Expect(json.Unmarshal([]byte(expvar.Get("collie.stats.test").String()), &stats)).To(Succeed())
+		Expect(stats.Offset).To(Equal(int64(3)))
+		Expect(stats.Commits).To(Equal(int64(3)))
+	})
+
+	It("should serve prometheus metrics", func() {
+		rec := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/metrics", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		MetricsHandler(map[string]*Collection{`main"`: subject}, true).ServeHTTP(rec, req)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.HeaderMap.Get("Content-Type")).To(HavePrefix("text/plain"))
+
+		body := rec.Body.String()
+		Expect(body).To(ContainSubstring("# TYPE collie_offset gauge\ncollie_offset{collection=\"main\\\"\"} 3\n"))
+		Expect(body).To(ContainSubstring("# TYPE collie_commits_total counter\ncollie_commits_total{collection=\"main\\\"\"} 3\n"))
+		Expect(body).To(ContainSubstring("collie_column_bytes{collection=\"main\\\"\",column=\"code\"} 6\n"))
+		Expect(body).To(ContainSubstring("collie_index_keys{collection=\"main\\\"\",index=\"tag\"} 2\n"))
+
+		buf := new(bytes.Buffer)
+		Expect(WritePrometheus(buf, map[string]*Collection{"main": subject}, false)).To(Succeed())
+		Expect(buf.String()).NotTo(ContainSubstring("collie_index_keys"))
+	})
+
+})
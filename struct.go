@@ -0,0 +1,339 @@
+package collie
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errNotStruct       = errors.New("collie: value must be a struct or a pointer to a struct")
+	errNotStructPtr    = errors.New("collie: value must be a non-nil pointer to a struct")
+	errShortValue      = errors.New("collie: value too short")
+	errUnsupportedType = errors.New("collie: unsupported field type")
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	binaryMarshalerType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+)
+
+// AddStruct stashes a struct for the next commit. Exported fields are
+// mapped to columns via tags:
+//
+//	Name string   `collie:"name"`        // data column 'name'
+//	Tag  string   `collie:"tag,index"`   // index 'tag'
+//	Tags []string `collie:"tags,index"`  // multi-valued index 'tags'
+//	Skip string   `collie:"-"`           // ignored
+//
+// Untagged fields are mapped to columns named after the field. Fields
+// tagged as index feed both, the index and the data column of the same
+// name, if any. Strings and byte slices are stored as-is, integers and
+// floats as big-endian values of their size, bools as a single byte and
+// time.Time as big-endian Unix seconds. Other types must implement
+// encoding.BinaryMarshaler.
+func (t *Txn) AddStruct(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return errNotStruct
+	}
+
+	codec, err := structCodecOf(rv.Type())
+	if err != nil {
+		return err
+	}
+	t.Add(&structRecord{codec: codec, v: rv})
+	return nil
+}
+
+// Load decodes the row at offset into v, which must be a pointer to a
+// struct. Only fields mapped to data columns are populated, see
+// Txn.AddStruct for details.
+func (c *Collection) Load(offset int64, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errNotStructPtr
+	}
+	rv = rv.Elem()
+
+	codec, err := structCodecOf(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, f := range codec.fields {
+		col, ok := c.schema.column(f.name)
+		if !ok {
+			return ErrColumnNotFound
+		} else if col.NoData || (f.index && f.multi) {
+			continue
+		}
+
+		val, err := c.Value(f.name, offset)
+		if err != nil {
+			return err
+		}
+		if col.Size > 0 && rv.FieldByIndex(f.path).Kind() == reflect.String {
+			val = trimZeros(val)
+		}
+		if err := decodeField(rv.FieldByIndex(f.path), val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// structRecord is a Record, backed by a struct
+type structRecord struct {
+	codec *structCodec
+	v     reflect.Value
+}
+
+func (r *structRecord) ValueAt(name string) (Value, error) {
+	f, ok := r.codec.byName[name]
+	if !ok || f.multi {
+		return nil, nil
+	}
+	return encodeField(r.v.FieldByIndex(f.path))
+}
+
+func (r *structRecord) IValuesAt(name string) ([]Value, error) {
+	f, ok := r.codec.byName[name]
+	if !ok || !f.index {
+		return nil, nil
+	}
+
+	fv := r.v.FieldByIndex(f.path)
+	if !f.multi {
+		val, err := encodeField(fv)
+		if err != nil {
+			return nil, err
+		}
+		return []Value{val}, nil
+	}
+
+	vals := make([]Value, 0, fv.Len())
+	for i := 0; i < fv.Len(); i++ {
+		val, err := encodeField(fv.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, val)
+	}
+	return vals, nil
+}
+
+// structCodec describes the column mapping of a struct type
+type structCodec struct {
+	fields []structField
+	byName map[string]*structField
+}
+
+type structField struct {
+	name  string
+	path  []int
+	index bool
+	multi bool
+}
+
+var structCodecs = struct {
+	m map[reflect.Type]*structCodec
+	sync.RWMutex
+}{m: make(map[reflect.Type]*structCodec)}
+
+// structCodecOf returns the (cached) codec of a struct type
+func structCodecOf(t reflect.Type) (*structCodec, error) {
+	structCodecs.RLock()
+	codec, ok := structCodecs.m[t]
+	structCodecs.RUnlock()
+	if ok {
+		return codec, nil
+	}
+
+	codec = &structCodec{byName: make(map[string]*structField)}
+	if err := codec.parse(t, nil); err != nil {
+		return nil, err
+	}
+	for i := range codec.fields {
+		codec.byName[codec.fields[i].name] = &codec.fields[i]
+	}
+
+	structCodecs.Lock()
+	structCodecs.m[t] = codec
+	structCodecs.Unlock()
+	return codec, nil
+}
+
+func (c *structCodec) parse(t reflect.Type, path []int) error {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("collie")
+		if tag == "-" {
+			continue
+		}
+
+		// Flatten embedded structs
+		fpath := append(append([]int(nil), path...), i)
+		if sf.Anonymous && tag == "" && sf.Type.Kind() == reflect.Struct && sf.Type != timeType {
+			if err := c.parse(sf.Type, fpath); err != nil {
+				return err
+			}
+			continue
+		} else if sf.PkgPath != "" {
+			continue
+		}
+
+		f := structField{name: sf.Name, path: fpath}
+		if parts := strings.Split(tag, ","); parts[0] != "" {
+			f.name = parts[0]
+			f.index = len(parts) > 1 && parts[1] == "index"
+		} else if len(parts) > 1 {
+			f.index = parts[1] == "index"
+		}
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Slice && !isBytes(ft) && !ft.Implements(binaryMarshalerType) {
+			if !f.index {
+				return errors.New("collie: slice field '" + sf.Name + "' must be an index")
+			}
+			f.multi, ft = true, ft.Elem()
+		}
+		if !supportedType(ft) {
+			return errors.New("collie: field '" + sf.Name + "' has an unsupported type")
+		}
+		c.fields = append(c.fields, f)
+	}
+	return nil
+}
+
+func supportedType(t reflect.Type) bool {
+	if t == timeType || isBytes(t) || t.Implements(binaryMarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// encodeField encodes a field value
+func encodeField(v reflect.Value) (Value, error) {
+	if v.Type() == timeType {
+		return encodeUint(uint64(v.Interface().(time.Time).Unix()), 8), nil
+	} else if isBytes(v.Type()) {
+		return Value(v.Bytes()), nil
+	} else if m, ok := v.Interface().(encoding.BinaryMarshaler); ok {
+		return m.MarshalBinary()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return Value(v.String()), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return Value{1}, nil
+		}
+		return Value{0}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return encodeUint(uint64(v.Int()), int(v.Type().Size())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return encodeUint(v.Uint(), int(v.Type().Size())), nil
+	case reflect.Float32:
+		return encodeUint(uint64(math.Float32bits(float32(v.Float()))), 4), nil
+	case reflect.Float64:
+		return encodeUint(math.Float64bits(v.Float()), 8), nil
+	}
+	return nil, errUnsupportedType
+}
+
+// decodeField decodes val into a field
+func decodeField(v reflect.Value, val Value) error {
+	if v.Type() == timeType {
+		n, err := decodeUint(val, 8)
+		if err == nil {
+			v.Set(reflect.ValueOf(time.Unix(int64(n), 0)))
+		}
+		return err
+	} else if isBytes(v.Type()) {
+		v.SetBytes(val)
+		return nil
+	} else if u, ok := v.Addr().Interface().(encoding.BinaryUnmarshaler); ok {
+		return u.UnmarshalBinary(val)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(string(val))
+		return nil
+	case reflect.Bool:
+		if len(val) < 1 {
+			return errShortValue
+		}
+		v.SetBool(val[0] != 0)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size := int(v.Type().Size())
+		n, err := decodeUint(val, size)
+		if err == nil {
+			// Sign-extend
+			shift := uint(64 - 8*size)
+			v.SetInt(int64(n<<shift) >> shift)
+		}
+		return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := decodeUint(val, int(v.Type().Size()))
+		if err == nil {
+			v.SetUint(n)
+		}
+		return err
+	case reflect.Float32:
+		n, err := decodeUint(val, 4)
+		if err == nil {
+			v.SetFloat(float64(math.Float32frombits(uint32(n))))
+		}
+		return err
+	case reflect.Float64:
+		n, err := decodeUint(val, 8)
+		if err == nil {
+			v.SetFloat(math.Float64frombits(n))
+		}
+		return err
+	}
+	return errUnsupportedType
+}
+
+func isBytes(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+func encodeUint(n uint64, size int) Value {
+	buf := make(Value, 8)
+	binary.BigEndian.PutUint64(buf, n)
+	return buf[8-size:]
+}
+
+func decodeUint(val Value, size int) (uint64, error) {
+	if len(val) < size {
+		return 0, errShortValue
+	}
+
+	buf := make([]byte, 8)
+	copy(buf[8-size:], val[:size])
+	return binary.BigEndian.Uint64(buf), nil
+}
+
+func trimZeros(val Value) Value {
+	for len(val) > 0 && val[len(val)-1] == 0 {
+		val = val[:len(val)-1]
+	}
+	return val
+}
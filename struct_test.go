@@ -0,0 +1,113 @@
+package collie
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type testStructBase struct {
+	ID uint32 `collie:"id"`
+}
+
+type testStruct struct {
+	testStructBase
+	Name    string    `collie:"name"`
+	Code    string    `collie:"code,index"`
+	Score   int16     `collie:"score"`
+	Ratio   float64   `collie:"ratio"`
+	Active  bool      `collie:"active"`
+	Created time.Time `collie:"created"`
+	Raw     []byte    `collie:"raw"`
+	Tags    []string  `collie:"tags,index"`
+	Skipped string    `collie:"-"`
+	private string
+}
+
+var _ = Describe("Struct mapping", func() {
+	var subject *Collection
+	var created = time.Unix(1400000000, 0)
+
+	BeforeEach(func() {
+		schema := CreateSchema([]Column{
+			{Name: "id", Size: 4},
+			{Name: "name"},
+			{Name: "code", Size: 4, Index: IndexTypeHash},
+			{Name: "score", Size: 2},
+			{Name: "ratio", Size: 8},
+			{Name: "active", Size: 1},
+			{Name: "created", Size: 8},
+			{Name: "raw"},
+			{Name: "tags", Index: IndexTypeHash, NoData: true},
+		})
+
+		var err error
+		subject, err = OpenCollection(testDir, schema)
+		Expect(err).NotTo(HaveOccurred())
+
+		txn := subject.Begin(2)
+		Expect(txn.AddStruct(&testStruct{
+			testStructBase: testStructBase{ID: 7},
+			Name:           "alice",
+			Code:           "ab",
+			Score:          -3,
+			Ratio:          0.25,
+			Active:         true,
+			Created:        created,
+			Raw:            []byte{0, 1},
+			Tags:           []string{"x", "y"},
+			Skipped:        "skip",
+		})).To(Succeed())
+		Expect(txn.AddStruct(testStruct{Name: "bob", Code: "cd", Tags: []string{"y"}})).To(Succeed())
+		_, err = txn.Commit()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should encode structs", func() {
+		Expect(subject.Value("id", 0)).To(Equal([]byte{0, 0, 0, 7}))
+		Expect(subject.Value("name", 0)).To(Equal([]byte("alice")))
+		Expect(subject.Value("score", 0)).To(Equal([]byte{0xff, 0xfd}))
+		Expect(subject.Value("created", 0)).To(Equal([]byte{0, 0, 0, 0, 0x53, 0x72, 0x4e, 0x00}))
+		Expect(subject.Offsets("code", []byte("cd"))).To(Equal([]int64{1}))
+		Expect(subject.Offsets("tags", []byte("x"))).To(Equal([]int64{0}))
+		Expect(subject.Offsets("tags", []byte("y"))).To(Equal([]int64{0, 1}))
+	})
+
+	It("should decode structs", func() {
+		var v testStruct
+		Expect(subject.Load(0, &v)).To(Succeed())
+		Expect(v).To(Equal(testStruct{
+			testStructBase: testStructBase{ID: 7},
+			Name:           "alice",
+			Code:           "ab",
+			Score:          -3,
+			Ratio:          0.25,
+			Active:         true,
+			Created:        created,
+			Raw:            []byte{0, 1},
+		}))
+
+		Expect(subject.Load(2, &v)).To(Equal(ErrNotFound))
+	})
+
+	It("should reject invalid values", func() {
+		txn := subject.Begin(1)
+		Expect(txn.AddStruct("x")).To(Equal(errNotStruct))
+		Expect(txn.AddStruct(struct {
+			Vals []int `collie:"vals"`
+		}{})).To(MatchError("collie: slice field 'Vals' must be an index"))
+		Expect(txn.AddStruct(struct{ M map[string]int }{})).To(MatchError("collie: field 'M' has an unsupported type"))
+
+		var v testStruct
+		Expect(subject.Load(0, v)).To(Equal(errNotStructPtr))
+		Expect(subject.Load(0, &struct {
+			X string `collie:"x"`
+		}{})).To(Equal(ErrColumnNotFound))
+	})
+
+})
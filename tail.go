@@ -0,0 +1,201 @@
+package collie
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// The offset file holds the committed offset, written after each commit
+const offsetFile = "OFFSET"
+
+var errNoCollection = errors.New("collie: no such collection")
+
+// WaitFor blocks until the offset exceeds offset or ctx is done and
+// returns the current offset. Read-only collections watch the collection
+// directory and pick up rows committed by other processes.
+func (c *Collection) WaitFor(ctx context.Context, offset int64) (int64, error) {
+	var events <-chan struct{}
+	if c.opts.ReadOnly {
+		w, err := watchOffset(c.dir)
+		if err != nil {
+			return c.Offset(), err
+		}
+		defer w.Close()
+		events = w.C
+	}
+
+	for {
+		changed := c.changed()
+		if err := c.Refresh(); err != nil {
+			return c.Offset(), err
+		}
+		if current := c.Offset(); current > offset {
+			return current, nil
+		}
+
+		select {
+		case <-changed:
+		case <-events:
+		case <-ctx.Done():
+			return c.Offset(), ctx.Err()
+		}
+	}
+}
+
+// Refresh picks up rows committed by other processes. Only read-only
+// collections are refreshed, for all others this is a no-op.
+func (c *Collection) Refresh() error {
+	if !c.opts.ReadOnly {
+		return nil
+	}
+
+	c.wmux.Lock()
+	defer c.wmux.Unlock()
+
+	return c.refresh()
+}
+
+// openReadOnly opens an existing collection without modifying it
+func (c *Collection) openReadOnly() error {
+	bases, err := listSegments(c.dir)
+	if os.IsNotExist(err) {
+		return errNoCollection
+	} else if err != nil {
+		return err
+	}
+
+	var seg *segment
+	if hasSegmentFiles(c.dir, c.schema) {
+		seg, err = openSegment(c.dir, 0, c.schema, c.opts)
+	} else if len(bases) != 0 {
+		seg, err = openSegment(filepath.Join(c.dir, segmentName(bases[0])), bases[0], c.schema, c.opts)
+	} else {
+		err = errNoCollection
+	}
+	if err != nil {
+		return err
+	}
+
+	c.segs = []*segment{seg}
+	if err := c.refresh(); err != nil {
+		c.Close()
+		return err
+	}
+	return nil
+}
+
+// refresh picks up new rows and segments and releases segments dropped
+// by the writer. Must only be called while holding the write lock.
+func (c *Collection) refresh() error {
+	committed, err := readOffsetFile(c.dir)
+	if err != nil {
+		return err
+	}
+	bases, err := listSegments(c.dir)
+	if err != nil {
+		return err
+	}
+
+	c.smux.RLock()
+	if len(c.segs) == 0 {
+		c.smux.RUnlock()
+		return ErrClosed
+	}
+	head := c.segs[len(c.segs)-1]
+	n := 0
+	for n < len(c.segs)-1 && c.isDropped(c.segs[n]) {
+		n++
+	}
+	c.smux.RUnlock()
+
+	if err := head.Refresh(); err != nil {
+		return err
+	}
+
+	// Segments are only picked up once they hold committed rows
+	var added []*segment
+	for _, base := range bases {
+		if base <= head.base || (committed > -1 && base >= committed) {
+			continue
+		}
+		seg, err := openSegment(filepath.Join(c.dir, segmentName(base)), base, c.schema, c.opts)
+		if err != nil {
+			for _, seg := range added {
+				seg.Close()
+			}
+			return err
+		}
+		added = append(added, seg)
+		head = seg
+	}
+
+	c.smux.Lock()
+	dropped := c.segs[:n]
+	c.segs = append(append([]*segment(nil), c.segs[n:]...), added...)
+	c.smux.Unlock()
+
+	for _, seg := range dropped {
+		seg.Close()
+	}
+
+	offset := head.base + head.Len()
+	if committed > -1 && committed < offset {
+		offset = committed
+	}
+	if offset > c.Offset() {
+		c.storeOffset(offset)
+	}
+	return nil
+}
+
+// isDropped returns true if the files of seg were removed by the writer
+func (c *Collection) isDropped(seg *segment) bool {
+	if seg.dir == c.dir {
+		return !hasSegmentFiles(c.dir, c.schema)
+	}
+	_, err := os.Stat(seg.dir)
+	return os.IsNotExist(err)
+}
+
+// openOffsetFile opens the offset file and stores the current offset
+func (c *Collection) openOffsetFile() (err error) {
+	if c.ofile, err = os.OpenFile(filepath.Join(c.dir, offsetFile), os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+		return
+	}
+	return c.commitOffset(c.Offset())
+}
+
+// commitOffset writes the committed offset to the offset file
+func (c *Collection) commitOffset(offset int64) error {
+	_, err := c.ofile.WriteAt(encodeOffset(offset), 0)
+	return err
+}
+
+// writeOffsetFile creates an offset file in dir
+func writeOffsetFile(dir string, offset int64) error {
+	return ioutil.WriteFile(filepath.Join(dir, offsetFile), encodeOffset(offset), 0644)
+}
+
+// readOffsetFile reads the committed offset of the collection in dir,
+// returns -1 if unknown
+func readOffsetFile(dir string) (int64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, offsetFile))
+	if os.IsNotExist(err) {
+		return -1, nil
+	} else if err != nil {
+		return 0, err
+	} else if len(data) < 8 {
+		return 0, nil
+	}
+	return int64(binary.BigEndian.Uint64(data)), nil
+}
+
+func encodeOffset(offset int64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(offset))
+	return buf
+}
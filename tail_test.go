@@ -0,0 +1,179 @@
+package collie
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tailing", func() {
+	var subject *Collection
+	var schema *Schema
+
+	add := func(coll *Collection, names ...string) error {
+		txn := coll.Begin(len(names))
+		for _, name := range names {
+			txn.Add(testRecord{"name": Value(name), "tag": Value(name[:1])})
+		}
+		_, err := txn.Commit()
+		return err
+	}
+
+	openReader := func(opts *Options) *Collection {
+		opts.ReadOnly = true
+		opts.NoIndices = true
+		reader, err := OpenCollectionWithOptions(testDir, schema, opts)
+		Expect(err).NotTo(HaveOccurred())
+		return reader
+	}
+
+	BeforeEach(func() {
+		schema = CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "tag", Index: IndexTypeHash},
+		})
+
+		var err error
+		subject, err = OpenCollection(testDir, schema)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(add(subject, "alice", "bob")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should wait for commits", func() {
+		offset, err := subject.WaitFor(context.Background(), 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offset).To(Equal(int64(2)))
+
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(10 * time.Millisecond)
+			Expect(add(subject, "carol")).To(Succeed())
+		}()
+
+		offset, err = subject.WaitFor(context.Background(), 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offset).To(Equal(int64(3)))
+	})
+
+	It("should stop waiting when the context is done", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		offset, err := subject.WaitFor(ctx, 2)
+		Expect(err).To(Equal(context.DeadlineExceeded))
+		Expect(offset).To(Equal(int64(2)))
+	})
+
+	It("should store the committed offset", func() {
+		Expect(readOffsetFile(testDir)).To(Equal(int64(2)))
+		Expect(add(subject, "carol")).To(Succeed())
+		Expect(readOffsetFile(testDir)).To(Equal(int64(3)))
+		Expect(readOffsetFile(filepath.Join(testDir, "missing"))).To(Equal(int64(-1)))
+	})
+
+	It("should open read-only", func() {
+		reader := openReader(&Options{})
+		defer reader.Close()
+
+		Expect(reader.Offset()).To(Equal(int64(2)))
+		Expect(reader.Value("name", 1)).To(Equal([]byte("bob")))
+		Expect(add(reader, "carol")).To(Equal(ErrReadOnly))
+		Expect(reader.ApplyRetention()).To(Equal(ErrReadOnly))
+
+		_, err := reader.Offsets("tag", []byte("a"))
+		Expect(err).To(Equal(ErrColumnNotFound))
+	})
+
+	It("should open indices read-only", func() {
+		Expect(subject.Close()).To(Succeed())
+
+		reader, err := OpenCollectionWithOptions(testDir, schema, &Options{ReadOnly: true})
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+
+		Expect(reader.Offsets("tag", []byte("b"))).To(Equal([]int64{1}))
+	})
+
+	It("should validate options", func() {
+		_, err := OpenCollectionWithOptions(testDir, schema, &Options{NoIndices: true})
+		Expect(err).To(MatchError("collie: indices can only be skipped by read-only collections"))
+
+		coll, err := OpenCollectionWithOptions(filepath.Join(testDir, "missing"), schema, &Options{ReadOnly: true, NoIndices: true})
+		Expect(err).To(Equal(errNoCollection))
+		Expect(coll).To(BeNil())
+	})
+
+	It("should only pick up committed rows", func() {
+		reader := openReader(&Options{})
+		defer reader.Close()
+
+		for _, col := range subject.head().columns {
+			Expect(col.Add([]byte("x"))).To(Succeed())
+		}
+		Expect(reader.Refresh()).To(Succeed())
+		Expect(reader.Offset()).To(Equal(int64(2)))
+
+		for _, col := range subject.head().columns {
+			Expect(col.Truncate(2)).To(Succeed())
+		}
+		Expect(add(subject, "carol")).To(Succeed())
+		Expect(reader.Refresh()).To(Succeed())
+		Expect(reader.Offset()).To(Equal(int64(3)))
+		Expect(reader.Value("name", 2)).To(Equal([]byte("carol")))
+	})
+
+	It("should tail other writers", func() {
+		reader := openReader(&Options{})
+		defer reader.Close()
+
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(10 * time.Millisecond)
+			Expect(add(subject, "carol", "dave")).To(Succeed())
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		offset, err := reader.WaitFor(ctx, 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offset).To(Equal(int64(4)))
+		Expect(reader.Value("name", 3)).To(Equal([]byte("dave")))
+	})
+
+	It("should follow segments", func() {
+		Expect(subject.Close()).To(Succeed())
+		Expect(os.RemoveAll(testDir)).To(Succeed())
+
+		var err error
+		subject, err = OpenCollectionWithOptions(testDir, schema, &Options{SegmentRows: 2, RetainRows: 4})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(add(subject, "alice", "bob")).To(Succeed())
+		Expect(add(subject, "carol")).To(Succeed())
+
+		reader := openReader(&Options{SegmentRows: 2})
+		defer reader.Close()
+		Expect(reader.Offset()).To(Equal(int64(3)))
+		Expect(reader.segs).To(HaveLen(2))
+
+		Expect(add(subject, "dave")).To(Succeed())
+		Expect(add(subject, "eve", "fred")).To(Succeed())
+		Expect(add(subject, "gina")).To(Succeed())
+		Expect(subject.FirstOffset()).To(Equal(int64(2)))
+
+		Expect(reader.Refresh()).To(Succeed())
+		Expect(reader.Offset()).To(Equal(int64(7)))
+		Expect(reader.FirstOffset()).To(Equal(int64(2)))
+		Expect(reader.Value("name", 3)).To(Equal([]byte("dave")))
+		Expect(reader.Value("name", 6)).To(Equal([]byte("gina")))
+	})
+
+})
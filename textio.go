@@ -0,0 +1,260 @@
+package collie
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+)
+
+// Encoding determines how values are represented in text formats
+type Encoding uint8
+
+const (
+	// Values are plain text, trailing zero padding of fixed-length
+	// columns is trimmed on export
+	EncodingText Encoding = iota
+	// Values are hex-encoded
+	EncodingHex
+	// Values are standard base64-encoded
+	EncodingBase64
+)
+
+// Encode encodes a value
+func (e Encoding) Encode(v Value) string {
+	switch e {
+	case EncodingHex:
+		return hex.EncodeToString(v)
+	case EncodingBase64:
+		return base64.StdEncoding.EncodeToString(v)
+	}
+	return string(v)
+}
+
+// Decode decodes a value
+func (e Encoding) Decode(s string) (Value, error) {
+	switch e {
+	case EncodingHex:
+		return hex.DecodeString(s)
+	case EncodingBase64:
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return Value(s), nil
+}
+
+// ImportOptions can be used to tune imports
+type ImportOptions struct {
+	// Maps input field names to column names. Fields are mapped to
+	// the column of the same name by default, unknown fields are
+	// ignored. Values of indexed columns are added to their index.
+	Fields map[string]string
+	// Value encodings by column name. Default: EncodingText
+	Encodings map[string]Encoding
+	// The number of rows committed per transaction. Default: 1000
+	BatchSize int
+}
+
+func (o *ImportOptions) norm() *ImportOptions {
+	var opts ImportOptions
+	if o != nil {
+		opts = *o
+	}
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 1000
+	}
+	return &opts
+}
+
+// ExportOptions can be used to tune exports
+type ExportOptions struct {
+	// The data columns to export. Default: all data columns
+	Columns []string
+	// The range of offsets to export, [From, To). Rows before the first
+	// offset are skipped, To defaults to the current offset if < 1.
+	From, To int64
+	// Value encodings by column name. Default: EncodingText
+	Encodings map[string]Encoding
+	// The number of rows per record batch or row group of columnar
+	// formats. Default: 10000
+	BatchRows int
+}
+
+func (o *ExportOptions) norm(c *Collection) (*ExportOptions, error) {
+	var opts ExportOptions
+	if o != nil {
+		opts = *o
+	}
+	if opts.BatchRows < 1 {
+		opts.BatchRows = 10000
+	}
+
+	head := c.head()
+	if head == nil {
+		return nil, ErrClosed
+	} else if len(opts.Columns) == 0 {
+		for _, col := range c.schema.Columns() {
+			if _, ok := head.columns[col.Name]; ok {
+				opts.Columns = append(opts.Columns, col.Name)
+			}
+		}
+	}
+	for _, name := range opts.Columns {
+		if _, ok := head.columns[name]; !ok {
+			return nil, ErrColumnNotFound
+		}
+	}
+
+	if first := c.FirstOffset(); opts.From < first {
+		opts.From = first
+	}
+	if max := c.Offset(); opts.To < 1 || opts.To > max {
+		opts.To = max
+	}
+	return &opts, nil
+}
+
+// importer maps input fields to rows and commits them in batches
+type importer struct {
+	opts  *ImportOptions
+	cols  map[string]Column
+	txn   *Txn
+	count int64
+}
+
+func newImporter(c *Collection, opts *ImportOptions) *importer {
+	im := &importer{opts: opts.norm(), cols: make(map[string]Column)}
+	for _, col := range c.schema.Columns() {
+		im.cols[col.Name] = col
+	}
+	im.txn = c.Begin(im.opts.BatchSize)
+	return im
+}
+
+// column returns the column a field maps to
+func (im *importer) column(field string) (Column, bool) {
+	name := field
+	if im.opts.Fields != nil {
+		if name = im.opts.Fields[field]; name == "" {
+			return Column{}, false
+		}
+	}
+	col, ok := im.cols[name]
+	return col, ok
+}
+
+// add stashes a row of field values and commits full batches
+func (im *importer) add(fields map[string][]string) error {
+	row := im.txn.New()
+	for field, vals := range fields {
+		col, ok := im.column(field)
+		if !ok {
+			continue
+		}
+
+		enc := im.opts.Encodings[col.Name]
+		for i, s := range vals {
+			val, err := enc.Decode(s)
+			if err != nil {
+				return err
+			}
+
+			if !col.NoData {
+				if i > 0 {
+					return errors.New("collie: multiple values for column '" + col.Name + "'")
+				}
+				row.SetColumn(col.Name, val)
+			}
+			if col.Index != IndexTypeNone {
+				row.AddIndex(col.Name, val)
+			}
+		}
+	}
+
+	if len(im.txn.stash) >= im.opts.BatchSize {
+		return im.flush()
+	}
+	return nil
+}
+
+// flush commits all stashed rows
+func (im *importer) flush() error {
+	n := len(im.txn.stash)
+	if n == 0 {
+		return nil
+	}
+	if _, err := im.txn.Commit(); err != nil {
+		return err
+	}
+	im.txn.Discard()
+	im.count += int64(n)
+	return nil
+}
+
+// export reads the values of each row within the configured range and
+// passes them to emit, checking ctx after every ExportOptions.BatchRows
+func (c *Collection) export(ctx context.Context, opts *ExportOptions, emit func([]string) error) error {
+	padded := make(map[string]bool)
+	for _, col := range c.schema.Columns() {
+		padded[col.Name] = col.Size > 0 && opts.Encodings[col.Name] == EncodingText
+	}
+
+	vals := make([]string, len(opts.Columns))
+	return c.exportBatches(ctx, opts, func(n int64, cols [][]Value) error {
+		for row := int64(0); row < n; row++ {
+			for i, name := range opts.Columns {
+				val := cols[i][row]
+				if padded[name] {
+					val = bytes.TrimRight(val, "\x00")
+				}
+				vals[i] = opts.Encodings[name].Encode(val)
+			}
+			if err := emit(vals); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// exportColumns returns the definitions of the exported columns
+func (c *Collection) exportColumns(opts *ExportOptions) []Column {
+	defs := make(map[string]Column)
+	for _, col := range c.schema.Columns() {
+		defs[col.Name] = col
+	}
+
+	cols := make([]Column, len(opts.Columns))
+	for i, name := range opts.Columns {
+		cols[i] = defs[name]
+	}
+	return cols
+}
+
+// exportBatches reads the exported columns in batches of rows and passes
+// the values of each column to emit, checking ctx before each batch
+func (c *Collection) exportBatches(ctx context.Context, opts *ExportOptions, emit func(int64, [][]Value) error) error {
+	for from := opts.From; from < opts.To; from += int64(opts.BatchRows) {
+		if err := canceled(ctx); err != nil {
+			return err
+		}
+
+		to := from + int64(opts.BatchRows)
+		if to > opts.To {
+			to = opts.To
+		}
+
+		vals := make([][]Value, len(opts.Columns))
+		for i, name := range opts.Columns {
+			col, err := c.readColumn(name, from, to)
+			if err != nil {
+				return err
+			}
+			vals[i] = col
+		}
+		if err := emit(to-from, vals); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package collie
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Minimal thrift compact protocol encoder, as required by the
+// Parquet file format
+
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+type thriftWriter struct {
+	bytes.Buffer
+	last  int16
+	stack []int16
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	if delta := id - w.last; delta > 0 && delta <= 15 {
+		w.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.WriteByte(typ)
+		w.varint(int64(id))
+	}
+	w.last = id
+}
+
+func (w *thriftWriter) varint(v int64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	w.Write(buf[:binary.PutVarint(buf, v)])
+}
+
+func (w *thriftWriter) uvarint(v uint64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	w.Write(buf[:binary.PutUvarint(buf, v)])
+}
+
+func (w *thriftWriter) I32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) I64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.varint(v)
+}
+
+func (w *thriftWriter) String(id int16, s string) {
+	w.field(id, thriftBinary)
+	w.str(s)
+}
+
+func (w *thriftWriter) str(s string) {
+	w.uvarint(uint64(len(s)))
+	w.WriteString(s)
+}
+
+// List writes a list header, elements must follow
+func (w *thriftWriter) List(id int16, typ byte, n int) {
+	w.field(id, thriftList)
+	if n < 15 {
+		w.WriteByte(byte(n)<<4 | typ)
+	} else {
+		w.WriteByte(0xf0 | typ)
+		w.uvarint(uint64(n))
+	}
+}
+
+// Struct begins a struct field, must be closed via End
+func (w *thriftWriter) Struct(id int16) {
+	w.field(id, thriftStruct)
+	w.Begin()
+}
+
+// Begin begins a struct list element, must be closed via End
+func (w *thriftWriter) Begin() {
+	w.stack = append(w.stack, w.last)
+	w.last = 0
+}
+
+// End ends a struct
+func (w *thriftWriter) End() {
+	w.WriteByte(0)
+	if n := len(w.stack); n > 0 {
+		w.last = w.stack[n-1]
+		w.stack = w.stack[:n-1]
+	}
+}
@@ -0,0 +1,33 @@
+package collie
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("thrift", func() {
+
+	It("should encode structs", func() {
+		w := new(thriftWriter)
+		w.I32(1, -2)
+		w.I64(3, 300)
+		w.String(20, "ab")
+		w.Struct(21)
+		w.I32(1, 1)
+		w.End()
+		w.List(22, thriftI32, 2)
+		w.varint(1)
+		w.varint(2)
+		w.End()
+
+		Expect(w.Bytes()).To(Equal([]byte{
+			0x15, 0x03, // 1: i32 -2
+			0x26, 0xd8, 0x04, // 3: i64 300
+			0x08, 0x28, 0x02, 'a', 'b', // 20: binary, long form
+			0x1c, 0x15, 0x02, 0x00, // 21: struct { 1: i32 1 }
+			0x19, 0x25, 0x02, 0x04, // 22: list<i32> [1, 2]
+			0x00,
+		}))
+	})
+
+})
@@ -1,6 +1,12 @@
 package collie
 
-import "github.com/bsm/collie/column"
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bsm/collie/column"
+)
 
 // A collection transaction. Transactions are not thread-safe
 // and must not be used across multiple goroutines.
@@ -18,7 +24,10 @@ func newTxn(coll *Collection, stash int) *Txn {
 
 // New initializes an empty row and stashes it for the next commit
 func (t *Txn) New() *Row {
-	row := newRow(len(t.c.columns), len(t.c.indices))
+	row := newRow(0, 0)
+	if head := t.c.head(); head != nil {
+		row = newRow(len(head.columns), len(head.indices))
+	}
 	t.stash = append(t.stash, row)
 	return row
 }
@@ -28,75 +37,223 @@ func (t *Txn) Add(rec Record) {
 	t.stash = append(t.stash, rec)
 }
 
-// Commit commits the transaction
-func (t *Txn) Commit() (offset int64, err error) {
-	var cval Value
-	var ivals []Value
-	updates := make([]indexUpdate, 0, len(t.c.indices)*len(t.stash)*2)
+// Commit commits the transaction. Columns and indices are written
+// concurrently, each index in a single atomic batch. On failure, all
+// columns are truncated and written batches are removed.
+func (t *Txn) Commit() (int64, error) {
+	return t.CommitContext(context.Background())
+}
 
+// CommitContext behaves like Commit, but aborts when ctx is done before
+// the rows are published. Aborted commits are rolled back, exactly like
+// failed ones, and return the context's error.
+func (t *Txn) CommitContext(ctx context.Context) (int64, error) {
 	t.c.wmux.Lock()
 	defer t.c.wmux.Unlock()
 
-	current := t.c.Offset()
-	offset = current
-	indices := make(map[string]map[string][]int64)
-	for name := range t.c.indices {
-		indices[name] = make(map[string][]int64)
+	w, err := t.write(ctx)
+	if err != nil {
+		return t.c.Offset(), err
+	}
+	if err := canceled(ctx); err != nil {
+		w.rollback()
+		return t.c.Offset(), err
+	}
+	return w.publish()
+}
+
+// Discard reset the stash
+func (t *Txn) Discard() {
+	t.stash = t.stash[:0]
+}
+
+// write writes all stashed records, without publishing them, checking ctx
+// between rows. Must only be called while holding the collection's write
+// lock.
+func (t *Txn) write(ctx context.Context) (*pendingWrite, error) {
+	if t.c.opts.ReadOnly {
+		return nil, ErrReadOnly
+	} else if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	offset := t.c.Offset()
+	seg, err := t.c.writable(offset)
+	if err != nil {
+		return nil, err
+	}
+	values, postings, err := t.collect(ctx, seg, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]func() error, 0, len(values)+len(postings))
+	for name, vals := range values {
+		col, vals := seg.columns[name], vals
+		tasks = append(tasks, func() error {
+			for _, val := range vals {
+				if err := canceled(ctx); err != nil {
+					return err
+				}
+				if err := col.Add(val); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	for name, p := range postings {
+		idx, p := seg.indices[name], p
+		tasks = append(tasks, func() error {
+			if err := canceled(ctx); err != nil {
+				return err
+			}
+			return idx.Write(p)
+		})
+	}
+
+	w := &pendingWrite{c: t.c, seg: seg, start: start, offset: offset, rows: int64(len(t.stash)), values: values, postings: postings}
+	for _, err := range parallel(t.c.opts.CommitConcurrency, tasks) {
+		if err != nil {
+			w.rollback()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// collect gathers column values and index postings of all stashed
+// records for seg, starting at offset
+func (t *Txn) collect(ctx context.Context, seg *segment, offset int64) (map[string][]Value, map[string]column.Postings, error) {
+	values := make(map[string][]Value, len(seg.columns))
+	for name := range seg.columns {
+		values[name] = make([]Value, 0, len(t.stash))
+	}
+	postings := make(map[string]column.Postings, len(seg.indices))
+	for name := range seg.indices {
+		postings[name] = make(column.Postings)
 	}
+
 	for _, rec := range t.stash {
-		for name, col := range t.c.columns {
-			if cval, err = rec.ValueAt(name); err != nil {
-				goto Rollback
-			} else if err = col.Add(cval); err != nil {
-				goto Rollback
+		if err := canceled(ctx); err != nil {
+			return nil, nil, err
+		}
+		for name, vals := range values {
+			val, err := rec.ValueAt(name)
+			if err != nil {
+				return nil, nil, err
 			}
+			values[name] = append(vals, val)
 		}
 
-		for name := range t.c.indices {
-			if ivals, err = rec.IValuesAt(name); err != nil {
-				goto Rollback
+		for name, p := range postings {
+			ivals, err := rec.IValuesAt(name)
+			if err != nil {
+				return nil, nil, err
 			}
 			for _, val := range ivals {
-				indices[name][string(val)] = append(indices[name][string(val)], offset)
+				p[string(val)] = append(p[string(val)], offset)
 			}
 		}
 		offset++
 	}
+	return values, postings, nil
+}
 
-	for name, valOffs := range indices {
-		idx := t.c.indices[name]
-		for val, offs := range valOffs {
-			if err = idx.Add([]byte(val), offs...); err != nil {
-				goto Rollback
-			}
-			for _, off := range offs {
-				updates = append(updates, indexUpdate{i: idx, v: Value(val), o: off})
+// canceled returns the error of ctx, if done
+func canceled(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return nil
+	}
+}
+
+// parallel runs tasks using up to n concurrent workers
+// and returns their errors in order
+func parallel(n int, tasks []func() error) []error {
+	errs := make([]error, len(tasks))
+	if n > len(tasks) {
+		n = len(tasks)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = tasks[i]()
 			}
-		}
+		}()
+	}
+	for i := range tasks {
+		jobs <- i
 	}
+	close(jobs)
+	wg.Wait()
+	return errs
+}
 
-	t.c.storeOffset(offset)
-	return
+// pendingWrite holds written, but unpublished records
+type pendingWrite struct {
+	c        *Collection
+	seg      *segment
+	start    time.Time
+	offset   int64
+	rows     int64
+	values   map[string][]Value
+	postings map[string]column.Postings
+}
 
-Rollback:
-	offset = current
-	for _, col := range t.c.columns {
-		col.Truncate(offset)
+// publish commits the written records, makes them visible, notifies
+// listeners and returns the new offset. The records are rolled back if
+// the offset cannot be committed.
+func (w *pendingWrite) publish() (int64, error) {
+	if err := w.commit(); err != nil {
+		w.revert()
+		return w.offset, err
 	}
-	for i := len(updates) - 1; i >= 0; i-- {
-		update := updates[i]
-		update.i.Undo(update.v, update.o)
+	return w.release(), nil
+}
+
+// commit flushes all columns and writes the new offset to the offset file
+func (w *pendingWrite) commit() error {
+	for _, col := range w.seg.columns {
+		if err := col.Flush(); err != nil {
+			return err
+		}
 	}
-	return
+	return w.c.commitOffset(w.offset + w.rows)
 }
 
-// Discard reset the stash
-func (t *Txn) Discard() {
-	t.stash = t.stash[:0]
+// revert restores the previous offset in the offset file and rolls back
+// the written records
+func (w *pendingWrite) revert() {
+	w.c.commitOffset(w.offset)
+	w.rollback()
+}
+
+// release makes committed records visible, notifies listeners and
+// returns the new offset
+func (w *pendingWrite) release() int64 {
+	offset := w.offset + w.rows
+	w.c.storeOffset(offset)
+	w.c.counters.commit(w.rows, w.start)
+	w.c.broadcast(&commitBatch{Offset: w.offset, Rows: w.rows, Values: w.values, Postings: w.postings})
+	return offset
 }
 
-type indexUpdate struct {
-	i column.Index
-	o int64
-	v Value
+// rollback truncates all columns and removes written postings
+func (w *pendingWrite) rollback() {
+	w.c.counters.rollback()
+	for _, col := range w.seg.columns {
+		col.Truncate(w.offset - w.seg.base)
+	}
+	for name, p := range w.postings {
+		w.seg.indices[name].Remove(p)
+	}
 }
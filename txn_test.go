@@ -1,9 +1,13 @@
 package collie
 
 import (
+	"context"
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/bsm/collie/column"
 	. "github.com/onsi/ginkgo"
@@ -48,6 +52,15 @@ var _ = Describe("Txn", func() {
 		Expect(subject.c.Offset()).To(Equal(int64(2)))
 	})
 
+	It("should store checksums on commit", func() {
+		_, err := subject.Commit()
+		Expect(err).NotTo(HaveOccurred())
+
+		info, err := os.Stat(filepath.Join(subject.c.head().dir, "last.cc.crc"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Size()).To(Equal(int64(16)))
+	})
+
 	It("should add new rows", func() {
 		row := subject.New()
 		row.SetColumn("first", Value("Jill"))
@@ -61,13 +74,13 @@ var _ = Describe("Txn", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(subject.c.Offset()).To(Equal(int64(3)))
 
-		offs, err := subject.c.indices["cityID"].Get(Value{0, 0, 3, 0})
+		offs, err := subject.c.head().indices["cityID"].Get(Value{0, 0, 3, 0})
 		Expect(err).NotTo(HaveOccurred())
 		Expect(offs).To(Equal([]int64{2}))
-		offs, err = subject.c.indices["cityID"].Get(Value{0, 0, 3, 1})
+		offs, err = subject.c.head().indices["cityID"].Get(Value{0, 0, 3, 1})
 		Expect(err).NotTo(HaveOccurred())
 		Expect(offs).To(Equal([]int64{2}))
-		offs, err = subject.c.indices["cityID"].Get(Value{0, 0, 3, 2})
+		offs, err = subject.c.head().indices["cityID"].Get(Value{0, 0, 3, 2})
 		Expect(err).NotTo(HaveOccurred())
 		Expect(offs).To(BeNil())
 	})
@@ -82,7 +95,7 @@ var _ = Describe("Txn", func() {
 			Expect(err).To(Equal(io.EOF))
 			Expect(subject.c.Offset()).To(Equal(int64(0)))
 
-			val, err := subject.c.columns["first"].Get(0)
+			val, err := subject.c.head().columns["first"].Get(0)
 			Expect(val).To(BeNil())
 			Expect(err).To(Equal(column.ErrNotFound))
 		})
@@ -108,15 +121,119 @@ var _ = Describe("Txn", func() {
 			Expect(n).To(Equal(int64(2)))
 			Expect(err).To(Equal(io.EOF))
 
-			offs, err := subject.c.indices["age"].Get(Value{25})
+			offs, err := subject.c.head().indices["age"].Get(Value{25})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(offs).To(BeNil())
 
-			offs, err = subject.c.indices["cityID"].Get(Value{0, 0, 3, 0})
+			offs, err = subject.c.head().indices["cityID"].Get(Value{0, 0, 3, 0})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(offs).To(BeNil())
 		})
 
+		It("should remove written index batches on failures", func() {
+			subject.c.head().indices["age"] = testIndexBadWrite{subject.c.head().indices["age"]}
+
+			n, err := subject.Commit()
+			Expect(n).To(Equal(int64(0)))
+			Expect(err).To(Equal(io.ErrShortWrite))
+			Expect(subject.c.Offset()).To(Equal(int64(0)))
+
+			offs, err := subject.c.head().indices["cityID"].Get(Value{0, 0, 2, 0})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(offs).To(BeNil())
+
+			offs, err = subject.c.head().indices["age"].Get(Value{27})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(offs).To(BeNil())
+		})
+
+		It("should rollback on column write failures", func() {
+			subject.c.head().columns["last"] = testColumnBadAdd{subject.c.head().columns["last"]}
+
+			n, err := subject.Commit()
+			Expect(n).To(Equal(int64(0)))
+			Expect(err).To(Equal(io.ErrShortWrite))
+			Expect(subject.c.Offset()).To(Equal(int64(0)))
+			Expect(subject.c.head().columns["first"].Len()).To(Equal(int64(0)))
+
+			offs, err := subject.c.head().indices["age"].Get(Value{27})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(offs).To(BeNil())
+		})
+
+		It("should not write cancelled commits", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			n, err := subject.CommitContext(ctx)
+			Expect(n).To(Equal(int64(0)))
+			Expect(err).To(Equal(context.Canceled))
+			Expect(subject.c.head().columns["first"].Len()).To(Equal(int64(0)))
+		})
+
+		It("should rollback commits cancelled while writing", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			subject.c.head().indices["age"] = testIndexCancelWrite{subject.c.head().indices["age"], cancel}
+
+			n, err := subject.CommitContext(ctx)
+			Expect(n).To(Equal(int64(0)))
+			Expect(err).To(Equal(context.Canceled))
+			Expect(subject.c.Offset()).To(Equal(int64(0)))
+			Expect(subject.c.head().columns["first"].Len()).To(Equal(int64(0)))
+			Expect(subject.c.counters.rollbacks).To(Equal(int64(1)))
+
+			offs, err := subject.c.head().indices["age"].Get(Value{27})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(offs).To(BeNil())
+
+			n, err = subject.CommitContext(context.Background())
+			Expect(n).To(Equal(int64(2)))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+	})
+
+})
+
+var _ = Describe("parallel", func() {
+
+	It("should run tasks with bounded concurrency", func() {
+		var mu sync.Mutex
+		var running, peak int
+
+		tasks := make([]func() error, 20)
+		for i := range tasks {
+			i := i
+			tasks[i] = func() error {
+				mu.Lock()
+				if running++; running > peak {
+					peak = running
+				}
+				mu.Unlock()
+
+				time.Sleep(time.Millisecond)
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+
+				if i%7 == 3 {
+					return io.EOF
+				}
+				return nil
+			}
+		}
+
+		errs := parallel(3, tasks)
+		Expect(errs).To(HaveLen(20))
+		Expect(peak).To(BeNumerically("<=", 3))
+		for i, err := range errs {
+			if i%7 == 3 {
+				Expect(err).To(Equal(io.EOF))
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+			}
+		}
 	})
 
 })
@@ -0,0 +1,169 @@
+package collie
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"sort"
+
+	"github.com/bsm/collie/column"
+)
+
+// ValueCount is a distinct index value with the number of rows it indexes
+type ValueCount struct {
+	Value Value
+	Count int
+}
+
+// ValueIterator iterates over distinct index values in ascending order
+type ValueIterator struct {
+	iter column.IndexIterator
+}
+
+// Next advances the iterator, returns false when exhausted
+func (i *ValueIterator) Next() bool { return i.iter.Next() }
+
+// Value returns the current value
+func (i *ValueIterator) Value() Value { return i.iter.Value() }
+
+// Count returns the number of rows indexed by the current value
+func (i *ValueIterator) Count() int { return len(i.iter.Offsets()) }
+
+// Err returns iteration errors, if any
+func (i *ValueIterator) Err() error { return i.iter.Error() }
+
+// Close releases the iterator
+func (i *ValueIterator) Close() { i.iter.Release() }
+
+// topValues collects the n most frequent values of an iterator
+func topValues(ctx context.Context, iter *ValueIterator, n int) ([]ValueCount, error) {
+	var top valueCounts
+	if n > 0 {
+		top = make(valueCounts, 0, n)
+	}
+	for iter.Next() {
+		if err := canceled(ctx); err != nil {
+			return nil, err
+		}
+		vc := ValueCount{Value: iter.Value(), Count: iter.Count()}
+		if n < 1 || len(top) < n {
+			heap.Push(&top, vc)
+		} else if top.less(top[0], vc) {
+			top[0] = vc
+			heap.Fix(&top, 0)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Sort(sort.Reverse(top))
+	return top, nil
+}
+
+// valueCounts is a min-heap, ordered by ascending count and descending value
+type valueCounts []ValueCount
+
+func (h valueCounts) Len() int            { return len(h) }
+func (h valueCounts) Less(i, j int) bool  { return h.less(h[i], h[j]) }
+func (h valueCounts) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *valueCounts) Push(x interface{}) { *h = append(*h, x.(ValueCount)) }
+func (h *valueCounts) Pop() interface{} {
+	old := *h
+	n := len(old) - 1
+	x := old[n]
+	*h = old[:n]
+	return x
+}
+
+func (valueCounts) less(a, b ValueCount) bool {
+	if a.Count != b.Count {
+		return a.Count < b.Count
+	}
+	return bytes.Compare(a.Value, b.Value) > 0
+}
+
+// mergeIterators combines the index iterators of multiple segments.
+// Offsets of values present in multiple segments are concatenated in
+// the order of iters.
+func mergeIterators(iters []column.IndexIterator) column.IndexIterator {
+	if len(iters) == 1 {
+		return iters[0]
+	}
+	return &mergeIterator{iters: iters}
+}
+
+type mergeIterator struct {
+	iters   []column.IndexIterator
+	heads   iteratorHeads
+	started bool
+
+	val  []byte
+	offs []int64
+	err  error
+}
+
+func (m *mergeIterator) Next() bool {
+	if !m.started {
+		m.started = true
+		m.heads = iteratorHeads{iters: m.iters}
+		for n := range m.iters {
+			m.advance(n)
+		}
+	}
+	if m.err != nil || len(m.heads.pos) == 0 {
+		return false
+	}
+
+	n := m.heads.pos[0]
+	m.val = append([]byte(nil), m.iters[n].Value()...)
+	m.offs = nil
+	for len(m.heads.pos) != 0 && bytes.Equal(m.iters[m.heads.pos[0]].Value(), m.val) {
+		n = heap.Pop(&m.heads).(int)
+		m.offs = append(m.offs, m.iters[n].Offsets()...)
+		m.advance(n)
+	}
+	return m.err == nil
+}
+
+func (m *mergeIterator) Value() []byte    { return m.val }
+func (m *mergeIterator) Offsets() []int64 { return m.offs }
+func (m *mergeIterator) Error() error     { return m.err }
+
+func (m *mergeIterator) Release() {
+	for _, iter := range m.iters {
+		iter.Release()
+	}
+}
+
+// advance moves the n-th iterator forward and pushes it back on the heap
+func (m *mergeIterator) advance(n int) {
+	if m.iters[n].Next() {
+		heap.Push(&m.heads, n)
+	} else if err := m.iters[n].Error(); err != nil && m.err == nil {
+		m.err = err
+	}
+}
+
+// iteratorHeads is a min-heap of iterator positions,
+// ordered by their current values
+type iteratorHeads struct {
+	iters []column.IndexIterator
+	pos   []int
+}
+
+func (h iteratorHeads) Len() int { return len(h.pos) }
+func (h iteratorHeads) Less(i, j int) bool {
+	if n := bytes.Compare(h.iters[h.pos[i]].Value(), h.iters[h.pos[j]].Value()); n != 0 {
+		return n < 0
+	}
+	return h.pos[i] < h.pos[j]
+}
+func (h iteratorHeads) Swap(i, j int)       { h.pos[i], h.pos[j] = h.pos[j], h.pos[i] }
+func (h *iteratorHeads) Push(x interface{}) { h.pos = append(h.pos, x.(int)) }
+func (h *iteratorHeads) Pop() interface{} {
+	n := len(h.pos) - 1
+	x := h.pos[n]
+	h.pos = h.pos[:n]
+	return x
+}
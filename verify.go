@@ -0,0 +1,266 @@
+package collie
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bsm/collie/column"
+)
+
+// An Issue describes an inconsistency within a segment
+type Issue struct {
+	// Offset of the first row of the affected segment
+	Segment int64
+	// Name of the affected column or index
+	Name string
+	// Description of the problem
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("segment %d, %s: %s", i.Segment, i.Name, i.Message)
+}
+
+// validator is implemented by columns able to detect corrupt rows
+type validator interface {
+	ValidLen() (int64, error)
+}
+
+// lossDetector is implemented by indices able to detect lost postings
+type lossDetector interface {
+	Lost() bool
+}
+
+// Verify checks all segments for columns with inconsistent lengths,
+// variable columns pointing past their data files, index entries
+// referencing rows beyond the consistent prefix of a segment and
+// indices which lost postings of stored rows.
+func (c *Collection) Verify() ([]Issue, error) {
+	return c.VerifyContext(context.Background())
+}
+
+// VerifyContext behaves like Verify, but aborts when ctx is done,
+// checking between segments
+func (c *Collection) VerifyContext(ctx context.Context) ([]Issue, error) {
+	c.wmux.Lock()
+	defer c.wmux.Unlock()
+
+	return c.verify(ctx, false)
+}
+
+// Repair behaves like Verify, but truncates all columns to the consistent
+// prefix of each segment and prunes dangling index entries. Rows missing
+// from segments other than the current one cannot be recovered and are
+// reported only. Returns the issues found.
+func (c *Collection) Repair() ([]Issue, error) {
+	return c.RepairContext(context.Background())
+}
+
+// RepairContext behaves like Repair, but aborts when ctx is done,
+// checking between segments. Segments repaired so far remain repaired.
+func (c *Collection) RepairContext(ctx context.Context) ([]Issue, error) {
+	if c.opts.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	c.wmux.Lock()
+	defer c.wmux.Unlock()
+
+	return c.verify(ctx, true)
+}
+
+// Scrub verifies the checksums of all stored column data and reports
+// corrupt columns. Segments are scrubbed one at a time, concurrent reads
+// and commits are not blocked.
+func (c *Collection) Scrub() ([]Issue, error) {
+	return c.ScrubContext(context.Background())
+}
+
+// ScrubContext behaves like Scrub, but aborts when ctx is done,
+// checking between segments
+func (c *Collection) ScrubContext(ctx context.Context) ([]Issue, error) {
+	var issues []Issue
+	for base := int64(-1); ; {
+		if err := canceled(ctx); err != nil {
+			return issues, err
+		}
+
+		c.smux.RLock()
+		seg := c.segmentAfter(base)
+		if seg == nil && base < 0 {
+			c.smux.RUnlock()
+			return nil, ErrClosed
+		} else if seg == nil {
+			c.smux.RUnlock()
+			return issues, nil
+		}
+
+		found, err := c.scrubSegment(seg)
+		c.smux.RUnlock()
+
+		issues = append(issues, found...)
+		if err != nil {
+			return issues, err
+		}
+		base = seg.base
+	}
+}
+
+// segmentAfter returns the first segment starting after base,
+// must be called while holding a read lock
+func (c *Collection) segmentAfter(base int64) *segment {
+	for _, seg := range c.segs {
+		if seg.base > base {
+			return seg
+		}
+	}
+	return nil
+}
+
+// scrubSegment verifies the checksums of all columns in seg
+func (c *Collection) scrubSegment(seg *segment) ([]Issue, error) {
+	var issues []Issue
+	for _, cc := range c.schema.Columns() {
+		col, ok := seg.columns[cc.Name]
+		if !ok {
+			continue
+		}
+
+		if err := col.Scrub(); err != nil {
+			if _, ok := err.(*column.CorruptionError); !ok {
+				return issues, err
+			}
+			issues = append(issues, Issue{Segment: seg.base, Name: cc.Name, Message: err.Error()})
+		}
+	}
+	return issues, nil
+}
+
+// verify verifies and optionally repairs all segments, must only be
+// called while holding the write lock
+func (c *Collection) verify(ctx context.Context, repair bool) ([]Issue, error) {
+	c.smux.RLock()
+	segs := c.segs
+	c.smux.RUnlock()
+
+	if len(segs) == 0 {
+		return nil, ErrClosed
+	}
+
+	var issues []Issue
+	for i, seg := range segs {
+		if err := canceled(ctx); err != nil {
+			return issues, err
+		}
+
+		limit := c.Offset() - seg.base
+		if i+1 < len(segs) {
+			limit = segs[i+1].base - seg.base
+		}
+
+		rows, found, err := c.verifySegment(seg, limit, repair)
+		issues = append(issues, found...)
+		if err != nil {
+			return issues, err
+		}
+
+		if repair && i+1 == len(segs) && rows < limit {
+			offset := seg.base + rows
+			if err := c.commitOffset(offset); err != nil {
+				return issues, err
+			}
+			c.storeOffset(offset)
+		}
+	}
+	return issues, nil
+}
+
+// verifySegment verifies seg, expected to hold limit rows, and returns
+// the number of consistent rows
+func (c *Collection) verifySegment(seg *segment, limit int64, repair bool) (int64, []Issue, error) {
+	var issues []Issue
+	report := func(name, format string, args ...interface{}) {
+		issues = append(issues, Issue{Segment: seg.base, Name: name, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Determine the consistent prefix
+	rows := limit
+	for _, cc := range c.schema.Columns() {
+		col, ok := seg.columns[cc.Name]
+		if !ok {
+			continue
+		}
+
+		n := col.Len()
+		if v, ok := col.(validator); ok {
+			valid, err := v.ValidLen()
+			if err != nil {
+				return rows, issues, err
+			}
+			if valid < n {
+				report(cc.Name, "%d rows point past the data file", n-valid)
+				n = valid
+			}
+		}
+		if n < limit {
+			report(cc.Name, "%d of %d rows missing", limit-n, limit)
+		}
+		if n < rows {
+			rows = n
+		}
+	}
+
+	for _, cc := range c.schema.Columns() {
+		// Check columns
+		if col, ok := seg.columns[cc.Name]; ok {
+			if n := col.Len(); n > rows {
+				report(cc.Name, "%d rows beyond the consistent prefix", n-rows)
+				if repair {
+					if err := col.Truncate(rows); err != nil {
+						return rows, issues, err
+					}
+				}
+			}
+		}
+
+		// Check indices
+		if idx, ok := seg.indices[cc.Name]; ok {
+			if d, ok := idx.(lossDetector); ok && d.Lost() {
+				report(cc.Name, "postings of stored rows were lost, the index was not closed")
+			}
+
+			dangling, n, err := danglingPostings(idx, seg.base, seg.base+rows)
+			if err != nil {
+				return rows, issues, err
+			}
+			if n == 0 {
+				continue
+			}
+
+			report(cc.Name, "%d index entries reference offsets outside [%d, %d)", n, seg.base, seg.base+rows)
+			if repair {
+				if err := idx.Remove(dangling); err != nil {
+					return rows, issues, err
+				}
+			}
+		}
+	}
+	return rows, issues, nil
+}
+
+// danglingPostings returns all postings of idx outside [min, max)
+func danglingPostings(idx column.Index, min, max int64) (column.Postings, int, error) {
+	iter := idx.Iterate(nil)
+	defer iter.Release()
+
+	p, n := make(column.Postings), 0
+	for iter.Next() {
+		for _, off := range iter.Offsets() {
+			if off < min || off >= max {
+				p[string(iter.Value())] = append(p[string(iter.Value())], off)
+				n++
+			}
+		}
+	}
+	return p, n, iter.Error()
+}
@@ -0,0 +1,179 @@
+package collie
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bsm/collie/column"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Verify", func() {
+	var subject *Collection
+	var schema *Schema
+
+	open := func(opts *Options) {
+		var err error
+		subject, err = OpenCollectionWithOptions(testDir, schema, opts)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		schema = CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "code", Size: 2},
+			{Name: "tag", Index: IndexTypeHash, NoData: true},
+		})
+		open(nil)
+
+		txn := subject.Begin(3)
+		for _, name := range []string{"alice", "bob", "anna"} {
+			txn.Add(testRecord{"name": Value(name), "tag": Value(name[:1]), "code": Value(name[:2])})
+		}
+		_, err := txn.Commit()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+	})
+
+	It("should verify consistent collections", func() {
+		Expect(subject.Verify()).To(BeEmpty())
+	})
+
+	It("should abort when cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := subject.VerifyContext(ctx)
+		Expect(err).To(Equal(context.Canceled))
+		_, err = subject.RepairContext(ctx)
+		Expect(err).To(Equal(context.Canceled))
+		_, err = subject.ScrubContext(ctx)
+		Expect(err).To(Equal(context.Canceled))
+	})
+
+	It("should detect and repair uneven columns", func() {
+		Expect(subject.segs[0].columns["code"].Add([]byte("xx"))).To(Succeed())
+		Expect(subject.segs[0].indices["tag"].Add([]byte("x"), 3, 7)).To(Succeed())
+
+		issues, err := subject.Verify()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(issues).To(Equal([]Issue{
+			{Segment: 0, Name: "code", Message: "1 rows beyond the consistent prefix"},
+			{Segment: 0, Name: "tag", Message: "2 index entries reference offsets outside [0, 3)"},
+		}))
+		Expect(issues[1].String()).To(Equal("segment 0, tag: 2 index entries reference offsets outside [0, 3)"))
+
+		Expect(subject.Repair()).To(HaveLen(2))
+		Expect(subject.Verify()).To(BeEmpty())
+		Expect(subject.segs[0].columns["code"].Len()).To(Equal(int64(3)))
+		Expect(subject.Offsets("tag", []byte("x"))).To(BeEmpty())
+		Expect(subject.Offset()).To(Equal(int64(3)))
+	})
+
+	It("should detect and repair dangling variable rows", func() {
+		Expect(subject.Close()).To(Succeed())
+		Expect(os.Truncate(filepath.Join(testDir, "name.cc"), 8)).To(Succeed())
+		open(nil)
+
+		issues, err := subject.Verify()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(issues).To(Equal([]Issue{
+			{Segment: 0, Name: "name", Message: "1 rows point past the data file"},
+			{Segment: 0, Name: "name", Message: "1 of 3 rows missing"},
+			{Segment: 0, Name: "name", Message: "1 rows beyond the consistent prefix"},
+			{Segment: 0, Name: "code", Message: "1 rows beyond the consistent prefix"},
+			{Segment: 0, Name: "tag", Message: "1 index entries reference offsets outside [0, 2)"},
+		}))
+
+		Expect(subject.Repair()).To(HaveLen(5))
+		Expect(subject.Verify()).To(BeEmpty())
+		Expect(subject.Offset()).To(Equal(int64(2)))
+		Expect(subject.Value("name", 1)).To(Equal([]byte("bob")))
+		Expect(subject.Offsets("tag", []byte("a"))).To(Equal([]int64{0}))
+
+		Expect(subject.Close()).To(Succeed())
+		open(nil)
+		Expect(subject.Offset()).To(Equal(int64(2)))
+	})
+
+	It("should scrub checksums", func() {
+		Expect(subject.Scrub()).To(BeEmpty())
+
+		file, err := os.OpenFile(filepath.Join(testDir, "code.cc"), os.O_WRONLY, 0644)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = file.WriteAt([]byte("x"), 3)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		issues, err := subject.Scrub()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(issues).To(HaveLen(1))
+		Expect(issues[0].Name).To(Equal("code"))
+		Expect(issues[0].Message).To(HavePrefix("collie: checksum mismatch in "))
+
+		_, err = subject.Value("code", 1)
+		Expect(err).To(BeAssignableToTypeOf(&column.CorruptionError{}))
+		Expect(subject.Value("name", 1)).To(Equal([]byte("bob")))
+	})
+
+	It("should verify read-only collections", func() {
+		Expect(subject.Close()).To(Succeed())
+		open(&Options{ReadOnly: true})
+
+		Expect(subject.Verify()).To(BeEmpty())
+		_, err := subject.Repair()
+		Expect(err).To(Equal(ErrReadOnly))
+	})
+
+	It("should report missing rows in closed segments", func() {
+		Expect(subject.Close()).To(Succeed())
+		open(&Options{SegmentRows: 2})
+
+		txn := subject.Begin(1)
+		txn.Add(testRecord{"name": Value("amber"), "tag": Value("a"), "code": Value("am")})
+		_, err := txn.Commit()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.segs).To(HaveLen(2))
+
+		Expect(subject.segs[0].columns["code"].Truncate(2)).To(Succeed())
+		Expect(subject.Repair()).To(Equal([]Issue{
+			{Segment: 0, Name: "code", Message: "1 of 3 rows missing"},
+			{Segment: 0, Name: "name", Message: "1 rows beyond the consistent prefix"},
+			{Segment: 0, Name: "tag", Message: "1 index entries reference offsets outside [0, 2)"},
+		}))
+		Expect(subject.Offset()).To(Equal(int64(4)))
+		Expect(subject.Verify()).To(Equal([]Issue{
+			{Segment: 0, Name: "name", Message: "1 of 3 rows missing"},
+			{Segment: 0, Name: "code", Message: "1 of 3 rows missing"},
+		}))
+	})
+
+	It("should report lost postings", func() {
+		dir := filepath.Join(testDir, "memory")
+		schema := CreateSchema([]Column{
+			{Name: "name"},
+			{Name: "tag", Index: IndexTypeMemory, NoData: true},
+		})
+		coll, err := OpenCollection(dir, schema)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(coll.Verify()).To(BeEmpty())
+
+		// Simulate a crash, the marker of the open index is left behind
+		Expect(coll.Close()).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "tag.ci.open"), nil, 0644)).To(Succeed())
+		coll, err = OpenCollection(dir, schema)
+		Expect(err).NotTo(HaveOccurred())
+		defer coll.Close()
+
+		Expect(coll.Verify()).To(Equal([]Issue{
+			{Segment: 0, Name: "tag", Message: "postings of stored rows were lost, the index was not closed"},
+		}))
+	})
+
+})
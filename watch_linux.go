@@ -0,0 +1,64 @@
+//go:build linux
+// +build linux
+
+package collie
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// offsetWatcher signals modifications of the offset file
+type offsetWatcher struct {
+	C    chan struct{}
+	file *os.File
+}
+
+// watchOffset watches the offset file of the collection in dir using inotify
+func watchOffset(dir string) (*offsetWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	if _, err := syscall.InotifyAddWatch(fd, dir, syscall.IN_MODIFY|syscall.IN_CREATE|syscall.IN_MOVED_TO); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("inotify_add_watch", err)
+	}
+
+	w := &offsetWatcher{C: make(chan struct{}, 1), file: os.NewFile(uintptr(fd), dir)}
+	go w.loop()
+	return w, nil
+}
+
+// Close stops watching
+func (w *offsetWatcher) Close() error { return w.file.Close() }
+
+func (w *offsetWatcher) loop() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for pos := 0; pos+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[pos]))
+			pos += syscall.SizeofInotifyEvent
+
+			name := buf[pos : pos+int(event.Len)]
+			if string(bytes.TrimRight(name, "\x00")) == offsetFile {
+				w.notify()
+			}
+			pos += int(event.Len)
+		}
+	}
+}
+
+func (w *offsetWatcher) notify() {
+	select {
+	case w.C <- struct{}{}:
+	default:
+	}
+}
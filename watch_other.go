@@ -0,0 +1,55 @@
+//go:build !linux
+// +build !linux
+
+package collie
+
+import (
+	"sync"
+	"time"
+)
+
+// The interval at which the offset file is polled
+// on platforms without inotify support
+var watchPollInterval = 100 * time.Millisecond
+
+// offsetWatcher signals possible modifications of the offset file
+type offsetWatcher struct {
+	C      chan struct{}
+	ticker *time.Ticker
+	done   chan struct{}
+	once   sync.Once
+}
+
+// watchOffset polls the offset file of the collection in dir
+func watchOffset(dir string) (*offsetWatcher, error) {
+	w := &offsetWatcher{
+		C:      make(chan struct{}, 1),
+		ticker: time.NewTicker(watchPollInterval),
+		done:   make(chan struct{}),
+	}
+	go w.loop()
+	return w, nil
+}
+
+// Close stops watching
+func (w *offsetWatcher) Close() error {
+	w.once.Do(func() {
+		w.ticker.Stop()
+		close(w.done)
+	})
+	return nil
+}
+
+func (w *offsetWatcher) loop() {
+	for {
+		select {
+		case <-w.ticker.C:
+			select {
+			case w.C <- struct{}{}:
+			default:
+			}
+		case <-w.done:
+			return
+		}
+	}
+}